@@ -0,0 +1,202 @@
+// Program ingestworker pulls measurements from a Pub/Sub subscription and saves them, as an
+// alternative to the web app's push endpoint. It runs the same pipeline as the push handler
+// and acknowledges each message only once it's been saved or quarantined, so messages that
+// fail to save are redelivered.
+//
+// Set PUBSUB_EMULATOR_HOST and DATASTORE_EMULATOR_HOST to run against the emulators.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"cloud.google.com/go/compute/metadata"
+	"cloud.google.com/go/pubsub"
+	"github.com/mtraver/environmental-sensor/web/db"
+	"github.com/mtraver/environmental-sensor/web/ingest"
+
+	// The drivers that the sql sink can use. SQLite's is in sqlite3.go.
+	_ "github.com/lib/pq"
+)
+
+const (
+	datastoreKind  = "measurement"
+	quarantineKind = "quarantined_message"
+)
+
+var (
+	registryID     string
+	unsignedPolicy string
+	fillDeviceID   bool
+	quarantineDir  string
+	sinks          string
+	maxMessages    int
+	maxBytes       int
+	numGoroutines  int
+)
+
+func init() {
+	flag.StringVar(&registryID, "registry", "", "the ID of the IoT Core registry from which messages are expected; required to verify signed measurements")
+	flag.StringVar(&unsignedPolicy, "unsigned", ingest.AcceptUnsigned, fmt.Sprintf("what to do with unsigned measurements, either %q or %q", ingest.AcceptUnsigned, ingest.RejectUnsigned))
+	flag.BoolVar(&fillDeviceID, "fill-device-id", false, "set to true to give measurements with no device ID the ID of the device that published them")
+	flag.StringVar(&quarantineDir, "quarantine-dir", "", "directory in which to quarantine rejected messages; if empty they're quarantined in Datastore")
+	flag.StringVar(&sinks, "sinks", "", "comma-separated sinks to save measurements to, e.g. \"!datastore,influxdb,file=measurements.ndjson\"; a \"!\" prefix makes a sink required. Defaults to \"!datastore\", plus \"influxdb\" if INFLUXDB_SERVER is set")
+	flag.IntVar(&maxMessages, "max-outstanding-messages", 100, "the maximum number of messages being processed at once")
+	flag.IntVar(&maxBytes, "max-outstanding-bytes", 10*1024*1024, "the maximum total size of the messages being processed at once")
+	flag.IntVar(&numGoroutines, "goroutines", 1, "the number of goroutines that pull messages")
+
+	flag.Usage = func() {
+		message := `usage: ingestworker [options] subscription
+
+Positional Arguments (required):
+  subscription
+	the ID of the Pub/Sub subscription from which to pull measurements
+
+Environment Variables:
+  PROJECT_ID
+	the Google Cloud project ID; not needed on GCE
+  INFLUXDB_SERVER, INFLUXDB_TOKEN, INFLUXDB_ORG, INFLUXDB_BUCKET
+	the InfluxDB to save measurements to if the influxdb sink is used
+  SQL_DRIVER, SQL_DSN
+	the SQL database to save measurements to if the sql sink is used; the driver is either
+	"sqlite3", if built with "-tags sqlite3" (which needs cgo), or "postgres"
+  INFLUXDB_MEASUREMENT, INFLUXDB_TAGS
+	the InfluxDB measurement name (default "stat") and tags to add to every point, e.g. "site=home"
+
+Options:
+`
+
+		fmt.Fprintf(flag.CommandLine.Output(), message)
+		flag.PrintDefaults()
+	}
+}
+
+// receive pulls messages from the subscription and runs each through the pipeline until ctx
+// is done. Messages that the pipeline says should be re-tried are nacked so that Pub/Sub
+// redelivers them.
+func receive(ctx context.Context, sub *pubsub.Subscription, p ingest.Pipeline) error {
+	return sub.Receive(ctx, func(ctx context.Context, msg *pubsub.Message) {
+		if err := p.Handle(ctx, msg.ID, msg.Attributes, msg.Data); err != nil {
+			log.Printf("Failed to ingest message %s: %v", msg.ID, err)
+			msg.Nack()
+			return
+		}
+		msg.Ack()
+	})
+}
+
+func main() {
+	flag.Parse()
+
+	if len(flag.Args()) != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	subscriptionID := flag.Args()[0]
+
+	if unsignedPolicy != ingest.AcceptUnsigned && unsignedPolicy != ingest.RejectUnsigned {
+		fmt.Printf("argument error: -unsigned must be %q or %q\n", ingest.AcceptUnsigned, ingest.RejectUnsigned)
+		os.Exit(2)
+	}
+
+	projectID := os.Getenv("PROJECT_ID")
+	if projectID == "" && metadata.OnGCE() {
+		var err error
+		projectID, err = metadata.ProjectID()
+		if err != nil {
+			log.Fatalf("Failed to get project ID: %v", err)
+		}
+	}
+
+	if sinks == "" {
+		sinks = "!datastore"
+		if os.Getenv("INFLUXDB_SERVER") != "" {
+			sinks += ",influxdb"
+		}
+	}
+	outputs, err := ingest.ParseOutputs(sinks, map[string]func(string) (ingest.Sink, error){
+		"datastore": func(string) (ingest.Sink, error) {
+			return db.NewDatastoreDB(projectID, datastoreKind, nil)
+		},
+		"sql": func(string) (ingest.Sink, error) {
+			return db.NewSQLDB(os.Getenv("SQL_DRIVER"), os.Getenv("SQL_DSN"))
+		},
+		"influxdb": func(string) (ingest.Sink, error) {
+			server := os.Getenv("INFLUXDB_SERVER")
+			if server == "" {
+				return nil, fmt.Errorf("INFLUXDB_SERVER must be set")
+			}
+			tags, err := db.ParseInfluxDBTags(os.Getenv("INFLUXDB_TAGS"))
+			if err != nil {
+				return nil, err
+			}
+			return db.NewInfluxDB(server, os.Getenv("INFLUXDB_TOKEN"), os.Getenv("INFLUXDB_ORG"), os.Getenv("INFLUXDB_BUCKET"), db.InfluxDBOptions{
+				Measurement: os.Getenv("INFLUXDB_MEASUREMENT"),
+				Tags:        tags,
+			})
+		},
+	})
+	if err != nil {
+		log.Fatalf("Failed to make sinks: %v", err)
+	}
+
+	var quarantine ingest.Quarantine
+	if quarantineDir != "" {
+		quarantine, err = db.NewFileQuarantine(quarantineDir)
+	} else {
+		quarantine, err = db.NewDatastoreQuarantine(projectID, quarantineKind)
+	}
+	if err != nil {
+		log.Fatalf("Failed to make quarantine: %v", err)
+	}
+
+	pipeline := ingest.Pipeline{
+		Outputs:        outputs,
+		UnsignedPolicy: unsignedPolicy,
+		ProjectID:      projectID,
+		RegistryID:     registryID,
+		FillDeviceID:   fillDeviceID,
+		Quarantine:     quarantine,
+	}
+	if registryID != "" {
+		pipeline.Keys = &ingest.IoTCoreKeys{
+			ProjectID:  projectID,
+			RegistryID: registryID,
+			TTL:        10 * time.Minute,
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Stop pulling on interrupt. Receive waits for the messages being processed to finish.
+	c := make(chan os.Signal, 2)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-c
+		log.Println("Shutting down...")
+		cancel()
+	}()
+
+	client, err := pubsub.NewClient(ctx, projectID)
+	if err != nil {
+		log.Fatalf("Failed to make Pub/Sub client: %v", err)
+	}
+	defer client.Close()
+
+	sub := client.Subscription(subscriptionID)
+	sub.ReceiveSettings.MaxOutstandingMessages = maxMessages
+	sub.ReceiveSettings.MaxOutstandingBytes = maxBytes
+	sub.ReceiveSettings.NumGoroutines = numGoroutines
+
+	log.Printf("Pulling from subscription %s", sub)
+	if err := receive(ctx, sub, pipeline); err != nil {
+		log.Fatalf("Failed to receive: %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"cloud.google.com/go/pubsub/pstest"
+	mpb "github.com/mtraver/environmental-sensor/measurementpb"
+	"github.com/mtraver/environmental-sensor/web/ingest"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	tspb "google.golang.org/protobuf/types/known/timestamppb"
+	wpb "google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// flakyDatabase fails as many saves as its failures field says and then records the rest.
+type flakyDatabase struct {
+	mu       sync.Mutex
+	failures int
+	saved    []*mpb.Measurement
+	done     chan struct{}
+}
+
+func (d *flakyDatabase) Save(ctx context.Context, m *mpb.Measurement) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.failures > 0 {
+		d.failures--
+		return errors.New("unavailable")
+	}
+
+	d.saved = append(d.saved, m)
+	close(d.done)
+	return nil
+}
+
+func TestReceive(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	srv := pstest.NewServer()
+	defer srv.Close()
+
+	conn, err := grpc.Dial(srv.Addr, grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer conn.Close()
+
+	client, err := pubsub.NewClient(ctx, "proj", option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer client.Close()
+
+	topic, err := client.CreateTopic(ctx, "telemetry")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	sub, err := client.CreateSubscription(ctx, "ingest", pubsub.SubscriptionConfig{Topic: topic})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	m := &mpb.Measurement{
+		DeviceId:  "foo",
+		Timestamp: tspb.New(time.Date(2018, time.March, 25, 0, 0, 0, 0, time.UTC)),
+		Temp:      wpb.Float(18.5),
+	}
+	data, err := proto.Marshal(m)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	id := srv.Publish("projects/proj/topics/telemetry", data, map[string]string{"deviceId": "foo"})
+
+	// The first save fails, so the message should be nacked and then redelivered.
+	database := &flakyDatabase{failures: 1, done: make(chan struct{})}
+	receiveCtx, stop := context.WithCancel(ctx)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- receive(receiveCtx, sub, ingest.Pipeline{Outputs: []*ingest.Output{{Name: "db", Sink: database, Required: true}}})
+	}()
+
+	select {
+	case <-database.done:
+	case <-ctx.Done():
+		t.Fatal("Timed out waiting for the measurement to be saved")
+	}
+	stop()
+	if err := <-errc; err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	if len(database.saved) != 1 || !proto.Equal(database.saved[0], m) {
+		t.Errorf("got saved %v, want [%v]", database.saved, m)
+	}
+
+	msg := srv.Message(id)
+	if msg.Deliveries < 2 {
+		t.Errorf("got %d deliveries, want at least 2", msg.Deliveries)
+	}
+	if msg.Acks != 1 {
+		t.Errorf("got %d acks, want 1", msg.Acks)
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	mpb "github.com/mtraver/environmental-sensor/measurementpb"
+	mpbutil "github.com/mtraver/environmental-sensor/measurementpbutil"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	durationpb "google.golang.org/protobuf/types/known/durationpb"
+	tspb "google.golang.org/protobuf/types/known/timestamppb"
+	wpb "google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// summaryKey identifies the samples of one metric from one sensor.
+type summaryKey struct {
+	metric   string
+	sensor   string
+	location string
+}
+
+type summaryStats struct {
+	min   float32
+	max   float32
+	sum   float64
+	count uint32
+}
+
+// aggregator buffers the samples taken by a SENSE job and summarizes each window of them.
+// Windows are aligned to multiples of their length, e.g. a window of one minute starts on
+// the minute. Buffered samples aren't persisted, so they're lost if the program exits
+// without flushing them.
+type aggregator struct {
+	window time.Duration
+
+	// Publishes the summaries of windows that aren't returned by add: those flushed by
+	// flush, and those that are still open a window's length after they ended, e.g. because
+	// a sensor has failed. If nil, windows are only summarized by add.
+	publish func(m *mpb.Measurement)
+
+	mu    sync.Mutex
+	timer *time.Timer
+	// Incremented each time a window is summarized so that a timer that fires after its
+	// window was summarized doesn't summarize the next one.
+	gen int
+	// The start of the current window. Zero if there are no samples.
+	start    time.Time
+	deviceID string
+	stats    map[summaryKey]*summaryStats
+	// The keys of stats in the order in which they were first seen.
+	keys  []summaryKey
+	alert *mpb.TempAlert
+}
+
+func newAggregator(window time.Duration, publish func(m *mpb.Measurement)) *aggregator {
+	return &aggregator{
+		window:  window,
+		publish: publish,
+		stats:   make(map[summaryKey]*summaryStats),
+	}
+}
+
+// add buffers the readings in m. If m is the first sample in a new window then the previous
+// window is summarized and its summary is returned. Otherwise add returns nil.
+func (a *aggregator) add(m *mpb.Measurement) *mpb.Measurement {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var summary *mpb.Measurement
+	windowStart := m.GetTimestamp().AsTime().Truncate(a.window)
+	if !a.start.IsZero() && !windowStart.Equal(a.start) {
+		summary = a.summarizeLocked()
+	}
+
+	if a.start.IsZero() {
+		a.start = windowStart
+		a.deviceID = m.GetDeviceId()
+		a.startTimerLocked()
+	}
+
+	for _, r := range m.GetReadings() {
+		key := summaryKey{r.GetMetric(), r.GetSensor(), r.GetLocation()}
+		s, ok := a.stats[key]
+		if !ok {
+			s = &summaryStats{min: r.GetValue(), max: r.GetValue()}
+			a.stats[key] = s
+			a.keys = append(a.keys, key)
+		}
+
+		if r.GetValue() < s.min {
+			s.min = r.GetValue()
+		}
+		if r.GetValue() > s.max {
+			s.max = r.GetValue()
+		}
+		s.sum += float64(r.GetValue())
+		s.count++
+	}
+
+	if alert := m.GetTempAlert(); alert != nil {
+		if a.alert == nil {
+			a.alert = &mpb.TempAlert{}
+		}
+		a.alert.Lower = a.alert.GetLower() || alert.GetLower()
+		a.alert.Upper = a.alert.GetUpper() || alert.GetUpper()
+		a.alert.Critical = a.alert.GetCritical() || alert.GetCritical()
+	}
+
+	return summary
+}
+
+// flush publishes the summary of the current window, if it has any samples.
+func (a *aggregator) flush() {
+	a.mu.Lock()
+	if a.start.IsZero() || a.publish == nil {
+		a.mu.Unlock()
+		return
+	}
+	m := a.summarizeLocked()
+	a.mu.Unlock()
+
+	a.publish(m)
+}
+
+// startTimerLocked starts the timer that publishes the current window if the next one hasn't
+// started a window's length after it ended. a.mu must be held.
+func (a *aggregator) startTimerLocked() {
+	if a.publish == nil {
+		return
+	}
+
+	gen := a.gen
+	a.timer = time.AfterFunc(time.Until(a.start.Add(2*a.window)), func() {
+		a.mu.Lock()
+		if a.gen != gen || a.start.IsZero() {
+			a.mu.Unlock()
+			return
+		}
+		m := a.summarizeLocked()
+		a.mu.Unlock()
+
+		a.publish(m)
+	})
+}
+
+// summarizeLocked returns a Measurement that summarizes the current window and starts a
+// new, empty, window. Each metric is set to the mean of its samples. a.mu must be held.
+func (a *aggregator) summarizeLocked() *mpb.Measurement {
+	m := &mpb.Measurement{
+		DeviceId:  a.deviceID,
+		Timestamp: tspb.New(a.start),
+		Window:    durationpb.New(a.window),
+		TempAlert: a.alert,
+	}
+
+	// Merge the means from each sensor so that the metric fields and readings are set
+	// just as they are for a single sample.
+	type source struct {
+		sensor   string
+		location string
+	}
+	var sources []source
+	means := make(map[source]*mpb.Measurement)
+	for _, key := range a.keys {
+		s := a.stats[key]
+		mean := float32(s.sum / float64(s.count))
+		m.Summaries = append(m.Summaries, &mpb.Summary{
+			Metric:   key.metric,
+			Sensor:   key.sensor,
+			Location: key.location,
+			Min:      s.min,
+			Max:      s.max,
+			Mean:     mean,
+			Count:    s.count,
+		})
+
+		src := source{key.sensor, key.location}
+		if _, ok := means[src]; !ok {
+			means[src] = &mpb.Measurement{}
+			sources = append(sources, src)
+		}
+		setMetric(means[src], key.metric, mean)
+	}
+
+	for _, src := range sources {
+		mpbutil.Merge(m, means[src], src.sensor, src.location)
+	}
+
+	if a.timer != nil {
+		a.timer.Stop()
+		a.timer = nil
+	}
+	a.gen++
+
+	a.start = time.Time{}
+	a.stats = make(map[summaryKey]*summaryStats)
+	a.keys = nil
+	a.alert = nil
+
+	return m
+}
+
+// setMetric sets the Measurement field with the given name, e.g. "temp". It does nothing if
+// there's no such field.
+func setMetric(m *mpb.Measurement, metric string, v float32) {
+	r := m.ProtoReflect()
+	fd := r.Descriptor().Fields().ByName(protoreflect.Name(metric))
+	if fd == nil || fd.Message() == nil {
+		return
+	}
+	r.Set(fd, protoreflect.ValueOfMessage(wpb.Float(v).ProtoReflect()))
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	mpb "github.com/mtraver/environmental-sensor/measurementpb"
+	"google.golang.org/protobuf/testing/protocmp"
+	durationpb "google.golang.org/protobuf/types/known/durationpb"
+	tspb "google.golang.org/protobuf/types/known/timestamppb"
+	wpb "google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func sample(ts time.Time, temp float32, alert *mpb.TempAlert) *mpb.Measurement {
+	return &mpb.Measurement{
+		DeviceId:  "foo",
+		Timestamp: tspb.New(ts),
+		Temp:      wpb.Float(temp),
+		TempAlert: alert,
+		Readings: []*mpb.Reading{
+			{Metric: "temp", Sensor: "mcp9808", Location: "probe-in", Value: temp},
+			{Metric: "temp", Sensor: "bme280", Value: temp + 10},
+		},
+	}
+}
+
+func TestAggregator(t *testing.T) {
+	start := time.Date(2020, time.June, 1, 12, 0, 0, 0, time.UTC)
+	a := newAggregator(time.Minute, nil)
+
+	samples := []*mpb.Measurement{
+		sample(start.Add(5*time.Second), 20, nil),
+		sample(start.Add(25*time.Second), 18, &mpb.TempAlert{Upper: true}),
+		sample(start.Add(45*time.Second), 22, nil),
+	}
+	for i, m := range samples {
+		if got := a.add(m); got != nil {
+			t.Fatalf("add(samples[%d]) = %v, want nil", i, got)
+		}
+	}
+
+	// The first sample in the next window flushes the previous one.
+	got := a.add(sample(start.Add(65*time.Second), 30, nil))
+	want := &mpb.Measurement{
+		DeviceId:  "foo",
+		Timestamp: tspb.New(start),
+		Window:    durationpb.New(time.Minute),
+		Temp:      wpb.Float(20),
+		TempAlert: &mpb.TempAlert{Upper: true},
+		Readings: []*mpb.Reading{
+			{Metric: "temp", Sensor: "mcp9808", Location: "probe-in", Value: 20},
+			{Metric: "temp", Sensor: "bme280", Value: 30},
+		},
+		Summaries: []*mpb.Summary{
+			{Metric: "temp", Sensor: "mcp9808", Location: "probe-in", Min: 18, Max: 22, Mean: 20, Count: 3},
+			{Metric: "temp", Sensor: "bme280", Min: 28, Max: 32, Mean: 30, Count: 3},
+		},
+	}
+	if diff := cmp.Diff(got, want, protocmp.Transform()); diff != "" {
+		t.Errorf("Unexpected result (-got +want):\n%s", diff)
+	}
+
+	// A gap of more than one window still flushes only the window that has samples.
+	got = a.add(sample(start.Add(10*time.Minute), 0, nil))
+	want = &mpb.Measurement{
+		DeviceId:  "foo",
+		Timestamp: tspb.New(start.Add(time.Minute)),
+		Window:    durationpb.New(time.Minute),
+		Temp:      wpb.Float(30),
+		Readings: []*mpb.Reading{
+			{Metric: "temp", Sensor: "mcp9808", Location: "probe-in", Value: 30},
+			{Metric: "temp", Sensor: "bme280", Value: 40},
+		},
+		Summaries: []*mpb.Summary{
+			{Metric: "temp", Sensor: "mcp9808", Location: "probe-in", Min: 30, Max: 30, Mean: 30, Count: 1},
+			{Metric: "temp", Sensor: "bme280", Min: 40, Max: 40, Mean: 40, Count: 1},
+		},
+	}
+	if diff := cmp.Diff(got, want, protocmp.Transform()); diff != "" {
+		t.Errorf("Unexpected result (-got +want):\n%s", diff)
+	}
+}
+
+func TestAggregatorTimer(t *testing.T) {
+	published := make(chan *mpb.Measurement, 1)
+	a := newAggregator(20*time.Millisecond, func(m *mpb.Measurement) {
+		published <- m
+	})
+
+	// No sample starts the next window, e.g. because the sensor failed, so the window is
+	// published once it's overdue.
+	now := time.Now()
+	if got := a.add(sample(now, 20, nil)); got != nil {
+		t.Fatalf("add = %v, want nil", got)
+	}
+
+	select {
+	case m := <-published:
+		if got, want := m.GetTimestamp().AsTime(), now.Truncate(20*time.Millisecond); !got.Equal(want) {
+			t.Errorf("got timestamp %v, want %v", got, want)
+		}
+		if got := m.GetSummaries()[0].GetCount(); got != 1 {
+			t.Errorf("got count %d, want 1", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Window wasn't published")
+	}
+
+	// It's published only once.
+	a.flush()
+	select {
+	case m := <-published:
+		t.Errorf("Window was published again: %v", m)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestAggregatorFlush(t *testing.T) {
+	var published []*mpb.Measurement
+	a := newAggregator(time.Hour, func(m *mpb.Measurement) {
+		published = append(published, m)
+	})
+
+	// Nothing to flush.
+	a.flush()
+	if len(published) != 0 {
+		t.Fatalf("got %d published, want 0", len(published))
+	}
+
+	now := time.Now()
+	a.add(sample(now, 20, nil))
+	a.add(sample(now.Add(time.Second), 22, nil))
+	a.flush()
+	if len(published) != 1 {
+		t.Fatalf("got %d published, want 1", len(published))
+	}
+	if got := published[0].GetSummaries()[0].GetCount(); got != 2 {
+		t.Errorf("got count %d, want 2", got)
+	}
+
+	// The flushed window's timer doesn't publish it again.
+	a.mu.Lock()
+	timer := a.timer
+	a.mu.Unlock()
+	if timer != nil {
+		t.Errorf("Timer is still set after flush")
+	}
+}
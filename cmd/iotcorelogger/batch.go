@@ -0,0 +1,90 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	mpb "github.com/mtraver/environmental-sensor/measurementpb"
+)
+
+// batcher packs measurements into batches and publishes each batch when it's full or when
+// its oldest measurement has waited for the maximum delay, whichever comes first. Measurements
+// that are waiting aren't persisted, so they're lost if the program exits without flushing
+// them. SHUTDOWN jobs flush the batch, as does the program when it's killed.
+type batcher struct {
+	max   int
+	delay time.Duration
+
+	// Publishes the measurements in one message.
+	publish func(ms []*mpb.Measurement) error
+
+	mu      sync.Mutex
+	pending []*mpb.Measurement
+	timer   *time.Timer
+	// Incremented each time a batch is published so that a timer that fires after its batch
+	// was published doesn't publish the next batch early.
+	gen int
+}
+
+func newBatcher(max int, delay time.Duration, publish func(ms []*mpb.Measurement) error) *batcher {
+	return &batcher{
+		max:     max,
+		delay:   delay,
+		publish: publish,
+	}
+}
+
+// add adds the measurement to the current batch. If that fills the batch then it's published
+// and add returns true and any error from publishing it. Otherwise add returns false and the
+// batch is published later.
+func (b *batcher) add(m *mpb.Measurement) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.pending = append(b.pending, m)
+	if len(b.pending) >= b.max {
+		return true, b.flushLocked()
+	}
+
+	if b.timer == nil {
+		gen := b.gen
+		b.timer = time.AfterFunc(b.delay, func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			if b.gen != gen {
+				return
+			}
+			if err := b.flushLocked(); err != nil {
+				log.Printf("Failed to publish batch: %v", err)
+			}
+		})
+	}
+
+	return false, nil
+}
+
+// flush publishes the current batch, if there is one.
+func (b *batcher) flush() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.flushLocked()
+}
+
+// flushLocked publishes the current batch and starts a new one. The batch is dropped even if
+// it fails to publish, just as a single measurement would be. b.mu must be held.
+func (b *batcher) flushLocked() error {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+
+	if len(b.pending) == 0 {
+		return nil
+	}
+
+	b.gen++
+	ms := b.pending
+	b.pending = nil
+	return b.publish(ms)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	mpb "github.com/mtraver/environmental-sensor/measurementpb"
+)
+
+func TestBatcherFull(t *testing.T) {
+	var got []int64
+	b := newBatcher(3, time.Hour, func(ms []*mpb.Measurement) error {
+		for _, m := range ms {
+			got = append(got, m.GetTimestamp().GetSeconds())
+		}
+		return nil
+	})
+
+	for i, sec := range []int64{100, 200, 300, 400} {
+		full, err := b.add(queuedMeasurement(sec))
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		if want := i == 2; full != want {
+			t.Errorf("add(%d): got full = %t, want %t", sec, full, want)
+		}
+	}
+
+	if diff := cmp.Diff(got, []int64{100, 200, 300}); diff != "" {
+		t.Errorf("Unexpected result (-got +want):\n%s", diff)
+	}
+
+	if err := b.flush(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(got, []int64{100, 200, 300, 400}); diff != "" {
+		t.Errorf("Unexpected result (-got +want):\n%s", diff)
+	}
+}
+
+func TestBatcherDelay(t *testing.T) {
+	published := make(chan []*mpb.Measurement, 1)
+	b := newBatcher(10, 10*time.Millisecond, func(ms []*mpb.Measurement) error {
+		published <- ms
+		return nil
+	})
+
+	if full, err := b.add(queuedMeasurement(100)); full || err != nil {
+		t.Fatalf("add: got (%t, %v), want (false, nil)", full, err)
+	}
+
+	select {
+	case ms := <-published:
+		if len(ms) != 1 {
+			t.Errorf("got %d measurements, want 1", len(ms))
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("Batch wasn't published after its delay")
+	}
+}
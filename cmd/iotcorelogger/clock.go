@@ -0,0 +1,91 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	mpb "github.com/mtraver/environmental-sensor/measurementpb"
+	tspb "google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// maxHeldMeasurements is the most measurements that a clockGate holds. Once it's reached the
+// oldest are dropped.
+const maxHeldMeasurements = 1000
+
+type heldMeasurement struct {
+	m *mpb.Measurement
+	// When the measurement was taken. Only its monotonic clock reading is meaningful.
+	taken time.Time
+}
+
+// clockGate holds measurements taken before the system clock is synchronized, because a Pi
+// without a real-time clock boots with a stale clock and the measurements' timestamps would
+// be wrong. Once the clock is synchronized it rewrites their timestamps using the monotonic
+// clock, which isn't affected when the wall clock is set, and releases them.
+type clockGate struct {
+	// Reports whether the clock is synchronized. Replaced in tests.
+	synced func() (bool, error)
+
+	mu sync.Mutex
+	// Once the clock has been synchronized it's not checked again.
+	isSynced bool
+	held     []heldMeasurement
+}
+
+func newClockGate() *clockGate {
+	return &clockGate{synced: systemClockSynced}
+}
+
+// check returns the measurements that are ready to be published, oldest first. If the clock
+// isn't synchronized then m, which was taken at the given time, is held and check returns
+// nothing. Otherwise it returns any held measurements and m, with their timestamps rewritten
+// if the clock has just been synchronized.
+// taken must have a monotonic clock reading, i.e. it must come from time.Now.
+func (g *clockGate) check(m *mpb.Measurement, taken time.Time) []*mpb.Measurement {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if !g.isSynced {
+		ok, err := g.synced()
+		if err != nil {
+			// Don't hold measurements forever because the status can't be read.
+			log.Printf("Failed to get clock sync status, assuming it's synchronized: %v", err)
+			ok = true
+		}
+
+		if !ok {
+			if len(g.held) >= maxHeldMeasurements {
+				log.Printf("Holding too many measurements while waiting for clock sync, dropping the oldest")
+				g.held = g.held[1:]
+			}
+			g.held = append(g.held, heldMeasurement{m: m, taken: taken})
+			return nil
+		}
+
+		g.isSynced = true
+		if len(g.held) > 0 {
+			// The clock may have been set while m was being taken, so rewrite its
+			// timestamp too.
+			g.held = append(g.held, heldMeasurement{m: m, taken: taken})
+			return g.releaseLocked()
+		}
+	}
+
+	return []*mpb.Measurement{m}
+}
+
+// releaseLocked rewrites the timestamps of the held measurements and returns them. g.mu must be held.
+func (g *clockGate) releaseLocked() []*mpb.Measurement {
+	now := time.Now()
+	ready := make([]*mpb.Measurement, 0, len(g.held))
+	for _, h := range g.held {
+		// time.Since uses the monotonic clock.
+		h.m.Timestamp = tspb.New(now.Add(-time.Since(h.taken)).UTC())
+		ready = append(ready, h.m)
+	}
+
+	log.Printf("Clock synchronized, rewrote the timestamps of %d held measurements", len(ready))
+	g.held = nil
+	return ready
+}
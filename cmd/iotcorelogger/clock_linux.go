@@ -0,0 +1,16 @@
+package main
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// systemClockSynced reports whether the kernel considers the system clock to be synchronized,
+// e.g. by NTP. This is the same status that timedatectl reports as "System clock synchronized".
+func systemClockSynced() (bool, error) {
+	var tx unix.Timex
+	state, err := unix.Adjtimex(&tx)
+	if err != nil {
+		return false, err
+	}
+	return state != unix.TIME_ERROR, nil
+}
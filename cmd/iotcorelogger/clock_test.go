@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	mpb "github.com/mtraver/environmental-sensor/measurementpb"
+	tspb "google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestClockGate(t *testing.T) {
+	synced := false
+	g := &clockGate{synced: func() (bool, error) { return synced, nil }}
+
+	// The clock is stale, as it is when a Pi without a real-time clock boots.
+	stale := time.Date(1970, time.January, 1, 0, 0, 0, 0, time.UTC)
+	first := &mpb.Measurement{DeviceId: "foo", Timestamp: tspb.New(stale)}
+	if got := g.check(first, time.Now()); len(got) != 0 {
+		t.Fatalf("got %d measurements before sync, want 0", len(got))
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	second := &mpb.Measurement{DeviceId: "foo", Timestamp: tspb.New(stale.Add(20 * time.Millisecond))}
+	if got := g.check(second, time.Now()); len(got) != 0 {
+		t.Fatalf("got %d measurements before sync, want 0", len(got))
+	}
+
+	synced = true
+	third := &mpb.Measurement{DeviceId: "foo", Timestamp: tspb.New(time.Now().UTC())}
+	got := g.check(third, time.Now())
+	if len(got) != 3 {
+		t.Fatalf("got %d measurements after sync, want 3", len(got))
+	}
+
+	// The held measurements are stamped relative to now, in the order they were taken.
+	now := time.Now()
+	for i, m := range got {
+		ts := m.GetTimestamp().AsTime()
+		if ts.Before(now.Add(-time.Minute)) || ts.After(now) {
+			t.Errorf("measurement %d: got timestamp %v, want close to %v", i, ts, now)
+		}
+	}
+	if gap := got[1].GetTimestamp().AsTime().Sub(got[0].GetTimestamp().AsTime()); gap < 20*time.Millisecond {
+		t.Errorf("got %v between held measurements, want at least %v", gap, 20*time.Millisecond)
+	}
+
+	// Once synced the clock isn't checked again and measurements pass straight through.
+	synced = false
+	fourth := &mpb.Measurement{DeviceId: "foo", Timestamp: tspb.New(time.Now().UTC())}
+	if got := g.check(fourth, time.Now()); len(got) != 1 || got[0] != fourth {
+		t.Errorf("got %v after sync, want only the new measurement", got)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mtraver/environmental-sensor/configpb"
+	"github.com/mtraver/environmental-sensor/sensor/discover"
+	"google.golang.org/protobuf/encoding/protojson"
+	"periph.io/x/periph/conn/i2c/i2creg"
+	"periph.io/x/periph/host"
+)
+
+// Serial ports that are probed if none are given. Patterns are expanded with filepath.Glob.
+var defaultSerialPorts = []string{"/dev/ttyUSB*", "/dev/serial0"}
+
+// discoverMain implements the discover subcommand. It scans the I²C bus and serial ports for
+// sensors and prints a config fragment for the ones that are supported. It returns the exit code.
+func discoverMain(args []string) int {
+	fs := flag.NewFlagSet("discover", flag.ContinueOnError)
+	ports := fs.String("serial", strings.Join(defaultSerialPorts, ","), "comma-separated serial ports (or glob patterns) to probe")
+	listen := fs.Duration("listen", 3*time.Second, "how long to listen on each serial port")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if _, err := host.Init(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to initialize periph: %v\n", err)
+		return 1
+	}
+
+	var devices []discover.Device
+	bus, err := i2creg.Open("")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open I²C bus, skipping I²C scan: %v\n", err)
+	} else {
+		devices = append(devices, discover.ScanI2C(bus)...)
+		bus.Close()
+	}
+
+	for _, port := range expandPorts(strings.Split(*ports, ",")) {
+		d, ok, err := discover.ProbeSerial(port, *listen)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to probe %s: %v\n", port, err)
+			continue
+		}
+		if ok {
+			devices = append(devices, d)
+		}
+	}
+
+	config, notes := configFragment(devices)
+	for _, n := range notes {
+		fmt.Fprintln(os.Stderr, n)
+	}
+
+	b, err := protojson.MarshalOptions{Multiline: true}.Marshal(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to marshal config: %v\n", err)
+		return 1
+	}
+	fmt.Println(string(b))
+
+	return 0
+}
+
+// expandPorts expands any glob patterns in ports. Patterns that match nothing are dropped.
+func expandPorts(ports []string) []string {
+	var expanded []string
+	for _, p := range ports {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+
+		matches, err := filepath.Glob(p)
+		if err != nil {
+			// Not a valid pattern, so try it as a literal path.
+			expanded = append(expanded, p)
+			continue
+		}
+		expanded = append(expanded, matches...)
+	}
+	return expanded
+}
+
+// configFragment makes a config containing the supported sensors among devices and the
+// sensor-specific config needed to use them. It also returns a note about each device
+// that was found but can't be used.
+func configFragment(devices []discover.Device) (*configpb.Config, []string) {
+	var config configpb.Config
+	var notes []string
+	for _, d := range devices {
+		if d.Sensor == "" {
+			if d.Identified {
+				notes = append(notes, fmt.Sprintf("Found %v, which isn't supported", d))
+			} else {
+				notes = append(notes, fmt.Sprintf("Found %v", d))
+			}
+			continue
+		}
+
+		switch d.Sensor {
+		case "mcp9808":
+			if config.Mcp9808 != nil {
+				notes = append(notes, fmt.Sprintf("Found %v, but only one MCP9808 is supported", d))
+				continue
+			}
+			config.Mcp9808 = &configpb.MCP9808Config{Address: uint32(d.Addr)}
+		case "sds011":
+			if config.Sds011 != nil {
+				notes = append(notes, fmt.Sprintf("Found %v, but only one SDS011 is supported", d))
+				continue
+			}
+			config.Sds011 = &configpb.SDS011Config{Port: d.Port}
+		}
+
+		config.SupportedSensors = append(config.SupportedSensors, d.Sensor)
+	}
+
+	return &config, notes
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/mtraver/environmental-sensor/configpb"
+	"github.com/mtraver/environmental-sensor/sensor/discover"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+func TestConfigFragment(t *testing.T) {
+	cases := []struct {
+		name      string
+		devices   []discover.Device
+		want      *configpb.Config
+		wantNotes int
+	}{
+		{"none", nil, &configpb.Config{}, 0},
+		{"supported", []discover.Device{
+			{Model: "MCP9808", Sensor: "mcp9808", Addr: 0x19, Identified: true},
+			{Model: "SDS011", Sensor: "sds011", Port: "/dev/ttyUSB1", Identified: true},
+		}, &configpb.Config{
+			SupportedSensors: []string{"mcp9808", "sds011"},
+			Mcp9808:          &configpb.MCP9808Config{Address: 0x19},
+			Sds011:           &configpb.SDS011Config{Port: "/dev/ttyUSB1"},
+		}, 0},
+		{"unsupported", []discover.Device{
+			{Model: "BME280", Addr: 0x76, Identified: true},
+			{Model: "SHT3x?", Addr: 0x44},
+		}, &configpb.Config{}, 2},
+		{"two_mcp9808", []discover.Device{
+			{Model: "MCP9808", Sensor: "mcp9808", Addr: 0x18, Identified: true},
+			{Model: "MCP9808", Sensor: "mcp9808", Addr: 0x1a, Identified: true},
+		}, &configpb.Config{
+			SupportedSensors: []string{"mcp9808"},
+			Mcp9808:          &configpb.MCP9808Config{Address: 0x18},
+		}, 1},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, notes := configFragment(c.devices)
+			if diff := cmp.Diff(got, c.want, protocmp.Transform()); diff != "" {
+				t.Errorf("Unexpected result (-got +want):\n%s", diff)
+			}
+			if len(notes) != c.wantNotes {
+				t.Errorf("got %d notes, want %d: %v", len(notes), c.wantNotes, notes)
+			}
+		})
+	}
+}
@@ -97,6 +97,24 @@ func onConnectionLost(device iotcore.Device, opts *mqtt.ClientOptions) error {
 	return nil
 }
 
+// publishState publishes the JSON encoding of state as the device's state.
+func publishState(client mqtt.Client, device iotcore.Device, state interface{}) error {
+	b, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	waitDur := 10 * time.Second
+	token := client.Publish(device.StateTopic(), 1, false, b)
+	if ok := token.WaitTimeout(waitDur); !ok {
+		return fmt.Errorf("publish timed out after %v", waitDur)
+	} else if token.Error() != nil {
+		return fmt.Errorf("failed to publish: %v", token.Error())
+	}
+
+	return nil
+}
+
 func mqttConnect(device iotcore.Device, caCertsPath string) (mqtt.Client, error) {
 	certsFile, err := os.Open(caCertsPath)
 	if err != nil {
@@ -1,83 +1,300 @@
 package main
 
 import (
+	"context"
+	"crypto/ecdsa"
 	"fmt"
 	"log"
+	"path"
+	"sync"
 	"time"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
 	mpb "github.com/mtraver/environmental-sensor/measurementpb"
 	mpbutil "github.com/mtraver/environmental-sensor/measurementpbutil"
 	"github.com/mtraver/environmental-sensor/sensor"
+	"github.com/mtraver/environmental-sensor/sensor/calibration"
 	"github.com/mtraver/iotcore"
+	cron "github.com/robfig/cron/v3"
 	"google.golang.org/protobuf/proto"
 	tspb "google.golang.org/protobuf/types/known/timestamppb"
 )
 
-type SetupJob struct {
+// Outcomes of publishing the measurement taken by a SENSE job.
+const (
+	publishOK     = "published"
+	publishFailed = "failed"
+	publishDryrun = "dryrun"
+
+	// The measurement was added to the job's aggregation window and nothing was published.
+	publishBuffered = "buffered"
+
+	// The measurement was within the deadbands of the publish policies and wasn't due a heartbeat.
+	publishSkipped = "skipped"
+
+	// The measurement was added to a batch that will be published later.
+	publishBatched = "batched"
+
+	// The measurement is being held until the system clock is synchronized.
+	publishHeld = "held"
+)
+
+// jobResult records what happened in one run of a job.
+type jobResult struct {
+	// The sensors that were run, in order.
 	Sensors []string
+	Errors  []string
+
+	// The outcome of publishing the measurement taken by a SENSE job. Empty if the
+	// job isn't a SENSE job or if it took no measurement.
+	Publish string
 }
 
-func (j SetupJob) Run() {
-	for _, name := range j.Sensors {
+// addError logs the error and records it.
+func (r *jobResult) addError(err error) {
+	log.Print(err)
+	r.Errors = append(r.Errors, err.Error())
+}
+
+func (r *jobResult) merge(other jobResult) {
+	r.Sensors = append(r.Sensors, other.Sensors...)
+	r.Errors = append(r.Errors, other.Errors...)
+	if other.Publish != "" {
+		r.Publish = other.Publish
+	}
+}
+
+// runner is a job that reports what happened when it ran. Jobs stop early, between sensors
+// or steps, when ctx is done. A sensor that's being read can't be interrupted, so a job that
+// times out in the middle of reading one is abandoned (see monitoredJob.Run).
+type runner interface {
+	cron.Job
+	run(ctx context.Context) jobResult
+}
+
+// forEachSensor calls f with each of the named sensors in turn, recording the sensors that
+// were run and any errors in res. It stops early if ctx is done.
+func forEachSensor(ctx context.Context, names []string, res *jobResult, f func(name string, s sensor.Sensor) error) {
+	for _, name := range names {
+		if err := ctx.Err(); err != nil {
+			res.addError(fmt.Errorf("stopped before %q: %v", name, err))
+			return
+		}
+
 		s, err := sensor.Get(name)
 		if err != nil {
-			log.Printf("Error getting sensor %q: %v", name, err)
+			res.addError(fmt.Errorf("error getting sensor %q: %v", name, err))
 			continue
 		}
-		if err := s.Init(); err != nil {
-			log.Printf("Failed to init %q: %v", name, err)
-			continue
+
+		res.Sensors = append(res.Sensors, name)
+		if err := f(name, s); err != nil {
+			res.addError(err)
 		}
 	}
 }
 
-type SenseJob struct {
+type SetupJob struct {
 	Sensors []string
-	Client  mqtt.Client
-	Device  iotcore.Device
-	Dryrun  bool
+}
+
+func (j SetupJob) Run() {
+	j.run(context.Background())
+}
+
+func (j SetupJob) run(ctx context.Context) jobResult {
+	var res jobResult
+	forEachSensor(ctx, j.Sensors, &res, func(name string, s sensor.Sensor) error {
+		if err := s.Init(); err != nil {
+			return fmt.Errorf("failed to init %q: %v", name, err)
+		}
+		return nil
+	})
+	return res
+}
+
+type SenseJob struct {
+	Sensors    []string
+	Client     mqtt.Client
+	Device     iotcore.Device
+	Calibrator *calibration.Calibrator
+	Locations  map[string]string
+	Dryrun     bool
+
+	// If non-nil, measurements are buffered and a summary is published once per window.
+	Aggregator *aggregator
+
+	// If non-nil, only the measurements that pass the filter are published.
+	Filter *publishFilter
+
+	// If non-nil, measurements are published in batches.
+	Batcher *batcher
+
+	// If non-nil, measurements are held until the system clock is synchronized.
+	Clock *clockGate
+
+	// If non-nil, each published measurement is given a sequence number.
+	Sequencer *sequencer
+
+	// If non-nil, measurements are signed with this key and published as SignedBatch messages.
+	SigningKey *ecdsa.PrivateKey
 }
 
 func (j SenseJob) Run() {
-	// Create a Measurement that we'll pass along to each sensor.
-	timepb := tspb.New(time.Now().UTC())
-	if err := timepb.CheckValid(); err != nil {
-		log.Printf("Invalid timestamp: %v", err)
+	j.run(context.Background())
+}
+
+func (j SenseJob) run(ctx context.Context) jobResult {
+	var res jobResult
+	taken := time.Now()
+	m := j.sense(ctx, taken, &res)
+	if m == nil {
+		return res
+	}
+
+	ms := []*mpb.Measurement{m}
+	if j.Clock != nil {
+		ms = j.Clock.check(m, taken)
+		if len(ms) == 0 {
+			res.Publish = publishHeld
+			return res
+		}
+	}
+
+	for _, m := range ms {
+		j.handle(m, &res)
+	}
+
+	return res
+}
+
+// handle aggregates, filters, and publishes the measurement, recording the outcome in res.
+func (j SenseJob) handle(m *mpb.Measurement, res *jobResult) {
+	if j.Aggregator != nil {
+		// Publish the previous window's summary if this measurement starts a new window.
+		m = j.Aggregator.add(m)
+		if m == nil {
+			res.Publish = publishBuffered
+			return
+		}
+	}
+
+	j.deliver(m, res)
+}
+
+// deliver filters and publishes the measurement, recording the outcome in res. Summaries that
+// the aggregator publishes on its own, rather than returning them from add, go straight here.
+func (j SenseJob) deliver(m *mpb.Measurement, res *jobResult) {
+	if j.Filter != nil && !j.Filter.shouldPublish(m) {
+		res.Publish = publishSkipped
 		return
 	}
-	m := mpb.Measurement{
+
+	if j.Dryrun {
+		log.Print(mpbutil.String(*m))
+		res.Publish = publishDryrun
+		return
+	}
+
+	j.number(m, res)
+
+	var err error
+	res.Publish = publishOK
+	if j.Batcher != nil {
+		// The batch is published now only if this measurement filled it.
+		var full bool
+		full, err = j.Batcher.add(m)
+		if !full {
+			res.Publish = publishBatched
+		}
+	} else {
+		err = j.publish(m)
+	}
+
+	if err != nil {
+		res.addError(fmt.Errorf("failed to publish measurement: %v", err))
+		res.Publish = publishFailed
+	}
+}
+
+// number gives the measurement a sequence number, if the job has a sequencer. A measurement
+// that can't be numbered is still published.
+func (j SenseJob) number(m *mpb.Measurement, res *jobResult) {
+	if j.Sequencer == nil {
+		return
+	}
+
+	if err := j.Sequencer.stamp(m); err != nil {
+		res.addError(fmt.Errorf("failed to number measurement: %v", err))
+	}
+}
+
+// sense takes a measurement from each of the job's sensors and combines them into one
+// Measurement timestamped with the given time. It returns nil if no measurements were taken.
+func (j SenseJob) sense(ctx context.Context, now time.Time, res *jobResult) *mpb.Measurement {
+	// Create the Measurement that will hold the values reported by all sensors.
+	timepb := tspb.New(now.UTC())
+	if err := timepb.CheckValid(); err != nil {
+		res.addError(fmt.Errorf("invalid timestamp: %v", err))
+		return nil
+	}
+	m := &mpb.Measurement{
 		DeviceId:  j.Device.DeviceID,
 		Timestamp: timepb,
 	}
 
 	count := 0
-	for _, name := range j.Sensors {
-		s, err := sensor.Get(name)
-		if err != nil {
-			log.Printf("Error getting sensor %q: %v", name, err)
-			continue
+	forEachSensor(ctx, j.Sensors, res, func(name string, s sensor.Sensor) error {
+		// Each sensor senses into its own Measurement so that a sensor can't overwrite
+		// the values reported by another sensor for the same metric.
+		var sm mpb.Measurement
+		if err := s.Sense(&sm); err != nil {
+			return fmt.Errorf("failed to take measurement from %q: %v", name, err)
 		}
-		if err := s.Sense(&m); err != nil {
-			log.Printf("Failed to take measurement from %q: %v", name, err)
-			continue
+		if err := j.Calibrator.Apply(name, &sm); err != nil {
+			return fmt.Errorf("failed to calibrate measurement from %q: %v", name, err)
 		}
+		mpbutil.Merge(m, &sm, name, j.Locations[name])
 		count++
-	}
+		return nil
+	})
 
 	if count <= 0 {
-		log.Print("Took no measurements, will not publish")
-		return
+		res.addError(fmt.Errorf("took no measurements, will not publish"))
+		return nil
 	}
 
-	if j.Dryrun {
-		log.Print(mpbutil.String(m))
-	} else if err := j.publish(&m); err != nil {
-		log.Printf("Failed to publish measurement: %v", err)
+	if a := m.GetTempAlert(); a.GetLower() || a.GetUpper() || a.GetCritical() {
+		log.Printf("Temperature alert: lower=%t upper=%t critical=%t", a.GetLower(), a.GetUpper(), a.GetCritical())
 	}
+
+	return m
 }
 
 func (j SenseJob) publish(m *mpb.Measurement) error {
+	if j.SigningKey != nil {
+		return j.publishBatch([]*mpb.Measurement{m})
+	}
+	return j.publishProto(j.Device.TelemetryTopic(), m)
+}
+
+// publishBatch publishes the measurements in one MeasurementBatch message, or in one
+// SignedBatch message if the job has a signing key.
+func (j SenseJob) publishBatch(ms []*mpb.Measurement) error {
+	if j.SigningKey != nil {
+		sb, err := mpbutil.Sign(ms, j.SigningKey)
+		if err != nil {
+			return err
+		}
+		return j.publishProto(path.Join(j.Device.TelemetryTopic(), mpbutil.SignedSubfolder), sb)
+	}
+
+	return j.publishProto(path.Join(j.Device.TelemetryTopic(), mpbutil.BatchSubfolder), &mpb.MeasurementBatch{
+		Measurements: ms,
+	})
+}
+
+func (j SenseJob) publishProto(topic string, m proto.Message) error {
 	// Marshal to bytes for publication.
 	pbBytes, err := proto.Marshal(m)
 	if err != nil {
@@ -85,7 +302,7 @@ func (j SenseJob) publish(m *mpb.Measurement) error {
 	}
 
 	waitDur := 10 * time.Second
-	token := j.Client.Publish(j.Device.TelemetryTopic(), 1, false, pbBytes)
+	token := j.Client.Publish(topic, 1, false, pbBytes)
 	if ok := token.WaitTimeout(waitDur); !ok {
 		// Timed out.
 		return fmt.Errorf("publish timed out after %v", waitDur)
@@ -99,18 +316,95 @@ func (j SenseJob) publish(m *mpb.Measurement) error {
 
 type ShutdownJob struct {
 	Sensors []string
+
+	// If non-nil, the current batch is published after the sensors are shut down so that
+	// its measurements aren't left waiting while the device is powered off.
+	Batcher *batcher
 }
 
 func (j ShutdownJob) Run() {
-	for _, name := range j.Sensors {
-		s, err := sensor.Get(name)
-		if err != nil {
-			log.Printf("Error getting sensor %q: %v", name, err)
-			continue
-		}
+	j.run(context.Background())
+}
+
+func (j ShutdownJob) run(ctx context.Context) jobResult {
+	var res jobResult
+	forEachSensor(ctx, j.Sensors, &res, func(name string, s sensor.Sensor) error {
 		if err := s.Shutdown(); err != nil {
-			log.Printf("Failed to shut down %q: %v", name, err)
-			continue
+			return fmt.Errorf("failed to shut down %q: %v", name, err)
+		}
+		return nil
+	})
+
+	if j.Batcher != nil {
+		if err := j.Batcher.flush(); err != nil {
+			res.addError(fmt.Errorf("failed to publish batch: %v", err))
 		}
 	}
+	return res
+}
+
+// SequenceStep is a job to run as part of a SequenceJob and how long to wait after running it.
+type SequenceStep struct {
+	Job   runner
+	Delay time.Duration
+}
+
+// SequenceJob runs jobs in order, waiting after each for its delay. There's no wait after
+// the last job.
+type SequenceJob struct {
+	Steps []SequenceStep
+
+	// Replaced in tests. If nil the sequence waits on a timer.
+	sleep func(ctx context.Context, d time.Duration)
+}
+
+func (j SequenceJob) Run() {
+	j.run(context.Background())
+}
+
+func (j SequenceJob) run(ctx context.Context) jobResult {
+	sleep := j.sleep
+	if sleep == nil {
+		sleep = sleepContext
+	}
+
+	var res jobResult
+	for i, step := range j.Steps {
+		if err := ctx.Err(); err != nil {
+			res.addError(fmt.Errorf("stopped before step %d: %v", i, err))
+			break
+		}
+
+		res.merge(step.Job.run(ctx))
+		if i < len(j.Steps)-1 && step.Delay > 0 {
+			sleep(ctx, step.Delay)
+		}
+	}
+
+	return res
+}
+
+// sleepContext waits for the given duration or until ctx is done, whichever comes first.
+func sleepContext(ctx context.Context, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+	case <-ctx.Done():
+	}
+}
+
+// exclusive returns a cron.JobWrapper that prevents the jobs it wraps from running at the
+// same time as each other, so that e.g. a SENSE job can't run in the middle of a sequence
+// that's waiting for a sensor to warm up. A job that's scheduled while another is running
+// waits for it to finish.
+func exclusive(mu *sync.Mutex) cron.JobWrapper {
+	return func(j cron.Job) cron.Job {
+		return cron.FuncJob(func() {
+			mu.Lock()
+			defer mu.Unlock()
+			j.Run()
+		})
+	}
 }
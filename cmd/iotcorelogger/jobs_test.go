@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	mpb "github.com/mtraver/environmental-sensor/measurementpb"
+	cron "github.com/robfig/cron/v3"
+)
+
+// fakeRunner is a job that appends its name to a log when it runs and fails if err is set.
+type fakeRunner struct {
+	name string
+	err  string
+	log  *[]string
+}
+
+func (r fakeRunner) Run() {
+	r.run(context.Background())
+}
+
+func (r fakeRunner) run(ctx context.Context) jobResult {
+	*r.log = append(*r.log, r.name)
+	res := jobResult{Sensors: []string{r.name}}
+	if r.err != "" {
+		res.Errors = []string{r.err}
+	}
+	return res
+}
+
+func TestSequenceJob(t *testing.T) {
+	var got []string
+	j := SequenceJob{
+		Steps: []SequenceStep{
+			{Job: fakeRunner{name: "setup", log: &got}, Delay: 30 * time.Second},
+			{Job: fakeRunner{name: "sense", err: "oops", log: &got}},
+			{Job: fakeRunner{name: "shutdown", log: &got}, Delay: time.Minute},
+		},
+		sleep: func(ctx context.Context, d time.Duration) { got = append(got, "sleep "+d.String()) },
+	}
+	res := j.run(context.Background())
+
+	// There's no delay after the last step.
+	want := []string{"setup", "sleep 30s", "sense", "shutdown"}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("Unexpected result (-got +want):\n%s", diff)
+	}
+
+	wantRes := jobResult{
+		Sensors: []string{"setup", "sense", "shutdown"},
+		Errors:  []string{"oops"},
+	}
+	if diff := cmp.Diff(res, wantRes); diff != "" {
+		t.Errorf("Unexpected result (-got +want):\n%s", diff)
+	}
+}
+
+func TestSequenceJobCanceled(t *testing.T) {
+	var got []string
+	ctx, cancel := context.WithCancel(context.Background())
+	j := SequenceJob{
+		Steps: []SequenceStep{
+			{Job: fakeRunner{name: "setup", log: &got}, Delay: time.Hour},
+			{Job: fakeRunner{name: "sense", log: &got}},
+		},
+		sleep: func(ctx context.Context, d time.Duration) { cancel() },
+	}
+	res := j.run(ctx)
+
+	if diff := cmp.Diff(got, []string{"setup"}); diff != "" {
+		t.Errorf("Unexpected result (-got +want):\n%s", diff)
+	}
+	if len(res.Errors) != 1 {
+		t.Errorf("got errors %v, want 1 error", res.Errors)
+	}
+}
+
+func TestExclusive(t *testing.T) {
+	var mu sync.Mutex
+	started := make(chan bool)
+	release := make(chan bool)
+	first := cron.NewChain(exclusive(&mu)).Then(cron.FuncJob(func() {
+		started <- true
+		<-release
+	}))
+
+	ran := make(chan bool, 1)
+	second := cron.NewChain(exclusive(&mu)).Then(cron.FuncJob(func() { ran <- true }))
+
+	go first.Run()
+	<-started
+	go second.Run()
+
+	select {
+	case <-ran:
+		t.Fatalf("Second job ran while the first was running")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		t.Errorf("Second job didn't run after the first finished")
+	}
+}
+
+func TestShutdownJobFlushesBatch(t *testing.T) {
+	var got []int64
+	var publishErr error
+	b := newBatcher(10, time.Hour, func(ms []*mpb.Measurement) error {
+		for _, m := range ms {
+			got = append(got, m.GetTimestamp().GetSeconds())
+		}
+		return publishErr
+	})
+	job := ShutdownJob{Batcher: b}
+
+	for _, sec := range []int64{100, 200} {
+		if _, err := b.add(queuedMeasurement(sec)); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if res := job.run(context.Background()); len(res.Errors) != 0 {
+		t.Errorf("Unexpected errors: %v", res.Errors)
+	}
+	if diff := cmp.Diff(got, []int64{100, 200}); diff != "" {
+		t.Errorf("Unexpected result (-got +want):\n%s", diff)
+	}
+
+	publishErr = errors.New("broker unreachable")
+	if _, err := b.add(queuedMeasurement(300)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if res := job.run(context.Background()); len(res.Errors) != 1 {
+		t.Errorf("got errors %v, want 1 error", res.Errors)
+	}
+}
@@ -11,13 +11,18 @@ import (
 	"os"
 	"os/signal"
 	"path"
+	"sort"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
 	homedir "github.com/mitchellh/go-homedir"
 	"github.com/mtraver/environmental-sensor/configpb"
+	mpb "github.com/mtraver/environmental-sensor/measurementpb"
 	"github.com/mtraver/environmental-sensor/sensor"
+	"github.com/mtraver/environmental-sensor/sensor/calibration"
 	"github.com/mtraver/environmental-sensor/sensor/dummy"
 	"github.com/mtraver/environmental-sensor/sensor/mcp9808"
 	"github.com/mtraver/environmental-sensor/sensor/sds011"
@@ -32,6 +37,7 @@ var (
 	configFilePath string
 	port           int
 	dryrun         bool
+	once           bool
 )
 
 var (
@@ -46,12 +52,25 @@ var (
 
 	// This is joined with the user's home directory in init.
 	jwtPath = path.Join(dotDir, "iotcorelogger.jwt")
+
+	// The directory in which one-shot mode queues measurements that it couldn't publish.
+	// This is joined with the user's home directory in init.
+	queueDir = path.Join(dotDir, "queue")
+
+	// The file in which the next sequence number for measurements is persisted. This is
+	// joined with the user's home directory in init.
+	sequencePath = path.Join(dotDir, "sequence")
+
+	// The file in which the SDS011's fan-hours are persisted. This is joined with
+	// the user's home directory in init.
+	sds011StatePath = path.Join(dotDir, "sds011.json")
 )
 
 func init() {
 	flag.StringVar(&configFilePath, "config", "", "path to a file containing a JSON-encoded config proto")
 	flag.IntVar(&port, "port", 8080, "port on which the device's web server should listen")
 	flag.BoolVar(&dryrun, "dryrun", false, "set to true to print rather than publish measurements")
+	flag.BoolVar(&once, "once", false, "set to true to set up, read, and shut down supported_sensors once, publish the measurement, and exit")
 
 	// Update directory and file paths by joining them to the user's home directory.
 	home, err := homedir.Dir()
@@ -61,9 +80,12 @@ func init() {
 	dotDir = path.Join(home, dotDir)
 	mqttStoreDir = path.Join(home, mqttStoreDir)
 	jwtPath = path.Join(home, jwtPath)
+	queueDir = path.Join(home, queueDir)
+	sequencePath = path.Join(home, sequencePath)
+	sds011StatePath = path.Join(home, sds011StatePath)
 
 	// Make all directories required by the program.
-	dirs := []string{dotDir, mqttStoreDir}
+	dirs := []string{dotDir, mqttStoreDir, queueDir}
 	for _, dir := range dirs {
 		if err := os.MkdirAll(dir, 0700); err != nil {
 			log.Fatalf("Failed to make dir %s: %v", dir, err)
@@ -81,7 +103,9 @@ func parseFlags() error {
 	return nil
 }
 
-func validateConfig(c configpb.Config) error {
+// validateConfig checks the config for errors. Jobs are required unless the program is
+// running in one-shot mode, in which case they're ignored.
+func validateConfig(c *configpb.Config, once bool) error {
 	if c.DeviceFilePath == "" {
 		return fmt.Errorf("device_file_path must be set")
 	}
@@ -94,10 +118,15 @@ func validateConfig(c configpb.Config) error {
 		return fmt.Errorf("supported_sensors must contain at least one sensor")
 	}
 
-	if len(c.Jobs) == 0 {
+	if len(c.Jobs) == 0 && !once {
 		return fmt.Errorf("at least one job must be given")
 	}
 
+	supported := make(map[string]bool)
+	for _, name := range c.SupportedSensors {
+		supported[name] = true
+	}
+
 	for _, jpb := range c.Jobs {
 		if jpb.Cronspec == "" {
 			return fmt.Errorf("all jobs must set cronspec")
@@ -107,15 +136,219 @@ func validateConfig(c configpb.Config) error {
 			return fmt.Errorf("all jobs must set operation")
 		}
 
-		if len(jpb.Sensors) == 0 {
-			return fmt.Errorf("all jobs must have at least one sensor")
+		if jpb.Operation == configpb.Job_SEQUENCE && len(jpb.Steps) == 0 {
+			return fmt.Errorf("job with cronspec %q must have at least one step", jpb.Cronspec)
+		}
+
+		for _, step := range jobSteps(jpb) {
+			if step.Operation == configpb.Job_INVALID || step.Operation == configpb.Job_SEQUENCE {
+				return fmt.Errorf("job with cronspec %q has a step with operation %v", jpb.Cronspec, step.Operation)
+			}
+
+			if len(step.Sensors) == 0 {
+				return fmt.Errorf("all jobs and steps must have at least one sensor")
+			}
+
+			for _, name := range step.Sensors {
+				if !supported[name] {
+					return fmt.Errorf("job with cronspec %q uses sensor %q, which is not in supported_sensors", jpb.Cronspec, name)
+				}
+			}
+
+			if step.Delay != nil {
+				if err := step.Delay.CheckValid(); err != nil {
+					return fmt.Errorf("job with cronspec %q has a step with a bad delay: %v", jpb.Cronspec, err)
+				}
+				if step.Delay.AsDuration() < 0 {
+					return fmt.Errorf("job with cronspec %q has a step with a negative delay", jpb.Cronspec)
+				}
+			}
+		}
+
+		if jpb.Timeout != nil {
+			if err := jpb.Timeout.CheckValid(); err != nil {
+				return fmt.Errorf("job with cronspec %q has a bad timeout: %v", jpb.Cronspec, err)
+			}
+			if jpb.Timeout.AsDuration() < 0 {
+				return fmt.Errorf("job with cronspec %q has a negative timeout", jpb.Cronspec)
+			}
+		}
+
+		if jpb.AggregationWindow != nil {
+			if err := jpb.AggregationWindow.CheckValid(); err != nil {
+				return fmt.Errorf("job with cronspec %q has a bad aggregation_window: %v", jpb.Cronspec, err)
+			}
+			if jpb.AggregationWindow.AsDuration() <= 0 {
+				return fmt.Errorf("job with cronspec %q has a non-positive aggregation_window", jpb.Cronspec)
+			}
+
+			senses := false
+			for _, step := range jobSteps(jpb) {
+				if step.Operation == configpb.Job_SENSE {
+					senses = true
+				}
+			}
+			if !senses {
+				return fmt.Errorf("job with cronspec %q sets aggregation_window but doesn't sense", jpb.Cronspec)
+			}
+		}
+	}
+
+	for _, cpb := range c.Calibrations {
+		if !supported[cpb.Sensor] {
+			return fmt.Errorf("calibration given for sensor %q, which is not in supported_sensors", cpb.Sensor)
+		}
+	}
+
+	for name := range c.Locations {
+		if !supported[name] {
+			return fmt.Errorf("location given for sensor %q, which is not in supported_sensors", name)
+		}
+	}
+
+	if err := validatePublishPolicies(c.PublishPolicies); err != nil {
+		return err
+	}
+
+	if b := c.Batch; b != nil {
+		if b.MaxMeasurements < 2 {
+			return fmt.Errorf("batch.max_measurements must be at least 2")
+		}
+		if b.MaxDelay == nil {
+			return fmt.Errorf("batch.max_delay must be set")
+		}
+		if err := b.MaxDelay.CheckValid(); err != nil {
+			return fmt.Errorf("batch.max_delay is bad: %v", err)
+		}
+		if b.MaxDelay.AsDuration() <= 0 {
+			return fmt.Errorf("batch.max_delay must be positive")
 		}
 	}
 
 	return nil
 }
 
+// jobSteps returns the steps of a SEQUENCE job. Any other job is returned as a single step
+// so that all jobs can be handled the same way.
+func jobSteps(jpb *configpb.Job) []*configpb.Step {
+	if jpb.Operation == configpb.Job_SEQUENCE {
+		return jpb.Steps
+	}
+
+	return []*configpb.Step{{Operation: jpb.Operation, Sensors: jpb.Sensors}}
+}
+
+// exitHooks are functions to run when the program is killed. They run in the reverse of the
+// order they were added, like deferred calls.
+type exitHooks struct {
+	mu    sync.Mutex
+	hooks []func()
+}
+
+func (h *exitHooks) add(f func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.hooks = append(h.hooks, f)
+}
+
+func (h *exitHooks) run() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i := len(h.hooks) - 1; i >= 0; i-- {
+		h.hooks[i]()
+	}
+}
+
+// newJob makes a job that runs the given operation on the given sensors. The SENSE job is
+// made by copying sense and setting its sensors. If window is non-zero the SENSE job gets
+// its own aggregator with that window, which is flushed on exit. The SHUTDOWN job flushes
+// sense's batcher, if it has one.
+func newJob(op configpb.Job_Operation, sensors []string, sense SenseJob, window time.Duration, onExit *exitHooks) (runner, error) {
+	switch op {
+	case configpb.Job_SETUP:
+		return SetupJob{Sensors: sensors}, nil
+	case configpb.Job_SENSE:
+		sense.Sensors = sensors
+		if window > 0 {
+			// The errors in publishing a summary that the aggregator publishes itself are
+			// logged but not recorded in any job's results.
+			sense.Aggregator = newAggregator(window, func(m *mpb.Measurement) {
+				var res jobResult
+				sense.deliver(m, &res)
+			})
+			onExit.add(sense.Aggregator.flush)
+		}
+		return sense, nil
+	case configpb.Job_SHUTDOWN:
+		return ShutdownJob{Sensors: sensors, Batcher: sense.Batcher}, nil
+	}
+
+	return nil, fmt.Errorf("unknown job type %v", op)
+}
+
+// overlapWrapper returns the cron.JobWrapper that implements the job's overlap policy.
+func overlapWrapper(jpb *configpb.Job, logger cron.Logger) cron.JobWrapper {
+	policy := jpb.Overlap
+	if policy == configpb.Job_OVERLAP_DEFAULT {
+		policy = configpb.Job_OVERLAP_QUEUE
+		if jpb.Operation == configpb.Job_SEQUENCE {
+			policy = configpb.Job_OVERLAP_SKIP
+		}
+	}
+
+	if policy == configpb.Job_OVERLAP_SKIP {
+		return cron.SkipIfStillRunning(logger)
+	}
+	return cron.DelayIfStillRunning(logger)
+}
+
+// validateJobs checks the config's jobs against the registered sensors. It returns an error
+// if a job uses a sensor that isn't registered. It returns a warning for each metric that's
+// reported by more than one sensor in the same SENSE job, because only the first sensor's
+// value is put in the metric's field. The others are available only in readings.
+func validateJobs(jobs []*configpb.Job) ([]string, error) {
+	var warnings []string
+	for _, jpb := range jobs {
+		for _, step := range jobSteps(jpb) {
+			writers := make(map[string][]string)
+			for _, name := range step.Sensors {
+				caps, ok, err := sensor.Describe(name)
+				if err != nil {
+					return nil, fmt.Errorf("job with cronspec %q: %v", jpb.Cronspec, err)
+				}
+
+				if !ok || step.Operation != configpb.Job_SENSE {
+					continue
+				}
+
+				for _, metric := range caps.Metrics {
+					writers[metric] = append(writers[metric], name)
+				}
+			}
+
+			metrics := make([]string, 0, len(writers))
+			for metric := range writers {
+				metrics = append(metrics, metric)
+			}
+			sort.Strings(metrics)
+
+			for _, metric := range metrics {
+				if names := writers[metric]; len(names) > 1 {
+					warnings = append(warnings, fmt.Sprintf("job with cronspec %q: metric %q is reported by more than one sensor (%s); field %q will hold the value from %q and all values will be in readings",
+						jpb.Cronspec, metric, strings.Join(names, ", "), metric, names[0]))
+				}
+			}
+		}
+	}
+
+	return warnings, nil
+}
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "discover" {
+		os.Exit(discoverMain(os.Args[2:]))
+	}
+
 	if err := parseFlags(); err != nil {
 		fmt.Printf("argument error: %v\n", err)
 		os.Exit(2)
@@ -130,7 +363,11 @@ func main() {
 	if err := protojson.Unmarshal(b, &config); err != nil {
 		log.Fatal(err)
 	}
-	if err := validateConfig(config); err != nil {
+	if err := validateConfig(&config, once); err != nil {
+		log.Fatalf("Invalid config: %v", err)
+	}
+	calibrator, err := calibration.New(config.Calibrations)
+	if err != nil {
 		log.Fatalf("Invalid config: %v", err)
 	}
 
@@ -142,25 +379,35 @@ func main() {
 
 	// Connect to IoT Core over MQTT. Make a dummy client if we're not actually
 	// going to connect.
+	var onExit exitHooks
 	client := mqtt.NewClient(mqtt.NewClientOptions())
 	if !dryrun {
 		client, err = mqttConnect(device, config.CaCertsPath)
-		if err != nil {
+		if err != nil && !once {
 			log.Fatal(err)
+		} else if err != nil {
+			// In one-shot mode the measurement is queued for the next run.
+			log.Printf("Failed to connect: %v", err)
+			client = nil
+		} else {
+			// If the program is killed, disconnect from the MQTT server. This is added first
+			// so that it runs last, after buffered measurements have been published.
+			onExit.add(func() {
+				client.Disconnect(250)
+				time.Sleep(500 * time.Millisecond)
+			})
 		}
-
-		// If the program is killed, disconnect from the MQTT server.
-		c := make(chan os.Signal, 2)
-		signal.Notify(c, os.Interrupt, syscall.SIGTERM)
-		go func() {
-			<-c
-			log.Println("Cleaning up...")
-			client.Disconnect(250)
-			time.Sleep(500 * time.Millisecond)
-			os.Exit(1)
-		}()
 	}
 
+	c := make(chan os.Signal, 2)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-c
+		log.Println("Cleaning up...")
+		onExit.run()
+		os.Exit(1)
+	}()
+
 	// Initialize periph.
 	if _, err := host.Init(); err != nil {
 		log.Fatalf("Failed to initialize periph: %v", err)
@@ -177,13 +424,22 @@ func main() {
 	for _, name := range config.SupportedSensors {
 		switch name {
 		case "mcp9808":
-			s, err := mcp9808.New(bus)
+			opts, err := mcp9808.OptsFromConfig(config.Mcp9808)
+			if err != nil {
+				log.Fatalf("Invalid MCP9808 config: %v", err)
+			}
+			s, err := mcp9808.New(bus, opts)
 			if err != nil {
 				log.Fatalf("Failed to initialize MCP9808: %v", err)
 			}
 			sensor.Register("mcp9808", s)
 		case "sds011":
-			s, err := sds011.New("/dev/ttyUSB0")
+			port, opts, err := sds011.OptsFromConfig(config.Sds011)
+			if err != nil {
+				log.Fatalf("Invalid SDS011 config: %v", err)
+			}
+			opts.StatePath = sds011StatePath
+			s, err := sds011.New(port, opts)
 			if err != nil {
 				log.Fatalf("Failed to initialize SDS011: %v", err)
 			}
@@ -195,33 +451,122 @@ func main() {
 		}
 	}
 
-	// Schedule jobs defined in the config.
+	warnings, err := validateJobs(config.Jobs)
+	if err != nil {
+		log.Fatalf("Invalid config: %v", err)
+	}
+	for _, w := range warnings {
+		log.Printf("Warning: %s", w)
+	}
+
+	sequencer, err := newSequencer(sequencePath, bootID())
+	if err != nil {
+		log.Fatalf("Failed to load sequence number: %v", err)
+	}
+	releaseSequence := func() {
+		if err := sequencer.release(); err != nil {
+			log.Printf("Failed to save sequence number: %v", err)
+		}
+	}
+	// This is added before the jobs' aggregators so that it runs after them, once they've
+	// numbered the summaries that they flush.
+	onExit.add(releaseSequence)
+
+	// The template for SENSE jobs. Each job sets its own sensors.
+	sense := SenseJob{
+		Client:     client,
+		Device:     device,
+		Calibrator: calibrator,
+		Locations:  config.Locations,
+		Dryrun:     dryrun,
+		Sequencer:  sequencer,
+	}
+
+	if config.SignMeasurements {
+		key, err := loadSigningKey(device.PrivKeyPath)
+		if err != nil {
+			log.Fatalf("Failed to load signing key: %v", err)
+		}
+		sense.SigningKey = key
+	}
+
+	if once {
+		sense.Sensors = config.SupportedSensors
+		code := runOnce(config.SupportedSensors, sense, queue{dir: queueDir}, int(config.GetBatch().GetMaxMeasurements()))
+		releaseSequence()
+		bus.Close()
+		os.Exit(code)
+	}
+
+	// One-shot mode publishes every measurement because it doesn't keep the last published
+	// values between runs. SENSE jobs share the filter.
+	filter := newPublishFilter(config.PublishPolicies)
+	sense.Filter = filter
+
+	// Hold measurements taken before the clock is synchronized so that their timestamps
+	// can be corrected.
+	sense.Clock = newClockGate()
+
+	// SENSE jobs also share the batcher so that they fill the same batches.
+	if b := config.Batch; b != nil {
+		sense.Batcher = newBatcher(int(b.MaxMeasurements), b.MaxDelay.AsDuration(), sense.publishBatch)
+
+		// This is added before the jobs' aggregators so that it runs after them, publishing
+		// the summaries that they flush into the batch.
+		batcher := sense.Batcher
+		onExit.add(func() {
+			if err := batcher.flush(); err != nil {
+				log.Printf("Failed to publish batch: %v", err)
+			}
+		})
+	}
+
+	// Keep a history of each job's runs and report jobs that fail repeatedly in the device's state.
+	monitor := newJobMonitor(int(config.JobHistorySize), int(config.JobFailureThreshold), func(state deviceState) {
+		log.Printf("Failing jobs changed: %+v", state.FailingJobs)
+		if dryrun {
+			return
+		}
+		if err := publishState(client, device, state); err != nil {
+			log.Printf("Failed to publish device state: %v", err)
+		}
+	})
+
+	// Schedule jobs defined in the config. Jobs don't run at the same time as each other,
+	// except that a job that has timed out no longer holds the others up, and each job's
+	// overlap policy determines what happens if it's scheduled while its previous run is
+	// still going.
+	var mu sync.Mutex
+	cronLogger := cron.VerbosePrintfLogger(log.New(os.Stderr, "cron: ", log.LstdFlags))
 	cr := cron.New(cron.WithSeconds())
-	for _, jpb := range config.Jobs {
-		switch jpb.Operation {
-		case configpb.Job_INVALID:
-			log.Fatalf("All jobs must set operation, got %v", configpb.Job_Operation_name[int32(jpb.Operation)])
-		case configpb.Job_SETUP:
-			log.Printf("Adding %s job with cronspec %q", configpb.Job_Operation_name[int32(jpb.Operation)], jpb.Cronspec)
-			cr.AddJob(jpb.Cronspec, SetupJob{
-				Sensors: jpb.Sensors,
-			})
-		case configpb.Job_SENSE:
-			log.Printf("Adding %s job with cronspec %q", configpb.Job_Operation_name[int32(jpb.Operation)], jpb.Cronspec)
-			cr.AddJob(jpb.Cronspec, SenseJob{
-				Sensors: jpb.Sensors,
-				Client:  client,
-				Device:  device,
-				Dryrun:  dryrun,
-			})
-		case configpb.Job_SHUTDOWN:
-			log.Printf("Adding %s job with cronspec %q", configpb.Job_Operation_name[int32(jpb.Operation)], jpb.Cronspec)
-			cr.AddJob(jpb.Cronspec, ShutdownJob{
-				Sensors: jpb.Sensors,
-			})
-		default:
-			log.Fatalf("Unknown job type %v", jpb.Operation)
+	for i, jpb := range config.Jobs {
+		op := configpb.Job_Operation_name[int32(jpb.Operation)]
+		log.Printf("Adding %s job with cronspec %q", op, jpb.Cronspec)
+
+		var job runner
+		if jpb.Operation != configpb.Job_SEQUENCE {
+			var err error
+			job, err = newJob(jpb.Operation, jpb.Sensors, sense, jpb.AggregationWindow.AsDuration(), &onExit)
+			if err != nil {
+				log.Fatal(err)
+			}
+		} else {
+			var seq SequenceJob
+			for _, step := range jpb.Steps {
+				stepJob, err := newJob(step.Operation, step.Sensors, sense, jpb.AggregationWindow.AsDuration(), &onExit)
+				if err != nil {
+					log.Fatal(err)
+				}
+				seq.Steps = append(seq.Steps, SequenceStep{
+					Job:   stepJob,
+					Delay: step.Delay.AsDuration(),
+				})
+			}
+			job = seq
 		}
+
+		monitored := monitor.wrap(i, op, jpb.Cronspec, job, jpb.Timeout.AsDuration())
+		cr.AddJob(jpb.Cronspec, cron.NewChain(overlapWrapper(jpb, cronLogger), exclusive(&mu)).Then(monitored))
 	}
 	cr.Start()
 
@@ -229,6 +574,11 @@ func main() {
 	http.Handle("/", indexHandler{
 		device: device,
 	})
+	http.Handle("/status", statusHandler{
+		device:  device,
+		jobs:    monitor,
+		publish: filter,
+	})
 	if err := http.ListenAndServe(fmt.Sprintf(":%v", port), nil); err != nil {
 		log.Fatal(err)
 	}
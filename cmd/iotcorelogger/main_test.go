@@ -0,0 +1,194 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mtraver/environmental-sensor/configpb"
+	mpb "github.com/mtraver/environmental-sensor/measurementpb"
+	"github.com/mtraver/environmental-sensor/sensor"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// fakeSensor is a sensor that describes itself with the given capabilities.
+type fakeSensor struct {
+	caps sensor.Capabilities
+}
+
+func (s fakeSensor) Init() error                    { return nil }
+func (s fakeSensor) Sense(m *mpb.Measurement) error { return nil }
+func (s fakeSensor) Shutdown() error                { return nil }
+
+func (s fakeSensor) Capabilities() sensor.Capabilities {
+	return s.caps
+}
+
+func init() {
+	sensor.Register("test-temp-a", fakeSensor{sensor.Capabilities{Metrics: []string{"temp"}}})
+	sensor.Register("test-temp-b", fakeSensor{sensor.Capabilities{Metrics: []string{"temp", "rh"}}})
+	sensor.Register("test-pm", fakeSensor{sensor.Capabilities{Metrics: []string{"pm25", "pm10"}}})
+}
+
+func validConfig() *configpb.Config {
+	return &configpb.Config{
+		DeviceFilePath:   "device.json",
+		CaCertsPath:      "roots.pem",
+		SupportedSensors: []string{"test-temp-a", "test-pm"},
+		Jobs: []*configpb.Job{
+			{
+				Cronspec:  "0 * * * * *",
+				Operation: configpb.Job_SENSE,
+				Sensors:   []string{"test-temp-a", "test-pm"},
+			},
+		},
+	}
+}
+
+func sequenceJob() *configpb.Job {
+	return &configpb.Job{
+		Cronspec:  "0 */5 * * * *",
+		Operation: configpb.Job_SEQUENCE,
+		Steps: []*configpb.Step{
+			{Operation: configpb.Job_SETUP, Sensors: []string{"test-pm"}, Delay: durationpb.New(30 * time.Second)},
+			{Operation: configpb.Job_SENSE, Sensors: []string{"test-temp-a", "test-pm"}},
+			{Operation: configpb.Job_SHUTDOWN, Sensors: []string{"test-pm"}},
+		},
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	cases := []struct {
+		name   string
+		modify func(c *configpb.Config)
+		once   bool
+		valid  bool
+	}{
+		{"valid", func(c *configpb.Config) {}, false, true},
+		{"valid_once", func(c *configpb.Config) {}, true, true},
+		{"no_jobs_once", func(c *configpb.Config) { c.Jobs = nil }, true, true},
+		{"no_device_file", func(c *configpb.Config) { c.DeviceFilePath = "" }, false, false},
+		{"no_jobs", func(c *configpb.Config) { c.Jobs = nil }, false, false},
+		{"no_operation", func(c *configpb.Config) { c.Jobs[0].Operation = configpb.Job_INVALID }, false, false},
+		{"unsupported_job_sensor", func(c *configpb.Config) { c.Jobs[0].Sensors = []string{"test-temp-b"} }, false, false},
+		{"unsupported_calibration_sensor", func(c *configpb.Config) {
+			c.Calibrations = []*configpb.Calibration{{Sensor: "test-temp-b", Metric: "temp"}}
+		}, false, false},
+		{"sequence", func(c *configpb.Config) {
+			c.Jobs = []*configpb.Job{sequenceJob()}
+		}, false, true},
+		{"sequence_no_steps", func(c *configpb.Config) {
+			c.Jobs = []*configpb.Job{{Cronspec: "0 * * * * *", Operation: configpb.Job_SEQUENCE}}
+		}, false, false},
+		{"sequence_nested", func(c *configpb.Config) {
+			j := sequenceJob()
+			j.Steps[1].Operation = configpb.Job_SEQUENCE
+			c.Jobs = []*configpb.Job{j}
+		}, false, false},
+		{"sequence_unsupported_sensor", func(c *configpb.Config) {
+			j := sequenceJob()
+			j.Steps[1].Sensors = []string{"test-temp-b"}
+			c.Jobs = []*configpb.Job{j}
+		}, false, false},
+		{"sequence_negative_delay", func(c *configpb.Config) {
+			j := sequenceJob()
+			j.Steps[0].Delay = durationpb.New(-time.Second)
+			c.Jobs = []*configpb.Job{j}
+		}, false, false},
+		{"timeout", func(c *configpb.Config) { c.Jobs[0].Timeout = durationpb.New(time.Minute) }, false, true},
+		{"negative_timeout", func(c *configpb.Config) { c.Jobs[0].Timeout = durationpb.New(-time.Minute) }, false, false},
+		{"aggregation_window", func(c *configpb.Config) { c.Jobs[0].AggregationWindow = durationpb.New(time.Minute) }, false, true},
+		{"zero_aggregation_window", func(c *configpb.Config) { c.Jobs[0].AggregationWindow = durationpb.New(0) }, false, false},
+		{"sequence_aggregation_window", func(c *configpb.Config) {
+			j := sequenceJob()
+			j.AggregationWindow = durationpb.New(time.Hour)
+			c.Jobs = []*configpb.Job{j}
+		}, false, true},
+		{"setup_aggregation_window", func(c *configpb.Config) {
+			c.Jobs[0].Operation = configpb.Job_SETUP
+			c.Jobs[0].AggregationWindow = durationpb.New(time.Minute)
+		}, false, false},
+		{"publish_policy", func(c *configpb.Config) {
+			c.PublishPolicies = map[string]*configpb.PublishPolicy{"temp": {Deadband: 0.25, Heartbeat: durationpb.New(time.Hour)}}
+		}, false, true},
+		{"publish_policy_unknown_metric", func(c *configpb.Config) {
+			c.PublishPolicies = map[string]*configpb.PublishPolicy{"device_id": {Heartbeat: durationpb.New(time.Hour)}}
+		}, false, false},
+		{"publish_policy_no_heartbeat", func(c *configpb.Config) {
+			c.PublishPolicies = map[string]*configpb.PublishPolicy{"temp": {Deadband: 0.25}}
+		}, false, false},
+		{"publish_policy_negative_deadband", func(c *configpb.Config) {
+			c.PublishPolicies = map[string]*configpb.PublishPolicy{"temp": {Deadband: -1, Heartbeat: durationpb.New(time.Hour)}}
+		}, false, false},
+		{"batch", func(c *configpb.Config) {
+			c.Batch = &configpb.Batch{MaxMeasurements: 10, MaxDelay: durationpb.New(time.Hour)}
+		}, false, true},
+		{"batch_too_small", func(c *configpb.Config) {
+			c.Batch = &configpb.Batch{MaxMeasurements: 1, MaxDelay: durationpb.New(time.Hour)}
+		}, false, false},
+		{"batch_no_delay", func(c *configpb.Config) { c.Batch = &configpb.Batch{MaxMeasurements: 10} }, false, false},
+		{"unsupported_location_sensor", func(c *configpb.Config) {
+			c.Locations = map[string]string{"test-temp-b": "probe-in"}
+		}, false, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			config := validConfig()
+			c.modify(config)
+
+			err := validateConfig(config, c.once)
+			if valid := err == nil; valid != c.valid {
+				t.Errorf("got valid = %t, want %t (err = %v)", valid, c.valid, err)
+			}
+		})
+	}
+}
+
+func TestValidateJobs(t *testing.T) {
+	cases := []struct {
+		name         string
+		jobs         []*configpb.Job
+		wantWarnings int
+		valid        bool
+	}{
+		{"no_overlap", []*configpb.Job{
+			{Operation: configpb.Job_SENSE, Sensors: []string{"test-temp-a", "test-pm"}},
+		}, 0, true},
+		{"overlap", []*configpb.Job{
+			{Operation: configpb.Job_SENSE, Sensors: []string{"test-temp-a", "test-temp-b"}},
+		}, 1, true},
+		{"overlap_not_sense", []*configpb.Job{
+			{Operation: configpb.Job_SETUP, Sensors: []string{"test-temp-a", "test-temp-b"}},
+		}, 0, true},
+		{"overlap_across_jobs", []*configpb.Job{
+			{Operation: configpb.Job_SENSE, Sensors: []string{"test-temp-a"}},
+			{Operation: configpb.Job_SENSE, Sensors: []string{"test-temp-b"}},
+		}, 0, true},
+		{"overlap_in_sequence", []*configpb.Job{
+			{Operation: configpb.Job_SEQUENCE, Steps: []*configpb.Step{
+				{Operation: configpb.Job_SETUP, Sensors: []string{"test-temp-a", "test-temp-b"}},
+				{Operation: configpb.Job_SENSE, Sensors: []string{"test-temp-a", "test-temp-b"}},
+			}},
+		}, 1, true},
+		{"unregistered_in_sequence", []*configpb.Job{
+			{Operation: configpb.Job_SEQUENCE, Steps: []*configpb.Step{
+				{Operation: configpb.Job_SENSE, Sensors: []string{"test-nope"}},
+			}},
+		}, 0, false},
+		{"unregistered", []*configpb.Job{
+			{Operation: configpb.Job_SENSE, Sensors: []string{"test-nope"}},
+		}, 0, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			warnings, err := validateJobs(c.jobs)
+			if valid := err == nil; valid != c.valid {
+				t.Fatalf("got valid = %t, want %t (err = %v)", valid, c.valid, err)
+			}
+			if len(warnings) != c.wantWarnings {
+				t.Errorf("got %d warnings, want %d: %v", len(warnings), c.wantWarnings, warnings)
+			}
+		})
+	}
+}
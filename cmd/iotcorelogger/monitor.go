@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultJobHistorySize      = 20
+	defaultJobFailureThreshold = 3
+)
+
+// jobRun is the record of one run of a job.
+type jobRun struct {
+	Start           time.Time `json:"start"`
+	DurationSeconds float64   `json:"duration_seconds"`
+	Sensors         []string  `json:"sensors"`
+	Errors          []string  `json:"errors,omitempty"`
+	Publish         string    `json:"publish,omitempty"`
+	TimedOut        bool      `json:"timed_out,omitempty"`
+}
+
+func (r jobRun) failed() bool {
+	return len(r.Errors) > 0
+}
+
+// jobStatus describes a scheduled job and its recent runs, oldest first.
+type jobStatus struct {
+	Index               int      `json:"index"`
+	Operation           string   `json:"operation"`
+	Cronspec            string   `json:"cronspec"`
+	ConsecutiveFailures int      `json:"consecutive_failures"`
+	Runs                []jobRun `json:"runs"`
+}
+
+// deviceState is reported to IoT Core as the device's state. It lists the jobs that have
+// failed at least the threshold number of times in a row.
+type deviceState struct {
+	FailingJobs []failingJob `json:"failing_jobs"`
+}
+
+type failingJob struct {
+	Index               int    `json:"index"`
+	Operation           string `json:"operation"`
+	Cronspec            string `json:"cronspec"`
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+	LastError           string `json:"last_error"`
+}
+
+// jobMonitor keeps the history of each job's runs and reports jobs that fail repeatedly.
+type jobMonitor struct {
+	historySize int
+	threshold   int
+
+	// Called with the new state whenever the set of failing jobs changes.
+	report func(s deviceState)
+
+	mu      sync.Mutex
+	jobs    []*monitoredJob
+	failing map[int]bool
+}
+
+func newJobMonitor(historySize, threshold int, report func(s deviceState)) *jobMonitor {
+	if historySize <= 0 {
+		historySize = defaultJobHistorySize
+	}
+	if threshold <= 0 {
+		threshold = defaultJobFailureThreshold
+	}
+
+	return &jobMonitor{
+		historySize: historySize,
+		threshold:   threshold,
+		report:      report,
+		failing:     make(map[int]bool),
+	}
+}
+
+// monitoredJob runs a job with an optional timeout and records each run.
+type monitoredJob struct {
+	monitor   *jobMonitor
+	index     int
+	operation string
+	cronspec  string
+	job       runner
+	timeout   time.Duration
+
+	// These are guarded by monitor.mu. runs is a ring buffer and next is the index at
+	// which the next run will be written. abandoned is set while a run that timed out
+	// hasn't returned.
+	runs                []jobRun
+	next                int
+	consecutiveFailures int
+	abandoned           bool
+}
+
+// wrap returns a job that runs job and records its runs. index identifies the job in the
+// config. If timeout is non-zero the job is stopped once it's exceeded (see runner).
+func (m *jobMonitor) wrap(index int, operation, cronspec string, job runner, timeout time.Duration) *monitoredJob {
+	j := &monitoredJob{
+		monitor:   m,
+		index:     index,
+		operation: operation,
+		cronspec:  cronspec,
+		job:       job,
+		timeout:   timeout,
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.jobs = append(m.jobs, j)
+	return j
+}
+
+// Run runs the job and records the run. If the job hasn't returned when its timeout is
+// exceeded, e.g. because a sensor has hung, it's recorded as timed out and abandoned: Run
+// returns, so that other jobs aren't held up, and the job is left running in the background.
+// The job isn't run again until the abandoned run returns.
+func (j *monitoredJob) Run() {
+	ctx := context.Background()
+	if j.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, j.timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	j.monitor.mu.Lock()
+	abandoned := j.abandoned
+	j.monitor.mu.Unlock()
+	if abandoned {
+		j.monitor.record(j, jobRun{
+			Start:    start,
+			Errors:   []string{"not run because a previous run timed out and hasn't returned"},
+			TimedOut: true,
+		})
+		return
+	}
+
+	// The result is sent with monitor.mu held so that, with it held, either the result has
+	// been sent or abandoned will be cleared when the job returns.
+	done := make(chan jobResult, 1)
+	go func() {
+		res := j.job.run(ctx)
+
+		j.monitor.mu.Lock()
+		defer j.monitor.mu.Unlock()
+		j.abandoned = false
+		done <- res
+	}()
+
+	var res jobResult
+	select {
+	case res = <-done:
+	case <-ctx.Done():
+		j.monitor.mu.Lock()
+		select {
+		case res = <-done:
+			// The job returned just as it timed out.
+		default:
+			j.abandoned = true
+			log.Printf("%s job %d timed out and was abandoned while still running", j.operation, j.index)
+		}
+		j.monitor.mu.Unlock()
+	}
+
+	run := jobRun{
+		Start:           start,
+		DurationSeconds: time.Since(start).Seconds(),
+		Sensors:         res.Sensors,
+		Errors:          res.Errors,
+		Publish:         res.Publish,
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		run.TimedOut = true
+		run.Errors = append(run.Errors, fmt.Sprintf("timed out after %v", j.timeout))
+	}
+
+	j.monitor.record(j, run)
+}
+
+// record adds the run to the job's history and reports the device's state if the set
+// of failing jobs has changed.
+func (m *jobMonitor) record(j *monitoredJob, run jobRun) {
+	m.mu.Lock()
+
+	if len(j.runs) < m.historySize {
+		j.runs = append(j.runs, run)
+	} else {
+		j.runs[j.next] = run
+	}
+	j.next = (j.next + 1) % m.historySize
+
+	if run.failed() {
+		j.consecutiveFailures++
+	} else {
+		j.consecutiveFailures = 0
+	}
+
+	isFailing := j.consecutiveFailures >= m.threshold
+	changed := isFailing != m.failing[j.index]
+	if isFailing {
+		m.failing[j.index] = true
+	} else {
+		delete(m.failing, j.index)
+	}
+
+	var state deviceState
+	if changed {
+		state = m.stateLocked()
+	}
+	m.mu.Unlock()
+
+	if changed && m.report != nil {
+		m.report(state)
+	}
+}
+
+// stateLocked returns the device's state. m.mu must be held.
+func (m *jobMonitor) stateLocked() deviceState {
+	state := deviceState{
+		FailingJobs: []failingJob{},
+	}
+
+	for _, j := range m.jobs {
+		if !m.failing[j.index] {
+			continue
+		}
+
+		last := j.runs[(j.next+len(j.runs)-1)%len(j.runs)]
+		state.FailingJobs = append(state.FailingJobs, failingJob{
+			Index:               j.index,
+			Operation:           j.operation,
+			Cronspec:            j.cronspec,
+			ConsecutiveFailures: j.consecutiveFailures,
+			LastError:           last.Errors[len(last.Errors)-1],
+		})
+	}
+
+	sort.Slice(state.FailingJobs, func(a, b int) bool {
+		return state.FailingJobs[a].Index < state.FailingJobs[b].Index
+	})
+	return state
+}
+
+// status returns the status of each job, in the order in which they were wrapped.
+func (m *jobMonitor) status() []jobStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	statuses := make([]jobStatus, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		// Unroll the ring buffer so that runs are oldest first.
+		runs := make([]jobRun, 0, len(j.runs))
+		if len(j.runs) == m.historySize {
+			runs = append(runs, j.runs[j.next:]...)
+			runs = append(runs, j.runs[:j.next]...)
+		} else {
+			runs = append(runs, j.runs...)
+		}
+
+		statuses = append(statuses, jobStatus{
+			Index:               j.index,
+			Operation:           j.operation,
+			Cronspec:            j.cronspec,
+			ConsecutiveFailures: j.consecutiveFailures,
+			Runs:                runs,
+		})
+	}
+
+	return statuses
+}
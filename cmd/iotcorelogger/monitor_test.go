@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/mtraver/environmental-sensor/sensor"
+	cron "github.com/robfig/cron/v3"
+)
+
+// slowRunner is a job that runs until ctx is done.
+type slowRunner struct{}
+
+func (r slowRunner) Run() {}
+
+func (r slowRunner) run(ctx context.Context) jobResult {
+	<-ctx.Done()
+	return jobResult{}
+}
+
+func runNames(runs []jobRun) []string {
+	var names []string
+	for _, r := range runs {
+		names = append(names, r.Sensors[0])
+	}
+	return names
+}
+
+func TestJobMonitorHistory(t *testing.T) {
+	var log []string
+	m := newJobMonitor(3, 0, nil)
+	wrapped := m.wrap(0, "SENSE", "* * * * * *", nil, 0)
+
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		wrapped.job = fakeRunner{name: name, log: &log}
+		wrapped.Run()
+	}
+
+	status := m.status()
+	if len(status) != 1 {
+		t.Fatalf("got %d statuses, want 1", len(status))
+	}
+	if diff := cmp.Diff(runNames(status[0].Runs), []string{"c", "d", "e"}); diff != "" {
+		t.Errorf("Unexpected result (-got +want):\n%s", diff)
+	}
+}
+
+func TestJobMonitorFailing(t *testing.T) {
+	var log []string
+	var reports []deviceState
+	m := newJobMonitor(0, 2, func(s deviceState) { reports = append(reports, s) })
+	ok := m.wrap(0, "SETUP", "0 * * * * *", fakeRunner{name: "ok", log: &log}, 0)
+	failing := m.wrap(1, "SENSE", "1 * * * * *", fakeRunner{name: "failing", err: "oops", log: &log}, 0)
+
+	ok.Run()
+	failing.Run()
+	if len(reports) != 0 {
+		t.Fatalf("got %d reports after one failure, want 0", len(reports))
+	}
+
+	// The second failure in a row reaches the threshold. Further failures don't
+	// change the set of failing jobs so they aren't reported.
+	failing.Run()
+	failing.Run()
+	want := []deviceState{
+		{FailingJobs: []failingJob{{Index: 1, Operation: "SENSE", Cronspec: "1 * * * * *", ConsecutiveFailures: 2, LastError: "oops"}}},
+	}
+	if diff := cmp.Diff(reports, want); diff != "" {
+		t.Errorf("Unexpected result (-got +want):\n%s", diff)
+	}
+
+	// A success clears the flag.
+	failing.job = fakeRunner{name: "failing", log: &log}
+	failing.Run()
+	want = append(want, deviceState{FailingJobs: []failingJob{}})
+	if diff := cmp.Diff(reports, want); diff != "" {
+		t.Errorf("Unexpected result (-got +want):\n%s", diff)
+	}
+}
+
+func TestJobMonitorTimeout(t *testing.T) {
+	m := newJobMonitor(0, 0, nil)
+	m.wrap(0, "SENSE", "* * * * * *", slowRunner{}, 10*time.Millisecond).Run()
+
+	runs := m.status()[0].Runs
+	if len(runs) != 1 {
+		t.Fatalf("got %d runs, want 1", len(runs))
+	}
+	if !runs[0].TimedOut || len(runs[0].Errors) != 1 {
+		t.Errorf("got %+v, want a timed out run with one error", runs[0])
+	}
+}
+
+// blockingSensor is a sensor whose Init blocks until release is closed, like one that has
+// hung on its bus.
+type blockingSensor struct {
+	fakeSensor
+	inits   chan bool
+	release chan bool
+}
+
+func (s blockingSensor) Init() error {
+	s.inits <- true
+	<-s.release
+	return nil
+}
+
+func TestJobMonitorTimeoutHungSensor(t *testing.T) {
+	s := blockingSensor{inits: make(chan bool, 10), release: make(chan bool)}
+	sensor.Register("test-blocking", s)
+
+	var mu sync.Mutex
+	m := newJobMonitor(0, 0, nil)
+	hung := cron.NewChain(exclusive(&mu)).Then(
+		m.wrap(0, "SETUP", "* * * * * *", SetupJob{Sensors: []string{"test-blocking"}}, 20*time.Millisecond))
+
+	ran := make(chan bool, 1)
+	other := cron.NewChain(exclusive(&mu)).Then(cron.FuncJob(func() { ran <- true }))
+
+	returned := make(chan bool)
+	go func() {
+		hung.Run()
+		returned <- true
+	}()
+	select {
+	case <-returned:
+	case <-time.After(time.Second):
+		t.Fatalf("Job with a hung sensor didn't return after timing out")
+	}
+
+	// Other jobs aren't held up by the hung one.
+	go other.Run()
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		t.Fatalf("Other job didn't run while the hung job was abandoned")
+	}
+
+	// The job isn't run again while the abandoned run is still going.
+	hung.Run()
+	if n := len(s.inits); n != 1 {
+		t.Errorf("got %d calls to Init, want 1", n)
+	}
+
+	runs := m.status()[0].Runs
+	if len(runs) != 2 {
+		t.Fatalf("got %d runs, want 2", len(runs))
+	}
+	for i, r := range runs {
+		if !r.TimedOut || len(r.Errors) == 0 {
+			t.Errorf("Run %d: got %+v, want a timed out run with errors", i, r)
+		}
+	}
+
+	// Once the sensor returns the job is run again.
+	close(s.release)
+	deadline := time.Now().Add(time.Second)
+	for {
+		m.mu.Lock()
+		abandoned := m.jobs[0].abandoned
+		m.mu.Unlock()
+		if !abandoned {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Abandoned run didn't finish")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	hung.Run()
+	runs = m.status()[0].Runs
+	if last := runs[len(runs)-1]; last.TimedOut || len(last.Errors) != 0 {
+		t.Errorf("got %+v, want a successful run", last)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	mpb "github.com/mtraver/environmental-sensor/measurementpb"
+	mpbutil "github.com/mtraver/environmental-sensor/measurementpbutil"
+)
+
+// Exit codes for one-shot mode. exitQueued is EX_TEMPFAIL from sysexits.h, which systemd
+// and cron treat as an ordinary failure but which can be told apart from exitFailure.
+const (
+	exitOK      = 0
+	exitFailure = 1
+	exitQueued  = 75
+)
+
+// runOnce sets up the given sensors, takes one measurement, and shuts the sensors down, using
+// the same jobs that the daemon runs on a schedule. It then publishes any measurements queued
+// by previous runs followed by the new one. If the new measurement can't be published it's
+// queued for the next run. Queued measurements are published in batches of up to batchSize.
+// It returns the exit code for the program.
+func runOnce(sensors []string, j SenseJob, q queue, batchSize int) int {
+	ctx := context.Background()
+	SetupJob{Sensors: sensors}.run(ctx)
+	var res jobResult
+	m := j.sense(ctx, time.Now(), &res)
+	ShutdownJob{Sensors: sensors}.run(ctx)
+	if m == nil {
+		return exitFailure
+	}
+
+	// Unlike the daemon, one-shot mode can't hold the measurement until the clock is
+	// synchronized because it exits right away.
+	if ok, err := systemClockSynced(); err == nil && !ok {
+		log.Print("Warning: the system clock isn't synchronized, so the measurement's timestamp may be wrong")
+	}
+
+	if j.Dryrun {
+		log.Print(mpbutil.String(*m))
+		return exitOK
+	}
+
+	j.number(m, &res)
+
+	if j.Client == nil || !j.Client.IsConnected() {
+		return enqueue(q, m)
+	}
+	defer j.Client.Disconnect(250)
+
+	publish := func(ms []*mpb.Measurement) error {
+		if len(ms) == 1 {
+			return j.publish(ms[0])
+		}
+		return j.publishBatch(ms)
+	}
+	if n, err := q.Flush(batchSize, publish); err != nil {
+		log.Printf("Failed to publish queued measurements (%d published): %v", n, err)
+		return enqueue(q, m)
+	} else if n > 0 {
+		log.Printf("Published %d queued measurements", n)
+	}
+
+	// publish waits for the broker to acknowledge the message.
+	if err := j.publish(m); err != nil {
+		log.Printf("Failed to publish measurement: %v", err)
+		return enqueue(q, m)
+	}
+
+	return exitOK
+}
+
+// enqueue adds the measurement to the queue and returns the exit code for the program.
+func enqueue(q queue, m *mpb.Measurement) int {
+	if err := q.Add(m); err != nil {
+		log.Printf("Failed to queue measurement: %v", err)
+		return exitFailure
+	}
+
+	log.Print("Queued measurement to be published by a later run")
+	return exitQueued
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	mpb "github.com/mtraver/environmental-sensor/measurementpb"
+	"github.com/mtraver/environmental-sensor/sensor"
+	"github.com/mtraver/iotcore"
+)
+
+// brokenSensor is a sensor that fails to take measurements.
+type brokenSensor struct {
+	fakeSensor
+}
+
+func (s brokenSensor) Sense(m *mpb.Measurement) error { return errors.New("broken") }
+
+func init() {
+	sensor.Register("test-broken", brokenSensor{})
+}
+
+func TestRunOnce(t *testing.T) {
+	cases := []struct {
+		name       string
+		sensors    []string
+		dryrun     bool
+		want       int
+		wantQueued int
+	}{
+		{"dryrun", []string{"test-temp-a"}, true, exitOK, 0},
+		{"not_connected", []string{"test-temp-a", "test-pm"}, false, exitQueued, 1},
+		{"sense_failed", []string{"test-broken"}, false, exitFailure, 0},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			q := queue{dir: t.TempDir()}
+			j := SenseJob{
+				Sensors: c.sensors,
+				Device:  iotcore.Device{DeviceID: "foo"},
+				Dryrun:  c.dryrun,
+			}
+
+			if got := runOnce(c.sensors, j, q, 1); got != c.want {
+				t.Errorf("got exit code %d, want %d", got, c.want)
+			}
+
+			infos, err := ioutil.ReadDir(q.dir)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(infos) != c.wantQueued {
+				t.Errorf("got %d queued measurements, want %d", len(infos), c.wantQueued)
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mtraver/environmental-sensor/configpb"
+	mpb "github.com/mtraver/environmental-sensor/measurementpb"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	durationpb "google.golang.org/protobuf/types/known/durationpb"
+	wpb "google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// readingStats describes the samples of one metric from one sensor that a publishFilter has seen.
+type readingStats struct {
+	Metric   string `json:"metric"`
+	Sensor   string `json:"sensor"`
+	Location string `json:"location,omitempty"`
+
+	// The number of samples taken and the number published.
+	Samples   int `json:"samples"`
+	Published int `json:"published"`
+
+	Last          float32   `json:"last"`
+	Min           float32   `json:"min"`
+	Max           float32   `json:"max"`
+	LastPublished float32   `json:"last_published"`
+	PublishedAt   time.Time `json:"published_at"`
+}
+
+// publishFilter decides which measurements SENSE jobs publish under the config's publish
+// policies (see configpb.PublishPolicy). It's shared by all SENSE jobs so that a sensor's
+// last published value is the same no matter which job published it.
+type publishFilter struct {
+	policies map[string]*configpb.PublishPolicy
+
+	mu sync.Mutex
+	// The stats of each sensor's samples of each metric, keyed as in aggregator.
+	stats map[summaryKey]*readingStats
+}
+
+// newPublishFilter returns a publishFilter for the given policies. It returns nil if there
+// are none, in which case every measurement should be published.
+func newPublishFilter(policies map[string]*configpb.PublishPolicy) *publishFilter {
+	if len(policies) == 0 {
+		return nil
+	}
+
+	return &publishFilter{
+		policies: policies,
+		stats:    make(map[summaryKey]*readingStats),
+	}
+}
+
+// shouldPublish records the readings in m and reports whether m should be published. A
+// measurement is published if any of its readings is of a metric with no policy, has never
+// been published, has moved by more than its deadband, or is due a heartbeat. If m is to be
+// published its heartbeat field is set.
+func (f *publishFilter) shouldPublish(m *mpb.Measurement) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := m.GetTimestamp().AsTime()
+	publish := len(m.GetReadings()) == 0
+	byException := len(m.GetReadings()) > 0
+	var heartbeat time.Duration
+	for _, r := range m.GetReadings() {
+		key := summaryKey{r.GetMetric(), r.GetSensor(), r.GetLocation()}
+		s, ok := f.stats[key]
+		if !ok {
+			s = &readingStats{
+				Metric:   key.metric,
+				Sensor:   key.sensor,
+				Location: key.location,
+				Min:      r.GetValue(),
+				Max:      r.GetValue(),
+			}
+			f.stats[key] = s
+		}
+
+		s.Samples++
+		s.Last = r.GetValue()
+		if r.GetValue() < s.Min {
+			s.Min = r.GetValue()
+		}
+		if r.GetValue() > s.Max {
+			s.Max = r.GetValue()
+		}
+
+		p, ok := f.policies[key.metric]
+		if !ok {
+			publish = true
+			byException = false
+			continue
+		}
+
+		hb := p.GetHeartbeat().AsDuration()
+		if heartbeat == 0 || hb < heartbeat {
+			heartbeat = hb
+		}
+
+		if s.Published == 0 ||
+			math.Abs(float64(r.GetValue()-s.LastPublished)) > float64(p.GetDeadband()) ||
+			now.Sub(s.PublishedAt) >= hb {
+			publish = true
+		}
+	}
+
+	if !publish {
+		return false
+	}
+
+	for _, r := range m.GetReadings() {
+		s := f.stats[summaryKey{r.GetMetric(), r.GetSensor(), r.GetLocation()}]
+		s.Published++
+		s.LastPublished = r.GetValue()
+		s.PublishedAt = now
+	}
+
+	if byException {
+		m.Heartbeat = durationpb.New(heartbeat)
+	}
+
+	return true
+}
+
+// status returns the stats of each sensor's samples of each metric, sorted by metric and then sensor.
+func (f *publishFilter) status() []readingStats {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	stats := make([]readingStats, 0, len(f.stats))
+	for _, s := range f.stats {
+		stats = append(stats, *s)
+	}
+
+	sort.Slice(stats, func(a, b int) bool {
+		if stats[a].Metric != stats[b].Metric {
+			return stats[a].Metric < stats[b].Metric
+		}
+		if stats[a].Sensor != stats[b].Sensor {
+			return stats[a].Sensor < stats[b].Sensor
+		}
+		return stats[a].Location < stats[b].Location
+	})
+	return stats
+}
+
+// validatePublishPolicies checks that each policy is for a metric in the Measurement message
+// and that its deadband and heartbeat are valid.
+func validatePublishPolicies(policies map[string]*configpb.PublishPolicy) error {
+	fields := (&mpb.Measurement{}).ProtoReflect().Descriptor().Fields()
+	floatValue := (&wpb.FloatValue{}).ProtoReflect().Descriptor().FullName()
+	for metric, p := range policies {
+		fd := fields.ByName(protoreflect.Name(metric))
+		if fd == nil || fd.Message() == nil || fd.Message().FullName() != floatValue {
+			return fmt.Errorf("publish policy given for unknown metric %q", metric)
+		}
+
+		if p.GetDeadband() < 0 || math.IsNaN(float64(p.GetDeadband())) {
+			return fmt.Errorf("publish policy for %q has a negative deadband", metric)
+		}
+
+		if p.GetHeartbeat() == nil {
+			return fmt.Errorf("publish policy for %q must set heartbeat", metric)
+		}
+		if err := p.GetHeartbeat().CheckValid(); err != nil {
+			return fmt.Errorf("publish policy for %q has a bad heartbeat: %v", metric, err)
+		}
+		if p.GetHeartbeat().AsDuration() <= 0 {
+			return fmt.Errorf("publish policy for %q has a non-positive heartbeat", metric)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mtraver/environmental-sensor/configpb"
+	mpb "github.com/mtraver/environmental-sensor/measurementpb"
+	durationpb "google.golang.org/protobuf/types/known/durationpb"
+	tspb "google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestPublishFilter(t *testing.T) {
+	start := time.Date(2020, time.June, 1, 12, 0, 0, 0, time.UTC)
+	f := newPublishFilter(map[string]*configpb.PublishPolicy{
+		"temp": {Deadband: 0.5, Heartbeat: durationpb.New(time.Hour)},
+		"rh":   {Deadband: 2, Heartbeat: durationpb.New(30 * time.Minute)},
+	})
+
+	cases := []struct {
+		name    string
+		offset  time.Duration
+		temp    float32
+		rh      float32
+		publish bool
+	}{
+		{"first", 0, 20, 50, true},
+		{"within_deadband", time.Minute, 20.4, 51, false},
+		{"temp_moved", 2 * time.Minute, 20.6, 51, true},
+		{"back_within_deadband", 3 * time.Minute, 20.3, 49, false},
+		{"rh_moved", 4 * time.Minute, 20.3, 46, true},
+		{"before_heartbeat", 33 * time.Minute, 20.3, 46, false},
+		{"heartbeat", 34 * time.Minute, 20.3, 46, true},
+	}
+
+	for _, c := range cases {
+		m := &mpb.Measurement{
+			Timestamp: tspb.New(start.Add(c.offset)),
+			Readings: []*mpb.Reading{
+				{Metric: "temp", Sensor: "mcp9808", Value: c.temp},
+				{Metric: "rh", Sensor: "bme280", Value: c.rh},
+			},
+		}
+
+		if got := f.shouldPublish(m); got != c.publish {
+			t.Errorf("%s: got publish = %t, want %t", c.name, got, c.publish)
+		}
+
+		if c.publish && m.GetHeartbeat().AsDuration() != 30*time.Minute {
+			t.Errorf("%s: got heartbeat = %v, want %v", c.name, m.GetHeartbeat().AsDuration(), 30*time.Minute)
+		}
+	}
+
+	// Every sample counts towards the stats, published or not.
+	for _, s := range f.status() {
+		if s.Samples != len(cases) || s.Published != 4 {
+			t.Errorf("%s: got %d samples and %d published, want %d and 4", s.Metric, s.Samples, s.Published, len(cases))
+		}
+	}
+}
+
+func TestPublishFilterNoPolicy(t *testing.T) {
+	f := newPublishFilter(map[string]*configpb.PublishPolicy{
+		"temp": {Deadband: 0.5, Heartbeat: durationpb.New(time.Hour)},
+	})
+
+	for i := 0; i < 3; i++ {
+		m := &mpb.Measurement{
+			Timestamp: tspb.New(time.Date(2020, time.June, 1, 12, i, 0, 0, time.UTC)),
+			Readings: []*mpb.Reading{
+				{Metric: "temp", Sensor: "mcp9808", Value: 20},
+				{Metric: "pm25", Sensor: "sds011", Value: 5},
+			},
+		}
+
+		// A metric with no policy is published every time.
+		if !f.shouldPublish(m) {
+			t.Errorf("sample %d: got publish = false, want true", i)
+		}
+		if m.GetHeartbeat() != nil {
+			t.Errorf("sample %d: got heartbeat = %v, want nil", i, m.GetHeartbeat())
+		}
+	}
+}
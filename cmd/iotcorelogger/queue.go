@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	mpb "github.com/mtraver/environmental-sensor/measurementpb"
+	"google.golang.org/protobuf/proto"
+	tspb "google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const queueFileExtension = ".pb"
+
+// queue persists measurements that couldn't be published so that they can be published
+// by a later run of the program. The MQTT client's store can't be used for this because
+// it's cleared when a new session is started.
+type queue struct {
+	dir string
+}
+
+// Add writes the measurement to the queue.
+func (q queue) Add(m *mpb.Measurement) error {
+	b, err := proto.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	// Name files by the measurement's timestamp so that they sort in the order in which
+	// they were taken. Write to a temp file first so that a partially-written file is never
+	// seen by Flush.
+	name := fmt.Sprintf("%020d%s", m.GetTimestamp().AsTime().UnixNano(), queueFileExtension)
+	tmp, err := ioutil.TempFile(q.dir, "tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), filepath.Join(q.dir, name))
+}
+
+// Flush publishes the queued measurements, oldest first, in batches of up to batchSize,
+// setting the upload timestamp of each. It removes each batch from the queue once it's
+// published and stops at the first failure. Files that can't be parsed are renamed so that
+// they're not tried again. It returns the number of measurements published.
+func (q queue) Flush(batchSize int, publish func(ms []*mpb.Measurement) error) (int, error) {
+	if batchSize < 1 {
+		batchSize = 1
+	}
+
+	infos, err := ioutil.ReadDir(q.dir)
+	if err != nil {
+		return 0, err
+	}
+
+	var names []string
+	for _, info := range infos {
+		if !info.IsDir() && strings.HasSuffix(info.Name(), queueFileExtension) {
+			names = append(names, info.Name())
+		}
+	}
+	sort.Strings(names)
+
+	published := 0
+	var batch []*mpb.Measurement
+	var paths []string
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+
+		now := tspb.New(time.Now().UTC())
+		for _, m := range batch {
+			m.UploadTimestamp = now
+		}
+		if err := publish(batch); err != nil {
+			return err
+		}
+		published += len(batch)
+
+		for _, path := range paths {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+		}
+
+		batch = nil
+		paths = nil
+		return nil
+	}
+
+	for _, name := range names {
+		path := filepath.Join(q.dir, name)
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return published, err
+		}
+
+		m := &mpb.Measurement{}
+		if err := proto.Unmarshal(b, m); err != nil {
+			log.Printf("Failed to parse queued measurement %s, setting it aside: %v", path, err)
+			if err := os.Rename(path, path+".bad"); err != nil {
+				return published, err
+			}
+			continue
+		}
+
+		batch = append(batch, m)
+		paths = append(paths, path)
+		if len(batch) >= batchSize {
+			if err := flush(); err != nil {
+				return published, err
+			}
+		}
+	}
+
+	return published, flush()
+}
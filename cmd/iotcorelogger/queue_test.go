@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	mpb "github.com/mtraver/environmental-sensor/measurementpb"
+	tspb "google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func queuedMeasurement(sec int64) *mpb.Measurement {
+	return &mpb.Measurement{
+		DeviceId:  "foo",
+		Timestamp: tspb.New(time.Unix(sec, 0)),
+	}
+}
+
+func TestQueueFlush(t *testing.T) {
+	q := queue{dir: t.TempDir()}
+	for _, sec := range []int64{300, 100, 200} {
+		if err := q.Add(queuedMeasurement(sec)); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	// The first flush fails on the second measurement, which should stay queued.
+	var got []int64
+	n, err := q.Flush(1, func(ms []*mpb.Measurement) error {
+		m := ms[0]
+		if len(got) == 1 {
+			return errors.New("publish failed")
+		}
+		if m.GetUploadTimestamp() == nil {
+			t.Errorf("Upload timestamp not set")
+		}
+		got = append(got, m.GetTimestamp().GetSeconds())
+		return nil
+	})
+	if err == nil {
+		t.Errorf("Expected error")
+	}
+	if n != 1 {
+		t.Errorf("got %d published, want 1", n)
+	}
+
+	n, err = q.Flush(1, func(ms []*mpb.Measurement) error {
+		got = append(got, ms[0].GetTimestamp().GetSeconds())
+		return nil
+	})
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("got %d published, want 2", n)
+	}
+
+	want := []int64{100, 200, 300}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("got %v, want %v", got, want)
+			break
+		}
+	}
+
+	n, err = q.Flush(1, func(ms []*mpb.Measurement) error { return nil })
+	if err != nil || n != 0 {
+		t.Errorf("got (%d, %v) from empty queue, want (0, nil)", n, err)
+	}
+}
+
+func TestQueueFlushBatches(t *testing.T) {
+	q := queue{dir: t.TempDir()}
+	for _, sec := range []int64{100, 200, 300, 400, 500} {
+		if err := q.Add(queuedMeasurement(sec)); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	var got []int
+	n, err := q.Flush(2, func(ms []*mpb.Measurement) error {
+		got = append(got, len(ms))
+		return nil
+	})
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if n != 5 {
+		t.Errorf("got %d published, want 5", n)
+	}
+
+	if diff := cmp.Diff(got, []int{2, 2, 1}); diff != "" {
+		t.Errorf("Unexpected batch sizes (-got +want):\n%s", diff)
+	}
+}
+
+func TestQueueFlushBadFile(t *testing.T) {
+	q := queue{dir: t.TempDir()}
+	if err := ioutil.WriteFile(filepath.Join(q.dir, "1.pb"), []byte("not a proto"), 0600); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := q.Add(queuedMeasurement(100)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	n, err := q.Flush(1, func(ms []*mpb.Measurement) error { return nil })
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("got %d published, want 1", n)
+	}
+
+	if _, err := os.Stat(filepath.Join(q.dir, "1.pb.bad")); err != nil {
+		t.Errorf("Bad file wasn't set aside: %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	mpb "github.com/mtraver/environmental-sensor/measurementpb"
+)
+
+// The kernel's random ID for the current boot.
+const kernelBootIDPath = "/proc/sys/kernel/random/boot_id"
+
+// The number of sequence numbers that a sequencer reserves at a time.
+const sequenceBlock = 1000
+
+// sequencer numbers the measurements that the device publishes. Numbers are reserved in blocks
+// by persisting the end of the block, so that the file isn't written for every measurement,
+// and numbering carries on from there when the program restarts. release persists the next
+// number instead so that the rest of the block isn't skipped. If the program exits without
+// releasing the block, e.g. because it crashed, the rest of the block is skipped. That shows as
+// lost measurements in that boot unless the device reboots, which starts a new boot ID.
+type sequencer struct {
+	path   string
+	bootID string
+
+	mu   sync.Mutex
+	next uint64
+	// The end of the reserved block. Numbers before it can be used without persisting anything.
+	reserved uint64
+}
+
+// newSequencer returns a sequencer that persists the next sequence number in the file at the
+// given path. If the file doesn't exist then numbering starts at 0.
+func newSequencer(path, bootID string) (*sequencer, error) {
+	s := &sequencer{
+		path:   path,
+		bootID: bootID,
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	s.next, err = strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("bad sequence number in %s: %v", path, err)
+	}
+	s.reserved = s.next
+
+	return s, nil
+}
+
+// stamp sets the measurement's sequence number and boot ID. If the reserved block is used up
+// then the next one is persisted before m is stamped so that a number is never used twice.
+func (s *sequencer) stamp(m *mpb.Measurement) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.next >= s.reserved {
+		if err := s.persist(s.next + sequenceBlock); err != nil {
+			return err
+		}
+		s.reserved = s.next + sequenceBlock
+	}
+
+	m.Sequence = s.next
+	m.BootId = s.bootID
+	s.next++
+	return nil
+}
+
+// release persists the next sequence number, giving up the rest of the reserved block, so that
+// numbering carries on without a gap when the program restarts. It should be called when the
+// program exits. Measurements stamped after it's called reserve another block.
+func (s *sequencer) release() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.next == s.reserved {
+		return nil
+	}
+	if err := s.persist(s.next); err != nil {
+		return err
+	}
+	s.reserved = s.next
+	return nil
+}
+
+// persist writes n to the file, replacing it atomically so that a crash can't leave it empty.
+func (s *sequencer) persist(n uint64) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path))
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(tmp, "%d\n", n); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// bootID returns the kernel's ID for the current boot. If it can't be read, e.g. because the
+// OS isn't Linux, then it returns a random ID, so that each run of the program counts as a boot.
+func bootID() string {
+	if b, err := ioutil.ReadFile(kernelBootIDPath); err == nil {
+		if id := strings.TrimSpace(string(b)); id != "" {
+			return id
+		}
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// There's nothing better to fall back on. Measurements are still numbered.
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
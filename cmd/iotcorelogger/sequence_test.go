@@ -0,0 +1,109 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	mpb "github.com/mtraver/environmental-sensor/measurementpb"
+)
+
+func TestSequencer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sequence")
+
+	s, err := newSequencer(path, "boot-1")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for want := uint64(0); want < 3; want++ {
+		var m mpb.Measurement
+		if err := s.stamp(&m); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if m.GetSequence() != want || m.GetBootId() != "boot-1" {
+			t.Errorf("got (%d, %q), want (%d, %q)", m.GetSequence(), m.GetBootId(), want, "boot-1")
+		}
+	}
+
+	// Numbering carries on after a restart.
+	if err := s.release(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	s, err = newSequencer(path, "boot-2")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var m mpb.Measurement
+	if err := s.stamp(&m); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if m.GetSequence() != 3 || m.GetBootId() != "boot-2" {
+		t.Errorf("got (%d, %q), want (%d, %q)", m.GetSequence(), m.GetBootId(), 3, "boot-2")
+	}
+}
+
+func TestSequencerBlocks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sequence")
+	persisted := func() string {
+		t.Helper()
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return strings.TrimSpace(string(b))
+	}
+
+	s, err := newSequencer(path, "boot-1")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for i := 0; i < sequenceBlock+1; i++ {
+		var m mpb.Measurement
+		if err := s.stamp(&m); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		// The file is only written when a block is reserved.
+		wantPersisted := sequenceBlock
+		if i >= sequenceBlock {
+			wantPersisted = 2 * sequenceBlock
+		}
+		if got, want := persisted(), strconv.Itoa(wantPersisted); got != want {
+			t.Fatalf("After %d: got %s persisted, want %s", i, got, want)
+		}
+	}
+
+	// Without releasing, the rest of the block is skipped after a restart.
+	s, err = newSequencer(path, "boot-1")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var m mpb.Measurement
+	if err := s.stamp(&m); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got, want := m.GetSequence(), uint64(2*sequenceBlock); got != want {
+		t.Errorf("got %d, want %d", got, want)
+	}
+
+	// Releasing the block persists the next number.
+	if err := s.release(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got, want := persisted(), strconv.Itoa(2*sequenceBlock+1); got != want {
+		t.Errorf("got %s persisted, want %s", got, want)
+	}
+}
+
+func TestSequencerBadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sequence")
+	if err := ioutil.WriteFile(path, []byte("not a number"), 0600); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if _, err := newSequencer(path, "boot-1"); err == nil {
+		t.Errorf("Expected error")
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func writePEM(t *testing.T, blockType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "key.pem")
+	if err := ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return path
+}
+
+func TestLoadSigningKey(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	sec1, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	rsaPKCS8, err := x509.MarshalPKCS8PrivateKey(rsaKey)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	cases := []struct {
+		name  string
+		path  string
+		valid bool
+	}{
+		{"sec1", writePEM(t, "EC PRIVATE KEY", sec1), true},
+		{"pkcs8", writePEM(t, "PRIVATE KEY", pkcs8), true},
+		{"rsa", writePEM(t, "PRIVATE KEY", rsaPKCS8), false},
+		{"garbage", writePEM(t, "EC PRIVATE KEY", []byte("not a key")), false},
+		{"missing", filepath.Join(t.TempDir(), "missing.pem"), false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := loadSigningKey(c.path)
+			if valid := err == nil; valid != c.valid {
+				t.Fatalf("got valid = %t, want %t (err = %v)", valid, c.valid, err)
+			}
+			if c.valid && !got.Equal(key) {
+				t.Errorf("Loaded key doesn't match")
+			}
+		})
+	}
+}
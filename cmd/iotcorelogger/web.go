@@ -1,9 +1,12 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
 
+	"github.com/mtraver/environmental-sensor/sensor"
 	"github.com/mtraver/iotcore"
 )
 
@@ -14,3 +17,55 @@ type indexHandler struct {
 func (h indexHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintln(w, h.device.DeviceID)
 }
+
+type sensorStatus struct {
+	Name string `json:"name"`
+	sensor.Capabilities
+}
+
+// statusHandler serves a JSON description of the device, its sensors, and its jobs.
+type statusHandler struct {
+	device  iotcore.Device
+	jobs    *jobMonitor
+	publish *publishFilter
+}
+
+func (h statusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	status := struct {
+		DeviceID string         `json:"device_id"`
+		Sensors  []sensorStatus `json:"sensors"`
+		Jobs     []jobStatus    `json:"jobs"`
+		Readings []readingStats `json:"readings"`
+	}{
+		DeviceID: h.device.DeviceID,
+		Sensors:  []sensorStatus{},
+		Jobs:     []jobStatus{},
+		Readings: []readingStats{},
+	}
+
+	if h.jobs != nil {
+		status.Jobs = h.jobs.status()
+	}
+
+	if h.publish != nil {
+		status.Readings = h.publish.status()
+	}
+
+	for _, name := range sensor.Names() {
+		caps, _, err := sensor.Describe(name)
+		if err != nil {
+			// The sensor was registered when we got its name, and sensors are never unregistered.
+			log.Printf("Error describing sensor %q: %v", name, err)
+			continue
+		}
+		status.Sensors = append(status.Sensors, sensorStatus{
+			Name:         name,
+			Capabilities: caps,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		log.Printf("Failed to write status: %v", err)
+	}
+}
@@ -8,6 +8,7 @@ package configpb
 
 import (
 	proto "github.com/golang/protobuf/proto"
+	duration "github.com/golang/protobuf/ptypes/duration"
 	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
 	reflect "reflect"
@@ -32,6 +33,9 @@ const (
 	Job_SETUP    Job_Operation = 1
 	Job_SENSE    Job_Operation = 2
 	Job_SHUTDOWN Job_Operation = 3
+	// Runs steps in order. If the job is still running when it's next
+	// scheduled to run then that run is skipped.
+	Job_SEQUENCE Job_Operation = 4
 )
 
 // Enum value maps for Job_Operation.
@@ -41,12 +45,14 @@ var (
 		1: "SETUP",
 		2: "SENSE",
 		3: "SHUTDOWN",
+		4: "SEQUENCE",
 	}
 	Job_Operation_value = map[string]int32{
 		"INVALID":  0,
 		"SETUP":    1,
 		"SENSE":    2,
 		"SHUTDOWN": 3,
+		"SEQUENCE": 4,
 	}
 )
 
@@ -74,7 +80,167 @@ func (x Job_Operation) Number() protoreflect.EnumNumber {
 
 // Deprecated: Use Job_Operation.Descriptor instead.
 func (Job_Operation) EnumDescriptor() ([]byte, []int) {
-	return file_configpb_config_proto_rawDescGZIP(), []int{1, 0}
+	return file_configpb_config_proto_rawDescGZIP(), []int{3, 0}
+}
+
+type Job_Overlap int32
+
+const (
+	Job_OVERLAP_DEFAULT Job_Overlap = 0
+	// Don't run.
+	Job_OVERLAP_SKIP Job_Overlap = 1
+	// Run once the previous run finishes.
+	Job_OVERLAP_QUEUE Job_Overlap = 2
+)
+
+// Enum value maps for Job_Overlap.
+var (
+	Job_Overlap_name = map[int32]string{
+		0: "OVERLAP_DEFAULT",
+		1: "OVERLAP_SKIP",
+		2: "OVERLAP_QUEUE",
+	}
+	Job_Overlap_value = map[string]int32{
+		"OVERLAP_DEFAULT": 0,
+		"OVERLAP_SKIP":    1,
+		"OVERLAP_QUEUE":   2,
+	}
+)
+
+func (x Job_Overlap) Enum() *Job_Overlap {
+	p := new(Job_Overlap)
+	*p = x
+	return p
+}
+
+func (x Job_Overlap) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Job_Overlap) Descriptor() protoreflect.EnumDescriptor {
+	return file_configpb_config_proto_enumTypes[1].Descriptor()
+}
+
+func (Job_Overlap) Type() protoreflect.EnumType {
+	return &file_configpb_config_proto_enumTypes[1]
+}
+
+func (x Job_Overlap) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Job_Overlap.Descriptor instead.
+func (Job_Overlap) EnumDescriptor() ([]byte, []int) {
+	return file_configpb_config_proto_rawDescGZIP(), []int{3, 1}
+}
+
+// Conversion resolution in °C. Higher resolutions take longer to convert.
+type MCP9808Config_Resolution int32
+
+const (
+	MCP9808Config_RESOLUTION_DEFAULT MCP9808Config_Resolution = 0 // Same as RESOLUTION_0_0625.
+	MCP9808Config_RESOLUTION_0_5     MCP9808Config_Resolution = 1
+	MCP9808Config_RESOLUTION_0_25    MCP9808Config_Resolution = 2
+	MCP9808Config_RESOLUTION_0_125   MCP9808Config_Resolution = 3
+	MCP9808Config_RESOLUTION_0_0625  MCP9808Config_Resolution = 4
+)
+
+// Enum value maps for MCP9808Config_Resolution.
+var (
+	MCP9808Config_Resolution_name = map[int32]string{
+		0: "RESOLUTION_DEFAULT",
+		1: "RESOLUTION_0_5",
+		2: "RESOLUTION_0_25",
+		3: "RESOLUTION_0_125",
+		4: "RESOLUTION_0_0625",
+	}
+	MCP9808Config_Resolution_value = map[string]int32{
+		"RESOLUTION_DEFAULT": 0,
+		"RESOLUTION_0_5":     1,
+		"RESOLUTION_0_25":    2,
+		"RESOLUTION_0_125":   3,
+		"RESOLUTION_0_0625":  4,
+	}
+)
+
+func (x MCP9808Config_Resolution) Enum() *MCP9808Config_Resolution {
+	p := new(MCP9808Config_Resolution)
+	*p = x
+	return p
+}
+
+func (x MCP9808Config_Resolution) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (MCP9808Config_Resolution) Descriptor() protoreflect.EnumDescriptor {
+	return file_configpb_config_proto_enumTypes[2].Descriptor()
+}
+
+func (MCP9808Config_Resolution) Type() protoreflect.EnumType {
+	return &file_configpb_config_proto_enumTypes[2]
+}
+
+func (x MCP9808Config_Resolution) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use MCP9808Config_Resolution.Descriptor instead.
+func (MCP9808Config_Resolution) EnumDescriptor() ([]byte, []int) {
+	return file_configpb_config_proto_rawDescGZIP(), []int{7, 0}
+}
+
+// Hysteresis applied to the limits when the temperature is falling.
+type MCP9808Alert_Hysteresis int32
+
+const (
+	MCP9808Alert_HYSTERESIS_0   MCP9808Alert_Hysteresis = 0
+	MCP9808Alert_HYSTERESIS_1_5 MCP9808Alert_Hysteresis = 1
+	MCP9808Alert_HYSTERESIS_3   MCP9808Alert_Hysteresis = 2
+	MCP9808Alert_HYSTERESIS_6   MCP9808Alert_Hysteresis = 3
+)
+
+// Enum value maps for MCP9808Alert_Hysteresis.
+var (
+	MCP9808Alert_Hysteresis_name = map[int32]string{
+		0: "HYSTERESIS_0",
+		1: "HYSTERESIS_1_5",
+		2: "HYSTERESIS_3",
+		3: "HYSTERESIS_6",
+	}
+	MCP9808Alert_Hysteresis_value = map[string]int32{
+		"HYSTERESIS_0":   0,
+		"HYSTERESIS_1_5": 1,
+		"HYSTERESIS_3":   2,
+		"HYSTERESIS_6":   3,
+	}
+)
+
+func (x MCP9808Alert_Hysteresis) Enum() *MCP9808Alert_Hysteresis {
+	p := new(MCP9808Alert_Hysteresis)
+	*p = x
+	return p
+}
+
+func (x MCP9808Alert_Hysteresis) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (MCP9808Alert_Hysteresis) Descriptor() protoreflect.EnumDescriptor {
+	return file_configpb_config_proto_enumTypes[3].Descriptor()
+}
+
+func (MCP9808Alert_Hysteresis) Type() protoreflect.EnumType {
+	return &file_configpb_config_proto_enumTypes[3]
+}
+
+func (x MCP9808Alert_Hysteresis) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use MCP9808Alert_Hysteresis.Descriptor instead.
+func (MCP9808Alert_Hysteresis) EnumDescriptor() ([]byte, []int) {
+	return file_configpb_config_proto_rawDescGZIP(), []int{8, 0}
 }
 
 // Config configures the iotcorelogger program.
@@ -91,6 +257,34 @@ type Config struct {
 	CaCertsPath      string   `protobuf:"bytes,2,opt,name=ca_certs_path,json=caCertsPath,proto3" json:"ca_certs_path,omitempty"`
 	SupportedSensors []string `protobuf:"bytes,3,rep,name=supported_sensors,json=supportedSensors,proto3" json:"supported_sensors,omitempty"`
 	Jobs             []*Job   `protobuf:"bytes,4,rep,name=jobs,proto3" json:"jobs,omitempty"`
+	// Corrections applied to sensor values after they're read and before
+	// they're published.
+	Calibrations []*Calibration `protobuf:"bytes,5,rep,name=calibrations,proto3" json:"calibrations,omitempty"`
+	// Used only if "mcp9808" is in supported_sensors.
+	Mcp9808 *MCP9808Config `protobuf:"bytes,6,opt,name=mcp9808,proto3" json:"mcp9808,omitempty"`
+	// Used only if "sds011" is in supported_sensors.
+	Sds011 *SDS011Config `protobuf:"bytes,7,opt,name=sds011,proto3" json:"sds011,omitempty"`
+	// Locations of sensors, keyed by the name of the sensor as given in
+	// supported_sensors, e.g. {"mcp9808": "probe-in"}. The location is recorded
+	// with each reading so that sensors reporting the same metric can be told apart.
+	Locations map[string]string `protobuf:"bytes,8,rep,name=locations,proto3" json:"locations,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// The number of runs of each job to keep. They're shown by the device's
+	// status API. Defaults to 20.
+	JobHistorySize uint32 `protobuf:"varint,9,opt,name=job_history_size,json=jobHistorySize,proto3" json:"job_history_size,omitempty"`
+	// A job that fails this many times in a row is reported in the device's
+	// state. Defaults to 3.
+	JobFailureThreshold uint32 `protobuf:"varint,10,opt,name=job_failure_threshold,json=jobFailureThreshold,proto3" json:"job_failure_threshold,omitempty"`
+	// Publish policies, keyed by metric, e.g. {"temp": {...}}. A metric is named
+	// as in the Measurement message. SENSE jobs publish every measurement unless
+	// every metric in it has a policy, in which case a measurement is published
+	// only if at least one metric's policy calls for it.
+	PublishPolicies map[string]*PublishPolicy `protobuf:"bytes,11,rep,name=publish_policies,json=publishPolicies,proto3" json:"publish_policies,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// Packs measurements into batches so that there are fewer messages to
+	// publish. Measurements are published one per message if unset.
+	Batch *Batch `protobuf:"bytes,12,opt,name=batch,proto3" json:"batch,omitempty"`
+	// If true, measurements are signed with the device's private key (the one
+	// used to authenticate with IoT Core) and published as SignedBatch messages.
+	SignMeasurements bool `protobuf:"varint,13,opt,name=sign_measurements,json=signMeasurements,proto3" json:"sign_measurements,omitempty"`
 }
 
 func (x *Config) Reset() {
@@ -153,6 +347,192 @@ func (x *Config) GetJobs() []*Job {
 	return nil
 }
 
+func (x *Config) GetCalibrations() []*Calibration {
+	if x != nil {
+		return x.Calibrations
+	}
+	return nil
+}
+
+func (x *Config) GetMcp9808() *MCP9808Config {
+	if x != nil {
+		return x.Mcp9808
+	}
+	return nil
+}
+
+func (x *Config) GetSds011() *SDS011Config {
+	if x != nil {
+		return x.Sds011
+	}
+	return nil
+}
+
+func (x *Config) GetLocations() map[string]string {
+	if x != nil {
+		return x.Locations
+	}
+	return nil
+}
+
+func (x *Config) GetJobHistorySize() uint32 {
+	if x != nil {
+		return x.JobHistorySize
+	}
+	return 0
+}
+
+func (x *Config) GetJobFailureThreshold() uint32 {
+	if x != nil {
+		return x.JobFailureThreshold
+	}
+	return 0
+}
+
+func (x *Config) GetPublishPolicies() map[string]*PublishPolicy {
+	if x != nil {
+		return x.PublishPolicies
+	}
+	return nil
+}
+
+func (x *Config) GetBatch() *Batch {
+	if x != nil {
+		return x.Batch
+	}
+	return nil
+}
+
+func (x *Config) GetSignMeasurements() bool {
+	if x != nil {
+		return x.SignMeasurements
+	}
+	return false
+}
+
+// Batch controls how measurements are packed into MeasurementBatch messages. A
+// batch is published when it's full or when its oldest measurement has waited
+// for max_delay, whichever comes first. A partial batch is also published by
+// SHUTDOWN jobs and when the program is killed.
+type Batch struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The most measurements to publish in one message. Must be at least 2.
+	MaxMeasurements uint32 `protobuf:"varint,1,opt,name=max_measurements,json=maxMeasurements,proto3" json:"max_measurements,omitempty"`
+	// The longest a measurement waits to be published. Required.
+	MaxDelay *duration.Duration `protobuf:"bytes,2,opt,name=max_delay,json=maxDelay,proto3" json:"max_delay,omitempty"`
+}
+
+func (x *Batch) Reset() {
+	*x = Batch{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_configpb_config_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Batch) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Batch) ProtoMessage() {}
+
+func (x *Batch) ProtoReflect() protoreflect.Message {
+	mi := &file_configpb_config_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Batch.ProtoReflect.Descriptor instead.
+func (*Batch) Descriptor() ([]byte, []int) {
+	return file_configpb_config_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Batch) GetMaxMeasurements() uint32 {
+	if x != nil {
+		return x.MaxMeasurements
+	}
+	return 0
+}
+
+func (x *Batch) GetMaxDelay() *duration.Duration {
+	if x != nil {
+		return x.MaxDelay
+	}
+	return nil
+}
+
+// PublishPolicy controls when a metric is reported by exception. Its value is
+// published if it's moved by more than the deadband since it was last published,
+// or if the heartbeat interval has passed since then. Samples that aren't
+// published still count towards the device's local stats and aggregation windows.
+type PublishPolicy struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Publish when the value differs from the last published value by more than
+	// this. Zero publishes whenever the value changes.
+	Deadband float32 `protobuf:"fixed32,1,opt,name=deadband,proto3" json:"deadband,omitempty"`
+	// Publish at least this often even if the value hasn't moved. Required.
+	Heartbeat *duration.Duration `protobuf:"bytes,2,opt,name=heartbeat,proto3" json:"heartbeat,omitempty"`
+}
+
+func (x *PublishPolicy) Reset() {
+	*x = PublishPolicy{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_configpb_config_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PublishPolicy) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PublishPolicy) ProtoMessage() {}
+
+func (x *PublishPolicy) ProtoReflect() protoreflect.Message {
+	mi := &file_configpb_config_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PublishPolicy.ProtoReflect.Descriptor instead.
+func (*PublishPolicy) Descriptor() ([]byte, []int) {
+	return file_configpb_config_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *PublishPolicy) GetDeadband() float32 {
+	if x != nil {
+		return x.Deadband
+	}
+	return 0
+}
+
+func (x *PublishPolicy) GetHeartbeat() *duration.Duration {
+	if x != nil {
+		return x.Heartbeat
+	}
+	return nil
+}
+
 type Job struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -161,14 +541,31 @@ type Job struct {
 	// Cron spec that specifies when this job should run.
 	Cronspec  string        `protobuf:"bytes,1,opt,name=cronspec,proto3" json:"cronspec,omitempty"`
 	Operation Job_Operation `protobuf:"varint,2,opt,name=operation,proto3,enum=config.Job_Operation" json:"operation,omitempty"`
-	// Sensors are processed in the order given.
+	// Sensors are processed in the order given. Not used by SEQUENCE jobs.
 	Sensors []string `protobuf:"bytes,3,rep,name=sensors,proto3" json:"sensors,omitempty"`
+	// Used only by SEQUENCE jobs.
+	Steps []*Step `protobuf:"bytes,4,rep,name=steps,proto3" json:"steps,omitempty"`
+	// How long a run of the job may take. Once it's exceeded the job stops
+	// before its next sensor or step; a sensor that's being read isn't
+	// interrupted. If unset there's no timeout.
+	Timeout *duration.Duration `protobuf:"bytes,5,opt,name=timeout,proto3" json:"timeout,omitempty"`
+	// What to do if the job is scheduled to run while its previous run is still
+	// going. SEQUENCE jobs default to OVERLAP_SKIP and all others to OVERLAP_QUEUE.
+	Overlap Job_Overlap `protobuf:"varint,6,opt,name=overlap,proto3,enum=config.Job_Overlap" json:"overlap,omitempty"`
+	// Used only by SENSE jobs, including SENSE steps of SEQUENCE jobs. If set,
+	// each run of the job takes a sample and buffers it rather than publishing
+	// it. Once per window, with windows aligned to multiples of this duration,
+	// a summary of the window's samples is published. The job should be
+	// scheduled to run several times per window.
+	// A window's summary is published when the first sample of the next window is
+	// taken, or a window later if there is none, and when the program is killed.
+	AggregationWindow *duration.Duration `protobuf:"bytes,7,opt,name=aggregation_window,json=aggregationWindow,proto3" json:"aggregation_window,omitempty"`
 }
 
 func (x *Job) Reset() {
 	*x = Job{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_configpb_config_proto_msgTypes[1]
+		mi := &file_configpb_config_proto_msgTypes[3]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -181,7 +578,7 @@ func (x *Job) String() string {
 func (*Job) ProtoMessage() {}
 
 func (x *Job) ProtoReflect() protoreflect.Message {
-	mi := &file_configpb_config_proto_msgTypes[1]
+	mi := &file_configpb_config_proto_msgTypes[3]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -194,7 +591,7 @@ func (x *Job) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Job.ProtoReflect.Descriptor instead.
 func (*Job) Descriptor() ([]byte, []int) {
-	return file_configpb_config_proto_rawDescGZIP(), []int{1}
+	return file_configpb_config_proto_rawDescGZIP(), []int{3}
 }
 
 func (x *Job) GetCronspec() string {
@@ -218,76 +615,750 @@ func (x *Job) GetSensors() []string {
 	return nil
 }
 
-var File_configpb_config_proto protoreflect.FileDescriptor
-
-var file_configpb_config_proto_rawDesc = []byte{
-	0x0a, 0x15, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x70, 0x62, 0x2f, 0x63, 0x6f, 0x6e, 0x66, 0x69,
-	0x67, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x06, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x22,
-	0xa4, 0x01, 0x0a, 0x06, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x12, 0x28, 0x0a, 0x10, 0x64, 0x65,
-	0x76, 0x69, 0x63, 0x65, 0x5f, 0x66, 0x69, 0x6c, 0x65, 0x5f, 0x70, 0x61, 0x74, 0x68, 0x18, 0x01,
-	0x20, 0x01, 0x28, 0x09, 0x52, 0x0e, 0x64, 0x65, 0x76, 0x69, 0x63, 0x65, 0x46, 0x69, 0x6c, 0x65,
-	0x50, 0x61, 0x74, 0x68, 0x12, 0x22, 0x0a, 0x0d, 0x63, 0x61, 0x5f, 0x63, 0x65, 0x72, 0x74, 0x73,
-	0x5f, 0x70, 0x61, 0x74, 0x68, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x63, 0x61, 0x43,
-	0x65, 0x72, 0x74, 0x73, 0x50, 0x61, 0x74, 0x68, 0x12, 0x2b, 0x0a, 0x11, 0x73, 0x75, 0x70, 0x70,
-	0x6f, 0x72, 0x74, 0x65, 0x64, 0x5f, 0x73, 0x65, 0x6e, 0x73, 0x6f, 0x72, 0x73, 0x18, 0x03, 0x20,
-	0x03, 0x28, 0x09, 0x52, 0x10, 0x73, 0x75, 0x70, 0x70, 0x6f, 0x72, 0x74, 0x65, 0x64, 0x53, 0x65,
-	0x6e, 0x73, 0x6f, 0x72, 0x73, 0x12, 0x1f, 0x0a, 0x04, 0x6a, 0x6f, 0x62, 0x73, 0x18, 0x04, 0x20,
-	0x03, 0x28, 0x0b, 0x32, 0x0b, 0x2e, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x2e, 0x4a, 0x6f, 0x62,
-	0x52, 0x04, 0x6a, 0x6f, 0x62, 0x73, 0x22, 0xae, 0x01, 0x0a, 0x03, 0x4a, 0x6f, 0x62, 0x12, 0x1a,
-	0x0a, 0x08, 0x63, 0x72, 0x6f, 0x6e, 0x73, 0x70, 0x65, 0x63, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
-	0x52, 0x08, 0x63, 0x72, 0x6f, 0x6e, 0x73, 0x70, 0x65, 0x63, 0x12, 0x33, 0x0a, 0x09, 0x6f, 0x70,
-	0x65, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x15, 0x2e,
-	0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x2e, 0x4a, 0x6f, 0x62, 0x2e, 0x4f, 0x70, 0x65, 0x72, 0x61,
-	0x74, 0x69, 0x6f, 0x6e, 0x52, 0x09, 0x6f, 0x70, 0x65, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12,
-	0x18, 0x0a, 0x07, 0x73, 0x65, 0x6e, 0x73, 0x6f, 0x72, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x09,
-	0x52, 0x07, 0x73, 0x65, 0x6e, 0x73, 0x6f, 0x72, 0x73, 0x22, 0x3c, 0x0a, 0x09, 0x4f, 0x70, 0x65,
-	0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x0b, 0x0a, 0x07, 0x49, 0x4e, 0x56, 0x41, 0x4c, 0x49,
-	0x44, 0x10, 0x00, 0x12, 0x09, 0x0a, 0x05, 0x53, 0x45, 0x54, 0x55, 0x50, 0x10, 0x01, 0x12, 0x09,
-	0x0a, 0x05, 0x53, 0x45, 0x4e, 0x53, 0x45, 0x10, 0x02, 0x12, 0x0c, 0x0a, 0x08, 0x53, 0x48, 0x55,
-	0x54, 0x44, 0x4f, 0x57, 0x4e, 0x10, 0x03, 0x42, 0x32, 0x5a, 0x30, 0x67, 0x69, 0x74, 0x68, 0x75,
-	0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x6d, 0x74, 0x72, 0x61, 0x76, 0x65, 0x72, 0x2f, 0x65, 0x6e,
-	0x76, 0x69, 0x72, 0x6f, 0x6e, 0x6d, 0x65, 0x6e, 0x74, 0x61, 0x6c, 0x2d, 0x73, 0x65, 0x6e, 0x73,
-	0x6f, 0x72, 0x2f, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f,
-	0x74, 0x6f, 0x33,
+func (x *Job) GetSteps() []*Step {
+	if x != nil {
+		return x.Steps
+	}
+	return nil
 }
 
-var (
-	file_configpb_config_proto_rawDescOnce sync.Once
-	file_configpb_config_proto_rawDescData = file_configpb_config_proto_rawDesc
-)
+func (x *Job) GetTimeout() *duration.Duration {
+	if x != nil {
+		return x.Timeout
+	}
+	return nil
+}
 
-func file_configpb_config_proto_rawDescGZIP() []byte {
-	file_configpb_config_proto_rawDescOnce.Do(func() {
-		file_configpb_config_proto_rawDescData = protoimpl.X.CompressGZIP(file_configpb_config_proto_rawDescData)
-	})
-	return file_configpb_config_proto_rawDescData
+func (x *Job) GetOverlap() Job_Overlap {
+	if x != nil {
+		return x.Overlap
+	}
+	return Job_OVERLAP_DEFAULT
 }
 
-var file_configpb_config_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_configpb_config_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
-var file_configpb_config_proto_goTypes = []interface{}{
-	(Job_Operation)(0), // 0: config.Job.Operation
-	(*Config)(nil),     // 1: config.Config
-	(*Job)(nil),        // 2: config.Job
+func (x *Job) GetAggregationWindow() *duration.Duration {
+	if x != nil {
+		return x.AggregationWindow
+	}
+	return nil
 }
-var file_configpb_config_proto_depIdxs = []int32{
-	2, // 0: config.Config.jobs:type_name -> config.Job
-	0, // 1: config.Job.operation:type_name -> config.Job.Operation
-	2, // [2:2] is the sub-list for method output_type
-	2, // [2:2] is the sub-list for method input_type
-	2, // [2:2] is the sub-list for extension type_name
-	2, // [2:2] is the sub-list for extension extendee
-	0, // [0:2] is the sub-list for field type_name
+
+// Step is one step of a SEQUENCE job, e.g. SETUP followed by a delay to let a
+// sensor warm up.
+type Step struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Must not be SEQUENCE.
+	Operation Job_Operation `protobuf:"varint,1,opt,name=operation,proto3,enum=config.Job_Operation" json:"operation,omitempty"`
+	// Sensors are processed in the order given.
+	Sensors []string `protobuf:"bytes,2,rep,name=sensors,proto3" json:"sensors,omitempty"`
+	// How long to wait after the step before running the next one.
+	Delay *duration.Duration `protobuf:"bytes,3,opt,name=delay,proto3" json:"delay,omitempty"`
 }
 
-func init() { file_configpb_config_proto_init() }
-func file_configpb_config_proto_init() {
-	if File_configpb_config_proto != nil {
-		return
+func (x *Step) Reset() {
+	*x = Step{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_configpb_config_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
 	}
-	if !protoimpl.UnsafeEnabled {
-		file_configpb_config_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*Config); i {
+}
+
+func (x *Step) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Step) ProtoMessage() {}
+
+func (x *Step) ProtoReflect() protoreflect.Message {
+	mi := &file_configpb_config_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Step.ProtoReflect.Descriptor instead.
+func (*Step) Descriptor() ([]byte, []int) {
+	return file_configpb_config_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Step) GetOperation() Job_Operation {
+	if x != nil {
+		return x.Operation
+	}
+	return Job_INVALID
+}
+
+func (x *Step) GetSensors() []string {
+	if x != nil {
+		return x.Sensors
+	}
+	return nil
+}
+
+func (x *Step) GetDelay() *duration.Duration {
+	if x != nil {
+		return x.Delay
+	}
+	return nil
+}
+
+// Calibration corrects the values of one metric reported by one sensor. If points
+// is given the raw value is first mapped through the piecewise-linear function they
+// define, and then the result is multiplied by gain and offset is added.
+type Calibration struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The name of the sensor, as given in supported_sensors.
+	Sensor string `protobuf:"bytes,1,opt,name=sensor,proto3" json:"sensor,omitempty"`
+	// The name of the field in the Measurement proto to correct, e.g. "temp".
+	Metric string  `protobuf:"bytes,2,opt,name=metric,proto3" json:"metric,omitempty"`
+	Offset float32 `protobuf:"fixed32,3,opt,name=offset,proto3" json:"offset,omitempty"`
+	// A gain of 0 is treated as 1 so that a calibration may give only an offset.
+	Gain float32 `protobuf:"fixed32,4,opt,name=gain,proto3" json:"gain,omitempty"`
+	// At least two points, sorted by raw value. Values outside of the range of
+	// the points are extrapolated from the nearest segment.
+	Points []*CalibrationPoint `protobuf:"bytes,5,rep,name=points,proto3" json:"points,omitempty"`
+	// Recorded alongside each raw value. Change it whenever the calibration changes.
+	Version string `protobuf:"bytes,6,opt,name=version,proto3" json:"version,omitempty"`
+}
+
+func (x *Calibration) Reset() {
+	*x = Calibration{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_configpb_config_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Calibration) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Calibration) ProtoMessage() {}
+
+func (x *Calibration) ProtoReflect() protoreflect.Message {
+	mi := &file_configpb_config_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Calibration.ProtoReflect.Descriptor instead.
+func (*Calibration) Descriptor() ([]byte, []int) {
+	return file_configpb_config_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Calibration) GetSensor() string {
+	if x != nil {
+		return x.Sensor
+	}
+	return ""
+}
+
+func (x *Calibration) GetMetric() string {
+	if x != nil {
+		return x.Metric
+	}
+	return ""
+}
+
+func (x *Calibration) GetOffset() float32 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+func (x *Calibration) GetGain() float32 {
+	if x != nil {
+		return x.Gain
+	}
+	return 0
+}
+
+func (x *Calibration) GetPoints() []*CalibrationPoint {
+	if x != nil {
+		return x.Points
+	}
+	return nil
+}
+
+func (x *Calibration) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+type CalibrationPoint struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Raw       float32 `protobuf:"fixed32,1,opt,name=raw,proto3" json:"raw,omitempty"`
+	Corrected float32 `protobuf:"fixed32,2,opt,name=corrected,proto3" json:"corrected,omitempty"`
+}
+
+func (x *CalibrationPoint) Reset() {
+	*x = CalibrationPoint{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_configpb_config_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CalibrationPoint) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CalibrationPoint) ProtoMessage() {}
+
+func (x *CalibrationPoint) ProtoReflect() protoreflect.Message {
+	mi := &file_configpb_config_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CalibrationPoint.ProtoReflect.Descriptor instead.
+func (*CalibrationPoint) Descriptor() ([]byte, []int) {
+	return file_configpb_config_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *CalibrationPoint) GetRaw() float32 {
+	if x != nil {
+		return x.Raw
+	}
+	return 0
+}
+
+func (x *CalibrationPoint) GetCorrected() float32 {
+	if x != nil {
+		return x.Corrected
+	}
+	return 0
+}
+
+type MCP9808Config struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// I²C address of the sensor, in [0x18, 0x1f]. Defaults to 0x18.
+	Address    uint32                   `protobuf:"varint,1,opt,name=address,proto3" json:"address,omitempty"`
+	Resolution MCP9808Config_Resolution `protobuf:"varint,2,opt,name=resolution,proto3,enum=config.MCP9808Config_Resolution" json:"resolution,omitempty"`
+	// If set, the alert temperature registers are programmed and the alert
+	// output is enabled. The alert status bits are reported with each reading.
+	Alert *MCP9808Alert `protobuf:"bytes,3,opt,name=alert,proto3" json:"alert,omitempty"`
+}
+
+func (x *MCP9808Config) Reset() {
+	*x = MCP9808Config{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_configpb_config_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *MCP9808Config) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MCP9808Config) ProtoMessage() {}
+
+func (x *MCP9808Config) ProtoReflect() protoreflect.Message {
+	mi := &file_configpb_config_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MCP9808Config.ProtoReflect.Descriptor instead.
+func (*MCP9808Config) Descriptor() ([]byte, []int) {
+	return file_configpb_config_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *MCP9808Config) GetAddress() uint32 {
+	if x != nil {
+		return x.Address
+	}
+	return 0
+}
+
+func (x *MCP9808Config) GetResolution() MCP9808Config_Resolution {
+	if x != nil {
+		return x.Resolution
+	}
+	return MCP9808Config_RESOLUTION_DEFAULT
+}
+
+func (x *MCP9808Config) GetAlert() *MCP9808Alert {
+	if x != nil {
+		return x.Alert
+	}
+	return nil
+}
+
+type MCP9808Alert struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Alert limits in °C, in [-40, 125] with a resolution of 0.25 °C.
+	// They must satisfy lower < upper < critical.
+	Lower      float32                 `protobuf:"fixed32,1,opt,name=lower,proto3" json:"lower,omitempty"`
+	Upper      float32                 `protobuf:"fixed32,2,opt,name=upper,proto3" json:"upper,omitempty"`
+	Critical   float32                 `protobuf:"fixed32,3,opt,name=critical,proto3" json:"critical,omitempty"`
+	Hysteresis MCP9808Alert_Hysteresis `protobuf:"varint,4,opt,name=hysteresis,proto3,enum=config.MCP9808Alert_Hysteresis" json:"hysteresis,omitempty"`
+	// Use interrupt mode for the alert output rather than comparator mode.
+	InterruptMode bool `protobuf:"varint,5,opt,name=interrupt_mode,json=interruptMode,proto3" json:"interrupt_mode,omitempty"`
+	// Drive the alert output high when asserted rather than low.
+	ActiveHigh bool `protobuf:"varint,6,opt,name=active_high,json=activeHigh,proto3" json:"active_high,omitempty"`
+	// Assert the alert output only when the temperature exceeds the critical limit.
+	CriticalOnly bool `protobuf:"varint,7,opt,name=critical_only,json=criticalOnly,proto3" json:"critical_only,omitempty"`
+}
+
+func (x *MCP9808Alert) Reset() {
+	*x = MCP9808Alert{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_configpb_config_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *MCP9808Alert) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MCP9808Alert) ProtoMessage() {}
+
+func (x *MCP9808Alert) ProtoReflect() protoreflect.Message {
+	mi := &file_configpb_config_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MCP9808Alert.ProtoReflect.Descriptor instead.
+func (*MCP9808Alert) Descriptor() ([]byte, []int) {
+	return file_configpb_config_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *MCP9808Alert) GetLower() float32 {
+	if x != nil {
+		return x.Lower
+	}
+	return 0
+}
+
+func (x *MCP9808Alert) GetUpper() float32 {
+	if x != nil {
+		return x.Upper
+	}
+	return 0
+}
+
+func (x *MCP9808Alert) GetCritical() float32 {
+	if x != nil {
+		return x.Critical
+	}
+	return 0
+}
+
+func (x *MCP9808Alert) GetHysteresis() MCP9808Alert_Hysteresis {
+	if x != nil {
+		return x.Hysteresis
+	}
+	return MCP9808Alert_HYSTERESIS_0
+}
+
+func (x *MCP9808Alert) GetInterruptMode() bool {
+	if x != nil {
+		return x.InterruptMode
+	}
+	return false
+}
+
+func (x *MCP9808Alert) GetActiveHigh() bool {
+	if x != nil {
+		return x.ActiveHigh
+	}
+	return false
+}
+
+func (x *MCP9808Alert) GetCriticalOnly() bool {
+	if x != nil {
+		return x.CriticalOnly
+	}
+	return false
+}
+
+type SDS011Config struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The serial port the sensor is connected to. Defaults to /dev/ttyUSB0.
+	Port string `protobuf:"bytes,1,opt,name=port,proto3" json:"port,omitempty"`
+	// How long the fan must run after the sensor is woken before it's read.
+	// Readings taken sooner are unreliable. Defaults to 30 seconds.
+	Warmup *duration.Duration `protobuf:"bytes,2,opt,name=warmup,proto3" json:"warmup,omitempty"`
+	// If non-zero the sensor's built-in working period mode is used: the sensor
+	// wakes itself every working_period_minutes, runs for 30 seconds, and goes
+	// back to sleep. Sensing returns the most recent reading without waiting.
+	// Must be in [0, 30].
+	WorkingPeriodMinutes uint32 `protobuf:"varint,3,opt,name=working_period_minutes,json=workingPeriodMinutes,proto3" json:"working_period_minutes,omitempty"`
+	// The rated lifetime of the sensor in fan-hours. A warning is logged once 90%
+	// of it has been used. Defaults to 8000 hours, the laser diode's rated life.
+	RatedHours uint32 `protobuf:"varint,4,opt,name=rated_hours,json=ratedHours,proto3" json:"rated_hours,omitempty"`
+}
+
+func (x *SDS011Config) Reset() {
+	*x = SDS011Config{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_configpb_config_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SDS011Config) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SDS011Config) ProtoMessage() {}
+
+func (x *SDS011Config) ProtoReflect() protoreflect.Message {
+	mi := &file_configpb_config_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SDS011Config.ProtoReflect.Descriptor instead.
+func (*SDS011Config) Descriptor() ([]byte, []int) {
+	return file_configpb_config_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *SDS011Config) GetPort() string {
+	if x != nil {
+		return x.Port
+	}
+	return ""
+}
+
+func (x *SDS011Config) GetWarmup() *duration.Duration {
+	if x != nil {
+		return x.Warmup
+	}
+	return nil
+}
+
+func (x *SDS011Config) GetWorkingPeriodMinutes() uint32 {
+	if x != nil {
+		return x.WorkingPeriodMinutes
+	}
+	return 0
+}
+
+func (x *SDS011Config) GetRatedHours() uint32 {
+	if x != nil {
+		return x.RatedHours
+	}
+	return 0
+}
+
+var File_configpb_config_proto protoreflect.FileDescriptor
+
+var file_configpb_config_proto_rawDesc = []byte{
+	0x0a, 0x15, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x70, 0x62, 0x2f, 0x63, 0x6f, 0x6e, 0x66, 0x69,
+	0x67, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x06, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x1a,
+	0x1e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66,
+	0x2f, 0x64, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22,
+	0x92, 0x06, 0x0a, 0x06, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x12, 0x28, 0x0a, 0x10, 0x64, 0x65,
+	0x76, 0x69, 0x63, 0x65, 0x5f, 0x66, 0x69, 0x6c, 0x65, 0x5f, 0x70, 0x61, 0x74, 0x68, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x0e, 0x64, 0x65, 0x76, 0x69, 0x63, 0x65, 0x46, 0x69, 0x6c, 0x65,
+	0x50, 0x61, 0x74, 0x68, 0x12, 0x22, 0x0a, 0x0d, 0x63, 0x61, 0x5f, 0x63, 0x65, 0x72, 0x74, 0x73,
+	0x5f, 0x70, 0x61, 0x74, 0x68, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x63, 0x61, 0x43,
+	0x65, 0x72, 0x74, 0x73, 0x50, 0x61, 0x74, 0x68, 0x12, 0x2b, 0x0a, 0x11, 0x73, 0x75, 0x70, 0x70,
+	0x6f, 0x72, 0x74, 0x65, 0x64, 0x5f, 0x73, 0x65, 0x6e, 0x73, 0x6f, 0x72, 0x73, 0x18, 0x03, 0x20,
+	0x03, 0x28, 0x09, 0x52, 0x10, 0x73, 0x75, 0x70, 0x70, 0x6f, 0x72, 0x74, 0x65, 0x64, 0x53, 0x65,
+	0x6e, 0x73, 0x6f, 0x72, 0x73, 0x12, 0x1f, 0x0a, 0x04, 0x6a, 0x6f, 0x62, 0x73, 0x18, 0x04, 0x20,
+	0x03, 0x28, 0x0b, 0x32, 0x0b, 0x2e, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x2e, 0x4a, 0x6f, 0x62,
+	0x52, 0x04, 0x6a, 0x6f, 0x62, 0x73, 0x12, 0x37, 0x0a, 0x0c, 0x63, 0x61, 0x6c, 0x69, 0x62, 0x72,
+	0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x05, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x13, 0x2e, 0x63,
+	0x6f, 0x6e, 0x66, 0x69, 0x67, 0x2e, 0x43, 0x61, 0x6c, 0x69, 0x62, 0x72, 0x61, 0x74, 0x69, 0x6f,
+	0x6e, 0x52, 0x0c, 0x63, 0x61, 0x6c, 0x69, 0x62, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x12,
+	0x2f, 0x0a, 0x07, 0x6d, 0x63, 0x70, 0x39, 0x38, 0x30, 0x38, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x15, 0x2e, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x2e, 0x4d, 0x43, 0x50, 0x39, 0x38, 0x30,
+	0x38, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x52, 0x07, 0x6d, 0x63, 0x70, 0x39, 0x38, 0x30, 0x38,
+	0x12, 0x2c, 0x0a, 0x06, 0x73, 0x64, 0x73, 0x30, 0x31, 0x31, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x14, 0x2e, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x2e, 0x53, 0x44, 0x53, 0x30, 0x31, 0x31,
+	0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x52, 0x06, 0x73, 0x64, 0x73, 0x30, 0x31, 0x31, 0x12, 0x3b,
+	0x0a, 0x09, 0x6c, 0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x08, 0x20, 0x03, 0x28,
+	0x0b, 0x32, 0x1d, 0x2e, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x2e, 0x43, 0x6f, 0x6e, 0x66, 0x69,
+	0x67, 0x2e, 0x4c, 0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79,
+	0x52, 0x09, 0x6c, 0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x12, 0x28, 0x0a, 0x10, 0x6a,
+	0x6f, 0x62, 0x5f, 0x68, 0x69, 0x73, 0x74, 0x6f, 0x72, 0x79, 0x5f, 0x73, 0x69, 0x7a, 0x65, 0x18,
+	0x09, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0e, 0x6a, 0x6f, 0x62, 0x48, 0x69, 0x73, 0x74, 0x6f, 0x72,
+	0x79, 0x53, 0x69, 0x7a, 0x65, 0x12, 0x32, 0x0a, 0x15, 0x6a, 0x6f, 0x62, 0x5f, 0x66, 0x61, 0x69,
+	0x6c, 0x75, 0x72, 0x65, 0x5f, 0x74, 0x68, 0x72, 0x65, 0x73, 0x68, 0x6f, 0x6c, 0x64, 0x18, 0x0a,
+	0x20, 0x01, 0x28, 0x0d, 0x52, 0x13, 0x6a, 0x6f, 0x62, 0x46, 0x61, 0x69, 0x6c, 0x75, 0x72, 0x65,
+	0x54, 0x68, 0x72, 0x65, 0x73, 0x68, 0x6f, 0x6c, 0x64, 0x12, 0x4e, 0x0a, 0x10, 0x70, 0x75, 0x62,
+	0x6c, 0x69, 0x73, 0x68, 0x5f, 0x70, 0x6f, 0x6c, 0x69, 0x63, 0x69, 0x65, 0x73, 0x18, 0x0b, 0x20,
+	0x03, 0x28, 0x0b, 0x32, 0x23, 0x2e, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x2e, 0x43, 0x6f, 0x6e,
+	0x66, 0x69, 0x67, 0x2e, 0x50, 0x75, 0x62, 0x6c, 0x69, 0x73, 0x68, 0x50, 0x6f, 0x6c, 0x69, 0x63,
+	0x69, 0x65, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x0f, 0x70, 0x75, 0x62, 0x6c, 0x69, 0x73,
+	0x68, 0x50, 0x6f, 0x6c, 0x69, 0x63, 0x69, 0x65, 0x73, 0x12, 0x23, 0x0a, 0x05, 0x62, 0x61, 0x74,
+	0x63, 0x68, 0x18, 0x0c, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0d, 0x2e, 0x63, 0x6f, 0x6e, 0x66, 0x69,
+	0x67, 0x2e, 0x42, 0x61, 0x74, 0x63, 0x68, 0x52, 0x05, 0x62, 0x61, 0x74, 0x63, 0x68, 0x12, 0x2b,
+	0x0a, 0x11, 0x73, 0x69, 0x67, 0x6e, 0x5f, 0x6d, 0x65, 0x61, 0x73, 0x75, 0x72, 0x65, 0x6d, 0x65,
+	0x6e, 0x74, 0x73, 0x18, 0x0d, 0x20, 0x01, 0x28, 0x08, 0x52, 0x10, 0x73, 0x69, 0x67, 0x6e, 0x4d,
+	0x65, 0x61, 0x73, 0x75, 0x72, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x73, 0x1a, 0x3c, 0x0a, 0x0e, 0x4c,
+	0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a,
+	0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12,
+	0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05,
+	0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x1a, 0x59, 0x0a, 0x14, 0x50, 0x75, 0x62,
+	0x6c, 0x69, 0x73, 0x68, 0x50, 0x6f, 0x6c, 0x69, 0x63, 0x69, 0x65, 0x73, 0x45, 0x6e, 0x74, 0x72,
+	0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03,
+	0x6b, 0x65, 0x79, 0x12, 0x2b, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x15, 0x2e, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x2e, 0x50, 0x75, 0x62, 0x6c,
+	0x69, 0x73, 0x68, 0x50, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65,
+	0x3a, 0x02, 0x38, 0x01, 0x22, 0x6a, 0x0a, 0x05, 0x42, 0x61, 0x74, 0x63, 0x68, 0x12, 0x29, 0x0a,
+	0x10, 0x6d, 0x61, 0x78, 0x5f, 0x6d, 0x65, 0x61, 0x73, 0x75, 0x72, 0x65, 0x6d, 0x65, 0x6e, 0x74,
+	0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0f, 0x6d, 0x61, 0x78, 0x4d, 0x65, 0x61, 0x73,
+	0x75, 0x72, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x73, 0x12, 0x36, 0x0a, 0x09, 0x6d, 0x61, 0x78, 0x5f,
+	0x64, 0x65, 0x6c, 0x61, 0x79, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x67, 0x6f,
+	0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x44, 0x75,
+	0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x08, 0x6d, 0x61, 0x78, 0x44, 0x65, 0x6c, 0x61, 0x79,
+	0x22, 0x64, 0x0a, 0x0d, 0x50, 0x75, 0x62, 0x6c, 0x69, 0x73, 0x68, 0x50, 0x6f, 0x6c, 0x69, 0x63,
+	0x79, 0x12, 0x1a, 0x0a, 0x08, 0x64, 0x65, 0x61, 0x64, 0x62, 0x61, 0x6e, 0x64, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x02, 0x52, 0x08, 0x64, 0x65, 0x61, 0x64, 0x62, 0x61, 0x6e, 0x64, 0x12, 0x37, 0x0a,
+	0x09, 0x68, 0x65, 0x61, 0x72, 0x74, 0x62, 0x65, 0x61, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x19, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62,
+	0x75, 0x66, 0x2e, 0x44, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x09, 0x68, 0x65, 0x61,
+	0x72, 0x74, 0x62, 0x65, 0x61, 0x74, 0x22, 0xd3, 0x03, 0x0a, 0x03, 0x4a, 0x6f, 0x62, 0x12, 0x1a,
+	0x0a, 0x08, 0x63, 0x72, 0x6f, 0x6e, 0x73, 0x70, 0x65, 0x63, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x08, 0x63, 0x72, 0x6f, 0x6e, 0x73, 0x70, 0x65, 0x63, 0x12, 0x33, 0x0a, 0x09, 0x6f, 0x70,
+	0x65, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x15, 0x2e,
+	0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x2e, 0x4a, 0x6f, 0x62, 0x2e, 0x4f, 0x70, 0x65, 0x72, 0x61,
+	0x74, 0x69, 0x6f, 0x6e, 0x52, 0x09, 0x6f, 0x70, 0x65, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12,
+	0x18, 0x0a, 0x07, 0x73, 0x65, 0x6e, 0x73, 0x6f, 0x72, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x09,
+	0x52, 0x07, 0x73, 0x65, 0x6e, 0x73, 0x6f, 0x72, 0x73, 0x12, 0x22, 0x0a, 0x05, 0x73, 0x74, 0x65,
+	0x70, 0x73, 0x18, 0x04, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x0c, 0x2e, 0x63, 0x6f, 0x6e, 0x66, 0x69,
+	0x67, 0x2e, 0x53, 0x74, 0x65, 0x70, 0x52, 0x05, 0x73, 0x74, 0x65, 0x70, 0x73, 0x12, 0x33, 0x0a,
+	0x07, 0x74, 0x69, 0x6d, 0x65, 0x6f, 0x75, 0x74, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x19,
+	0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66,
+	0x2e, 0x44, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x07, 0x74, 0x69, 0x6d, 0x65, 0x6f,
+	0x75, 0x74, 0x12, 0x2d, 0x0a, 0x07, 0x6f, 0x76, 0x65, 0x72, 0x6c, 0x61, 0x70, 0x18, 0x06, 0x20,
+	0x01, 0x28, 0x0e, 0x32, 0x13, 0x2e, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x2e, 0x4a, 0x6f, 0x62,
+	0x2e, 0x4f, 0x76, 0x65, 0x72, 0x6c, 0x61, 0x70, 0x52, 0x07, 0x6f, 0x76, 0x65, 0x72, 0x6c, 0x61,
+	0x70, 0x12, 0x48, 0x0a, 0x12, 0x61, 0x67, 0x67, 0x72, 0x65, 0x67, 0x61, 0x74, 0x69, 0x6f, 0x6e,
+	0x5f, 0x77, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e,
+	0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e,
+	0x44, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x11, 0x61, 0x67, 0x67, 0x72, 0x65, 0x67,
+	0x61, 0x74, 0x69, 0x6f, 0x6e, 0x57, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x22, 0x4a, 0x0a, 0x09, 0x4f,
+	0x70, 0x65, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x0b, 0x0a, 0x07, 0x49, 0x4e, 0x56, 0x41,
+	0x4c, 0x49, 0x44, 0x10, 0x00, 0x12, 0x09, 0x0a, 0x05, 0x53, 0x45, 0x54, 0x55, 0x50, 0x10, 0x01,
+	0x12, 0x09, 0x0a, 0x05, 0x53, 0x45, 0x4e, 0x53, 0x45, 0x10, 0x02, 0x12, 0x0c, 0x0a, 0x08, 0x53,
+	0x48, 0x55, 0x54, 0x44, 0x4f, 0x57, 0x4e, 0x10, 0x03, 0x12, 0x0c, 0x0a, 0x08, 0x53, 0x45, 0x51,
+	0x55, 0x45, 0x4e, 0x43, 0x45, 0x10, 0x04, 0x22, 0x43, 0x0a, 0x07, 0x4f, 0x76, 0x65, 0x72, 0x6c,
+	0x61, 0x70, 0x12, 0x13, 0x0a, 0x0f, 0x4f, 0x56, 0x45, 0x52, 0x4c, 0x41, 0x50, 0x5f, 0x44, 0x45,
+	0x46, 0x41, 0x55, 0x4c, 0x54, 0x10, 0x00, 0x12, 0x10, 0x0a, 0x0c, 0x4f, 0x56, 0x45, 0x52, 0x4c,
+	0x41, 0x50, 0x5f, 0x53, 0x4b, 0x49, 0x50, 0x10, 0x01, 0x12, 0x11, 0x0a, 0x0d, 0x4f, 0x56, 0x45,
+	0x52, 0x4c, 0x41, 0x50, 0x5f, 0x51, 0x55, 0x45, 0x55, 0x45, 0x10, 0x02, 0x22, 0x86, 0x01, 0x0a,
+	0x04, 0x53, 0x74, 0x65, 0x70, 0x12, 0x33, 0x0a, 0x09, 0x6f, 0x70, 0x65, 0x72, 0x61, 0x74, 0x69,
+	0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x15, 0x2e, 0x63, 0x6f, 0x6e, 0x66, 0x69,
+	0x67, 0x2e, 0x4a, 0x6f, 0x62, 0x2e, 0x4f, 0x70, 0x65, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52,
+	0x09, 0x6f, 0x70, 0x65, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x18, 0x0a, 0x07, 0x73, 0x65,
+	0x6e, 0x73, 0x6f, 0x72, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x09, 0x52, 0x07, 0x73, 0x65, 0x6e,
+	0x73, 0x6f, 0x72, 0x73, 0x12, 0x2f, 0x0a, 0x05, 0x64, 0x65, 0x6c, 0x61, 0x79, 0x18, 0x03, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x44, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x05,
+	0x64, 0x65, 0x6c, 0x61, 0x79, 0x22, 0xb5, 0x01, 0x0a, 0x0b, 0x43, 0x61, 0x6c, 0x69, 0x62, 0x72,
+	0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x65, 0x6e, 0x73, 0x6f, 0x72, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x73, 0x65, 0x6e, 0x73, 0x6f, 0x72, 0x12, 0x16, 0x0a,
+	0x06, 0x6d, 0x65, 0x74, 0x72, 0x69, 0x63, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x6d,
+	0x65, 0x74, 0x72, 0x69, 0x63, 0x12, 0x16, 0x0a, 0x06, 0x6f, 0x66, 0x66, 0x73, 0x65, 0x74, 0x18,
+	0x03, 0x20, 0x01, 0x28, 0x02, 0x52, 0x06, 0x6f, 0x66, 0x66, 0x73, 0x65, 0x74, 0x12, 0x12, 0x0a,
+	0x04, 0x67, 0x61, 0x69, 0x6e, 0x18, 0x04, 0x20, 0x01, 0x28, 0x02, 0x52, 0x04, 0x67, 0x61, 0x69,
+	0x6e, 0x12, 0x30, 0x0a, 0x06, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x73, 0x18, 0x05, 0x20, 0x03, 0x28,
+	0x0b, 0x32, 0x18, 0x2e, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x2e, 0x43, 0x61, 0x6c, 0x69, 0x62,
+	0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x50, 0x6f, 0x69, 0x6e, 0x74, 0x52, 0x06, 0x70, 0x6f, 0x69,
+	0x6e, 0x74, 0x73, 0x12, 0x18, 0x0a, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x06,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x22, 0x42, 0x0a,
+	0x10, 0x43, 0x61, 0x6c, 0x69, 0x62, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x50, 0x6f, 0x69, 0x6e,
+	0x74, 0x12, 0x10, 0x0a, 0x03, 0x72, 0x61, 0x77, 0x18, 0x01, 0x20, 0x01, 0x28, 0x02, 0x52, 0x03,
+	0x72, 0x61, 0x77, 0x12, 0x1c, 0x0a, 0x09, 0x63, 0x6f, 0x72, 0x72, 0x65, 0x63, 0x74, 0x65, 0x64,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x02, 0x52, 0x09, 0x63, 0x6f, 0x72, 0x72, 0x65, 0x63, 0x74, 0x65,
+	0x64, 0x22, 0x93, 0x02, 0x0a, 0x0d, 0x4d, 0x43, 0x50, 0x39, 0x38, 0x30, 0x38, 0x43, 0x6f, 0x6e,
+	0x66, 0x69, 0x67, 0x12, 0x18, 0x0a, 0x07, 0x61, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x0d, 0x52, 0x07, 0x61, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x12, 0x40, 0x0a,
+	0x0a, 0x72, 0x65, 0x73, 0x6f, 0x6c, 0x75, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x0e, 0x32, 0x20, 0x2e, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x2e, 0x4d, 0x43, 0x50, 0x39, 0x38,
+	0x30, 0x38, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x2e, 0x52, 0x65, 0x73, 0x6f, 0x6c, 0x75, 0x74,
+	0x69, 0x6f, 0x6e, 0x52, 0x0a, 0x72, 0x65, 0x73, 0x6f, 0x6c, 0x75, 0x74, 0x69, 0x6f, 0x6e, 0x12,
+	0x2a, 0x0a, 0x05, 0x61, 0x6c, 0x65, 0x72, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x14,
+	0x2e, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x2e, 0x4d, 0x43, 0x50, 0x39, 0x38, 0x30, 0x38, 0x41,
+	0x6c, 0x65, 0x72, 0x74, 0x52, 0x05, 0x61, 0x6c, 0x65, 0x72, 0x74, 0x22, 0x7a, 0x0a, 0x0a, 0x52,
+	0x65, 0x73, 0x6f, 0x6c, 0x75, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x16, 0x0a, 0x12, 0x52, 0x45, 0x53,
+	0x4f, 0x4c, 0x55, 0x54, 0x49, 0x4f, 0x4e, 0x5f, 0x44, 0x45, 0x46, 0x41, 0x55, 0x4c, 0x54, 0x10,
+	0x00, 0x12, 0x12, 0x0a, 0x0e, 0x52, 0x45, 0x53, 0x4f, 0x4c, 0x55, 0x54, 0x49, 0x4f, 0x4e, 0x5f,
+	0x30, 0x5f, 0x35, 0x10, 0x01, 0x12, 0x13, 0x0a, 0x0f, 0x52, 0x45, 0x53, 0x4f, 0x4c, 0x55, 0x54,
+	0x49, 0x4f, 0x4e, 0x5f, 0x30, 0x5f, 0x32, 0x35, 0x10, 0x02, 0x12, 0x14, 0x0a, 0x10, 0x52, 0x45,
+	0x53, 0x4f, 0x4c, 0x55, 0x54, 0x49, 0x4f, 0x4e, 0x5f, 0x30, 0x5f, 0x31, 0x32, 0x35, 0x10, 0x03,
+	0x12, 0x15, 0x0a, 0x11, 0x52, 0x45, 0x53, 0x4f, 0x4c, 0x55, 0x54, 0x49, 0x4f, 0x4e, 0x5f, 0x30,
+	0x5f, 0x30, 0x36, 0x32, 0x35, 0x10, 0x04, 0x22, 0xdc, 0x02, 0x0a, 0x0c, 0x4d, 0x43, 0x50, 0x39,
+	0x38, 0x30, 0x38, 0x41, 0x6c, 0x65, 0x72, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x6c, 0x6f, 0x77, 0x65,
+	0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x02, 0x52, 0x05, 0x6c, 0x6f, 0x77, 0x65, 0x72, 0x12, 0x14,
+	0x0a, 0x05, 0x75, 0x70, 0x70, 0x65, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x02, 0x52, 0x05, 0x75,
+	0x70, 0x70, 0x65, 0x72, 0x12, 0x1a, 0x0a, 0x08, 0x63, 0x72, 0x69, 0x74, 0x69, 0x63, 0x61, 0x6c,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x02, 0x52, 0x08, 0x63, 0x72, 0x69, 0x74, 0x69, 0x63, 0x61, 0x6c,
+	0x12, 0x3f, 0x0a, 0x0a, 0x68, 0x79, 0x73, 0x74, 0x65, 0x72, 0x65, 0x73, 0x69, 0x73, 0x18, 0x04,
+	0x20, 0x01, 0x28, 0x0e, 0x32, 0x1f, 0x2e, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x2e, 0x4d, 0x43,
+	0x50, 0x39, 0x38, 0x30, 0x38, 0x41, 0x6c, 0x65, 0x72, 0x74, 0x2e, 0x48, 0x79, 0x73, 0x74, 0x65,
+	0x72, 0x65, 0x73, 0x69, 0x73, 0x52, 0x0a, 0x68, 0x79, 0x73, 0x74, 0x65, 0x72, 0x65, 0x73, 0x69,
+	0x73, 0x12, 0x25, 0x0a, 0x0e, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x72, 0x75, 0x70, 0x74, 0x5f, 0x6d,
+	0x6f, 0x64, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0d, 0x69, 0x6e, 0x74, 0x65, 0x72,
+	0x72, 0x75, 0x70, 0x74, 0x4d, 0x6f, 0x64, 0x65, 0x12, 0x1f, 0x0a, 0x0b, 0x61, 0x63, 0x74, 0x69,
+	0x76, 0x65, 0x5f, 0x68, 0x69, 0x67, 0x68, 0x18, 0x06, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0a, 0x61,
+	0x63, 0x74, 0x69, 0x76, 0x65, 0x48, 0x69, 0x67, 0x68, 0x12, 0x23, 0x0a, 0x0d, 0x63, 0x72, 0x69,
+	0x74, 0x69, 0x63, 0x61, 0x6c, 0x5f, 0x6f, 0x6e, 0x6c, 0x79, 0x18, 0x07, 0x20, 0x01, 0x28, 0x08,
+	0x52, 0x0c, 0x63, 0x72, 0x69, 0x74, 0x69, 0x63, 0x61, 0x6c, 0x4f, 0x6e, 0x6c, 0x79, 0x22, 0x56,
+	0x0a, 0x0a, 0x48, 0x79, 0x73, 0x74, 0x65, 0x72, 0x65, 0x73, 0x69, 0x73, 0x12, 0x10, 0x0a, 0x0c,
+	0x48, 0x59, 0x53, 0x54, 0x45, 0x52, 0x45, 0x53, 0x49, 0x53, 0x5f, 0x30, 0x10, 0x00, 0x12, 0x12,
+	0x0a, 0x0e, 0x48, 0x59, 0x53, 0x54, 0x45, 0x52, 0x45, 0x53, 0x49, 0x53, 0x5f, 0x31, 0x5f, 0x35,
+	0x10, 0x01, 0x12, 0x10, 0x0a, 0x0c, 0x48, 0x59, 0x53, 0x54, 0x45, 0x52, 0x45, 0x53, 0x49, 0x53,
+	0x5f, 0x33, 0x10, 0x02, 0x12, 0x10, 0x0a, 0x0c, 0x48, 0x59, 0x53, 0x54, 0x45, 0x52, 0x45, 0x53,
+	0x49, 0x53, 0x5f, 0x36, 0x10, 0x03, 0x22, 0xac, 0x01, 0x0a, 0x0c, 0x53, 0x44, 0x53, 0x30, 0x31,
+	0x31, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x12, 0x12, 0x0a, 0x04, 0x70, 0x6f, 0x72, 0x74, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x70, 0x6f, 0x72, 0x74, 0x12, 0x31, 0x0a, 0x06, 0x77,
+	0x61, 0x72, 0x6d, 0x75, 0x70, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x67, 0x6f,
+	0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x44, 0x75,
+	0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x06, 0x77, 0x61, 0x72, 0x6d, 0x75, 0x70, 0x12, 0x34,
+	0x0a, 0x16, 0x77, 0x6f, 0x72, 0x6b, 0x69, 0x6e, 0x67, 0x5f, 0x70, 0x65, 0x72, 0x69, 0x6f, 0x64,
+	0x5f, 0x6d, 0x69, 0x6e, 0x75, 0x74, 0x65, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x14,
+	0x77, 0x6f, 0x72, 0x6b, 0x69, 0x6e, 0x67, 0x50, 0x65, 0x72, 0x69, 0x6f, 0x64, 0x4d, 0x69, 0x6e,
+	0x75, 0x74, 0x65, 0x73, 0x12, 0x1f, 0x0a, 0x0b, 0x72, 0x61, 0x74, 0x65, 0x64, 0x5f, 0x68, 0x6f,
+	0x75, 0x72, 0x73, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0a, 0x72, 0x61, 0x74, 0x65, 0x64,
+	0x48, 0x6f, 0x75, 0x72, 0x73, 0x42, 0x32, 0x5a, 0x30, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e,
+	0x63, 0x6f, 0x6d, 0x2f, 0x6d, 0x74, 0x72, 0x61, 0x76, 0x65, 0x72, 0x2f, 0x65, 0x6e, 0x76, 0x69,
+	0x72, 0x6f, 0x6e, 0x6d, 0x65, 0x6e, 0x74, 0x61, 0x6c, 0x2d, 0x73, 0x65, 0x6e, 0x73, 0x6f, 0x72,
+	0x2f, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x33,
+}
+
+var (
+	file_configpb_config_proto_rawDescOnce sync.Once
+	file_configpb_config_proto_rawDescData = file_configpb_config_proto_rawDesc
+)
+
+func file_configpb_config_proto_rawDescGZIP() []byte {
+	file_configpb_config_proto_rawDescOnce.Do(func() {
+		file_configpb_config_proto_rawDescData = protoimpl.X.CompressGZIP(file_configpb_config_proto_rawDescData)
+	})
+	return file_configpb_config_proto_rawDescData
+}
+
+var file_configpb_config_proto_enumTypes = make([]protoimpl.EnumInfo, 4)
+var file_configpb_config_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
+var file_configpb_config_proto_goTypes = []interface{}{
+	(Job_Operation)(0),            // 0: config.Job.Operation
+	(Job_Overlap)(0),              // 1: config.Job.Overlap
+	(MCP9808Config_Resolution)(0), // 2: config.MCP9808Config.Resolution
+	(MCP9808Alert_Hysteresis)(0),  // 3: config.MCP9808Alert.Hysteresis
+	(*Config)(nil),                // 4: config.Config
+	(*Batch)(nil),                 // 5: config.Batch
+	(*PublishPolicy)(nil),         // 6: config.PublishPolicy
+	(*Job)(nil),                   // 7: config.Job
+	(*Step)(nil),                  // 8: config.Step
+	(*Calibration)(nil),           // 9: config.Calibration
+	(*CalibrationPoint)(nil),      // 10: config.CalibrationPoint
+	(*MCP9808Config)(nil),         // 11: config.MCP9808Config
+	(*MCP9808Alert)(nil),          // 12: config.MCP9808Alert
+	(*SDS011Config)(nil),          // 13: config.SDS011Config
+	nil,                           // 14: config.Config.LocationsEntry
+	nil,                           // 15: config.Config.PublishPoliciesEntry
+	(*duration.Duration)(nil),     // 16: google.protobuf.Duration
+}
+var file_configpb_config_proto_depIdxs = []int32{
+	7,  // 0: config.Config.jobs:type_name -> config.Job
+	9,  // 1: config.Config.calibrations:type_name -> config.Calibration
+	11, // 2: config.Config.mcp9808:type_name -> config.MCP9808Config
+	13, // 3: config.Config.sds011:type_name -> config.SDS011Config
+	14, // 4: config.Config.locations:type_name -> config.Config.LocationsEntry
+	15, // 5: config.Config.publish_policies:type_name -> config.Config.PublishPoliciesEntry
+	5,  // 6: config.Config.batch:type_name -> config.Batch
+	16, // 7: config.Batch.max_delay:type_name -> google.protobuf.Duration
+	16, // 8: config.PublishPolicy.heartbeat:type_name -> google.protobuf.Duration
+	0,  // 9: config.Job.operation:type_name -> config.Job.Operation
+	8,  // 10: config.Job.steps:type_name -> config.Step
+	16, // 11: config.Job.timeout:type_name -> google.protobuf.Duration
+	1,  // 12: config.Job.overlap:type_name -> config.Job.Overlap
+	16, // 13: config.Job.aggregation_window:type_name -> google.protobuf.Duration
+	0,  // 14: config.Step.operation:type_name -> config.Job.Operation
+	16, // 15: config.Step.delay:type_name -> google.protobuf.Duration
+	10, // 16: config.Calibration.points:type_name -> config.CalibrationPoint
+	2,  // 17: config.MCP9808Config.resolution:type_name -> config.MCP9808Config.Resolution
+	12, // 18: config.MCP9808Config.alert:type_name -> config.MCP9808Alert
+	3,  // 19: config.MCP9808Alert.hysteresis:type_name -> config.MCP9808Alert.Hysteresis
+	16, // 20: config.SDS011Config.warmup:type_name -> google.protobuf.Duration
+	6,  // 21: config.Config.PublishPoliciesEntry.value:type_name -> config.PublishPolicy
+	22, // [22:22] is the sub-list for method output_type
+	22, // [22:22] is the sub-list for method input_type
+	22, // [22:22] is the sub-list for extension type_name
+	22, // [22:22] is the sub-list for extension extendee
+	0,  // [0:22] is the sub-list for field type_name
+}
+
+func init() { file_configpb_config_proto_init() }
+func file_configpb_config_proto_init() {
+	if File_configpb_config_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_configpb_config_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Config); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -299,6 +1370,30 @@ func file_configpb_config_proto_init() {
 			}
 		}
 		file_configpb_config_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Batch); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_configpb_config_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PublishPolicy); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_configpb_config_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*Job); i {
 			case 0:
 				return &v.state
@@ -310,14 +1405,86 @@ func file_configpb_config_proto_init() {
 				return nil
 			}
 		}
+		file_configpb_config_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Step); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_configpb_config_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Calibration); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_configpb_config_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CalibrationPoint); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_configpb_config_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*MCP9808Config); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_configpb_config_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*MCP9808Alert); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_configpb_config_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SDS011Config); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_configpb_config_proto_rawDesc,
-			NumEnums:      1,
-			NumMessages:   2,
+			NumEnums:      4,
+			NumMessages:   12,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
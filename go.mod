@@ -6,13 +6,15 @@ require (
 	cloud.google.com/go/logging v1.2.0 // indirect
 	cloud.google.com/go/pubsub v1.10.0
 	cloud.google.com/go/storage v1.13.0 // indirect
-	github.com/albenik/go-serial/v2 v2.1.0 // indirect
+	github.com/albenik/go-serial/v2 v2.1.0
 	github.com/deepmap/oapi-codegen v1.5.1 // indirect
 	github.com/eclipse/paho.mqtt.golang v1.3.2
 	github.com/golang/protobuf v1.4.3
 	github.com/google/go-cmp v0.5.4
 	github.com/influxdata/influxdb-client-go/v2 v2.2.2
 	github.com/influxdata/line-protocol v0.0.0-20201012155213-5f565037cbc9 // indirect
+	github.com/lib/pq v1.9.0
+	github.com/mattn/go-sqlite3 v1.14.6
 	github.com/mitchellh/go-homedir v1.1.0
 	github.com/mtraver/gaelog v0.2.1
 	github.com/mtraver/iotcore v0.0.0-20210120050705-2aa1443c5fbf
@@ -21,7 +23,7 @@ require (
 	github.com/stretchr/testify v1.7.0 // indirect
 	go.opencensus.io v0.22.6 // indirect
 	golang.org/x/oauth2 v0.0.0-20210216194517-16ff1888fd2e
-	golang.org/x/sys v0.0.0-20210217105451-b926d437f341 // indirect
+	golang.org/x/sys v0.0.0-20210217105451-b926d437f341
 	google.golang.org/api v0.40.0
 	google.golang.org/appengine v1.6.7
 	google.golang.org/genproto v0.0.0-20210217220511-c18582744cc2 // indirect
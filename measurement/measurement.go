@@ -10,6 +10,7 @@ import (
 
 	"github.com/mtraver/environmental-sensor/aqi"
 	mpb "github.com/mtraver/environmental-sensor/measurementpb"
+	durationpb "google.golang.org/protobuf/types/known/durationpb"
 	tspb "google.golang.org/protobuf/types/known/timestamppb"
 	wpb "google.golang.org/protobuf/types/known/wrapperspb"
 )
@@ -94,6 +95,21 @@ type StorableMeasurement struct {
 	Timestamp       time.Time `json:"-" datastore:"timestamp"`
 	UploadTimestamp time.Time `json:"-" datastore:"upload_timestamp,omitempty"`
 
+	// Window is the length of the aggregation window that the measurement summarizes, starting
+	// at Timestamp. It's zero if the measurement is a single sample.
+	Window time.Duration `json:"-" datastore:"window,omitempty"`
+
+	// Heartbeat is the longest the device should go without publishing. It's zero unless the
+	// device publishes by exception, skipping samples that are close to the last published
+	// values. A gap between measurements that's much longer than it means data was lost.
+	Heartbeat time.Duration `json:"-" datastore:"heartbeat,noindex,omitempty"`
+
+	// Sequence numbers the measurements published by a device within the boot identified by
+	// BootID. Datastore doesn't support unsigned integers, hence int64. BootID is empty for
+	// measurements from devices that predate them.
+	Sequence int64  `json:"-" datastore:"sequence,noindex,omitempty"`
+	BootID   string `json:"-" datastore:"boot_id,noindex,omitempty"`
+
 	// These metrics are the raw values reported by sensors. They must match the
 	// metrics defined in the generated Measurement type (from measurement.proto).
 	Temp *float32 `json:"temp,omitempty" datastore:"temp,omitempty" metric:"temp" unit:"°C"`
@@ -105,22 +121,102 @@ type StorableMeasurement struct {
 	// (the `datastore` tag is set to "-") but they are passed to the frontend in JSON form.
 	// These values are populated by the FillDerivedMetrics method.
 	AQI *float32 `json:"aqi,omitempty" datastore:"-" metric:"AQI" unit:""`
+
+	// RawValues holds the uncalibrated values of any metrics that were corrected on the device.
+	RawValues []RawValue `json:"-" datastore:"raw_values,noindex,omitempty"`
+
+	// Readings holds the value of each metric as reported by each sensor. It's empty for
+	// measurements from devices that predate it.
+	Readings []Reading `json:"-" datastore:"readings,noindex,omitempty"`
+
+	// Summaries holds the minimum, maximum, and mean of each metric over the aggregation
+	// window. It's empty if the measurement is a single sample. The metrics above hold the means.
+	Summaries []Summary `json:"-" datastore:"summaries,noindex,omitempty"`
+}
+
+// Reading is equivalent to the generated Reading type. See measurement.proto.
+type Reading struct {
+	Metric   string  `datastore:"metric"`
+	Sensor   string  `datastore:"sensor"`
+	Location string  `datastore:"location,omitempty"`
+	Value    float32 `datastore:"value"`
+}
+
+// Label returns the reading's location if it has one and otherwise the name of its sensor.
+func (r Reading) Label() string {
+	if r.Location != "" {
+		return r.Location
+	}
+	return r.Sensor
+}
+
+// Summary is equivalent to the generated Summary type. See measurement.proto.
+type Summary struct {
+	Metric   string  `datastore:"metric"`
+	Sensor   string  `datastore:"sensor"`
+	Location string  `datastore:"location,omitempty"`
+	Min      float32 `datastore:"min"`
+	Max      float32 `datastore:"max"`
+	Mean     float32 `datastore:"mean"`
+	Count    int64   `datastore:"count"`
+}
+
+// Label returns the summary's location if it has one and otherwise the name of its sensor.
+func (s Summary) Label() string {
+	return Reading{Sensor: s.Sensor, Location: s.Location}.Label()
+}
+
+// RawValue is equivalent to the generated RawValue type. See measurement.proto.
+type RawValue struct {
+	Metric             string  `datastore:"metric"`
+	Sensor             string  `datastore:"sensor"`
+	Value              float32 `datastore:"value"`
+	CalibrationVersion string  `datastore:"calibration_version,omitempty"`
 }
 
 func (sm StorableMeasurement) MarshalJSON() ([]byte, error) {
 	// Alias the type so that we don't infinitely recurse.
 	type alias StorableMeasurement
 
+	min, max := sm.bounds()
 	return json.Marshal(&struct {
 		alias
-		Ts int64 `json:"ts,omitempty"`
+		Ts        int64              `json:"ts,omitempty"`
+		Min       map[string]float32 `json:"min,omitempty"`
+		Max       map[string]float32 `json:"max,omitempty"`
+		Heartbeat int64              `json:"heartbeat,omitempty"`
 	}{
 		alias: (alias)(sm),
 		// Convert the original timestamp to an offset from the epoch in milliseconds.
-		Ts: sm.Timestamp.Unix() * 1000,
+		Ts:  sm.Timestamp.Unix() * 1000,
+		Min: min,
+		Max: max,
+		// The heartbeat is also in milliseconds.
+		Heartbeat: sm.Heartbeat.Milliseconds(),
 	})
 }
 
+// bounds returns the minimum and maximum of each summarized metric, keyed by JSON key. If a
+// metric was summarized for more than one sensor the first summary is used, because that's
+// the sensor whose mean is in the metric's field. Both maps are nil if there are no summaries.
+func (sm StorableMeasurement) bounds() (map[string]float32, map[string]float32) {
+	if len(sm.Summaries) == 0 {
+		return nil, nil
+	}
+
+	min := make(map[string]float32)
+	max := make(map[string]float32)
+	for _, s := range sm.Summaries {
+		if _, ok := min[s.Metric]; ok {
+			continue
+		}
+		min[s.Metric] = s.Min
+		max[s.Metric] = s.Max
+	}
+
+	return min, max
+}
+
 // NewStorableMeasurement converts the generated Measurement type to a StorableMeasurement,
 // which contains no protobuf-specific types, and therefore can be marshaled to JSON and
 // written to Datastore.
@@ -172,14 +268,70 @@ func NewStorableMeasurement(m *mpb.Measurement) (StorableMeasurement, error) {
 		rh = &v
 	}
 
+	var rawValues []RawValue
+	for _, rv := range m.GetRawValues() {
+		rawValues = append(rawValues, RawValue{
+			Metric:             rv.GetMetric(),
+			Sensor:             rv.GetSensor(),
+			Value:              rv.GetValue(),
+			CalibrationVersion: rv.GetCalibrationVersion(),
+		})
+	}
+
+	var readings []Reading
+	for _, r := range m.GetReadings() {
+		readings = append(readings, Reading{
+			Metric:   r.GetMetric(),
+			Sensor:   r.GetSensor(),
+			Location: r.GetLocation(),
+			Value:    r.GetValue(),
+		})
+	}
+
+	var window time.Duration
+	if m.GetWindow() != nil {
+		if err := m.GetWindow().CheckValid(); err != nil {
+			return StorableMeasurement{}, err
+		}
+		window = m.GetWindow().AsDuration()
+	}
+
+	var heartbeat time.Duration
+	if m.GetHeartbeat() != nil {
+		if err := m.GetHeartbeat().CheckValid(); err != nil {
+			return StorableMeasurement{}, err
+		}
+		heartbeat = m.GetHeartbeat().AsDuration()
+	}
+
+	var summaries []Summary
+	for _, s := range m.GetSummaries() {
+		summaries = append(summaries, Summary{
+			Metric:   s.GetMetric(),
+			Sensor:   s.GetSensor(),
+			Location: s.GetLocation(),
+			Min:      s.GetMin(),
+			Max:      s.GetMax(),
+			Mean:     s.GetMean(),
+			Count:    int64(s.GetCount()),
+		})
+	}
+
 	return StorableMeasurement{
 		DeviceID:        m.GetDeviceId(),
 		Timestamp:       timestamp,
 		UploadTimestamp: uploadTimestamp,
+		Window:          window,
+		Heartbeat:       heartbeat,
+		Sequence:        int64(m.GetSequence()),
+		BootID:          m.GetBootId(),
 		Temp:            temp,
 		PM25:            pm25,
 		PM10:            pm10,
 		RH:              rh,
+		RawValues:       rawValues,
+		Readings:        readings,
+		Summaries:       summaries,
 	}, nil
 }
 
@@ -221,17 +373,80 @@ func NewMeasurement(sm *StorableMeasurement) (mpb.Measurement, error) {
 		rh = wpb.Float(*sm.RH)
 	}
 
+	var rawValues []*mpb.RawValue
+	for _, rv := range sm.RawValues {
+		rawValues = append(rawValues, &mpb.RawValue{
+			Metric:             rv.Metric,
+			Sensor:             rv.Sensor,
+			Value:              rv.Value,
+			CalibrationVersion: rv.CalibrationVersion,
+		})
+	}
+
+	var readings []*mpb.Reading
+	for _, r := range sm.Readings {
+		readings = append(readings, &mpb.Reading{
+			Metric:   r.Metric,
+			Sensor:   r.Sensor,
+			Location: r.Location,
+			Value:    r.Value,
+		})
+	}
+
+	// The window is nil in the generated Measurement type if the measurement is a single sample.
+	var window *durationpb.Duration
+	if sm.Window != 0 {
+		window = durationpb.New(sm.Window)
+	}
+
+	var heartbeat *durationpb.Duration
+	if sm.Heartbeat != 0 {
+		heartbeat = durationpb.New(sm.Heartbeat)
+	}
+
+	var summaries []*mpb.Summary
+	for _, s := range sm.Summaries {
+		summaries = append(summaries, &mpb.Summary{
+			Metric:   s.Metric,
+			Sensor:   s.Sensor,
+			Location: s.Location,
+			Min:      s.Min,
+			Max:      s.Max,
+			Mean:     s.Mean,
+			Count:    uint32(s.Count),
+		})
+	}
+
 	return mpb.Measurement{
 		DeviceId:        sm.DeviceID,
 		Timestamp:       timestamp,
 		UploadTimestamp: uploadTimestamp,
+		Window:          window,
+		Heartbeat:       heartbeat,
+		Sequence:        uint64(sm.Sequence),
+		BootId:          sm.BootID,
 		Temp:            temp,
 		Pm25:            pm25,
 		Pm10:            pm10,
 		Rh:              rh,
+		RawValues:       rawValues,
+		Readings:        readings,
+		Summaries:       summaries,
 	}, nil
 }
 
+// Overdue reports whether a device that publishes by exception should have published again
+// by now, i.e. whether measurements after this one were lost. A device that publishes by
+// exception checks its heartbeat only when it takes a sample, so it may publish somewhat
+// later than its heartbeat. Overdue allows twice the heartbeat. It's always false if the
+// measurement has no heartbeat, because then the device's schedule isn't known.
+func (sm StorableMeasurement) Overdue(now time.Time) bool {
+	if sm.Heartbeat <= 0 {
+		return false
+	}
+	return now.Sub(sm.Timestamp.Add(sm.Window)) > 2*sm.Heartbeat
+}
+
 // DBKey returns a string key suitable for Datastore. It promotes Device ID and timestamp into the key.
 func (sm *StorableMeasurement) DBKey() string {
 	return strings.Join([]string{sm.DeviceID, sm.Timestamp.Format(time.RFC3339)}, keySep)
@@ -296,6 +511,107 @@ func (sm *StorableMeasurement) FillDerivedMetrics() {
 	}
 }
 
+// SplitBySensor separates the values of metrics that were reported by more than one sensor.
+// It returns a copy of sm without those metrics and, keyed by the label of each sensor (see
+// Reading.Label), a StorableMeasurement with only that sensor's values of those metrics. The
+// returned map is nil if no metric was reported by more than one sensor. Summaries of those
+// metrics are split in the same way. Derived metrics are recomputed for the returned measurements.
+func (sm StorableMeasurement) SplitBySensor() (StorableMeasurement, map[string]StorableMeasurement) {
+	count := make(map[string]int)
+	for _, r := range sm.Readings {
+		count[r.Metric]++
+	}
+
+	var bySensor map[string]StorableMeasurement
+	for _, r := range sm.Readings {
+		if count[r.Metric] < 2 {
+			continue
+		}
+
+		if bySensor == nil {
+			bySensor = make(map[string]StorableMeasurement)
+		}
+
+		label := r.Label()
+		s, ok := bySensor[label]
+		if !ok {
+			s = StorableMeasurement{
+				DeviceID:        sm.DeviceID,
+				Timestamp:       sm.Timestamp,
+				UploadTimestamp: sm.UploadTimestamp,
+				Window:          sm.Window,
+				Heartbeat:       sm.Heartbeat,
+			}
+		}
+
+		v := r.Value
+		s.setValue(r.Metric, &v)
+		bySensor[label] = s
+	}
+
+	if bySensor == nil {
+		return sm, nil
+	}
+
+	for metric, n := range count {
+		if n > 1 {
+			sm.setValue(metric, nil)
+		}
+	}
+
+	var summaries []Summary
+	for _, s := range sm.Summaries {
+		if count[s.Metric] < 2 {
+			summaries = append(summaries, s)
+			continue
+		}
+
+		if split, ok := bySensor[s.Label()]; ok {
+			split.Summaries = append(split.Summaries, s)
+			bySensor[s.Label()] = split
+		}
+	}
+	sm.Summaries = summaries
+	sm.AQI = nil
+	sm.FillDerivedMetrics()
+
+	for label, s := range bySensor {
+		s.FillDerivedMetrics()
+		bySensor[label] = s
+	}
+
+	return sm, bySensor
+}
+
+// SetValue sets the metric with the given JSON key, which is the same as the name of the
+// corresponding field in the generated Measurement type. It returns false if there's no such metric.
+func (sm *StorableMeasurement) SetValue(key string, f float32) bool {
+	return sm.setValue(key, &f)
+}
+
+// setValue sets the metric with the given JSON key, which is the same as the name of the
+// corresponding field in the generated Measurement type. It returns false if there's no such metric.
+func (sm *StorableMeasurement) setValue(key string, f *float32) bool {
+	v := reflect.ValueOf(sm).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if getMetric(v, i) == "" {
+			continue
+		}
+
+		if strings.Split(v.Type().Field(i).Tag.Get("json"), ",")[0] != key {
+			continue
+		}
+
+		if _, ok := getValue(v, i); !ok {
+			return false
+		}
+		v.Field(i).Set(reflect.ValueOf(f))
+		return true
+	}
+
+	return false
+}
+
 func (sm StorableMeasurement) String() string {
 	delay := ""
 	if !sm.UploadTimestamp.IsZero() {
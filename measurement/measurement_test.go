@@ -5,9 +5,11 @@ import (
 	"testing"
 	"time"
 
+	"cloud.google.com/go/datastore"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	mpb "github.com/mtraver/environmental-sensor/measurementpb"
+	durationpb "google.golang.org/protobuf/types/known/durationpb"
 	tspb "google.golang.org/protobuf/types/known/timestamppb"
 	wpb "google.golang.org/protobuf/types/known/wrapperspb"
 )
@@ -65,6 +67,105 @@ var (
 			},
 			true,
 		},
+		{"valid_with_raw_values",
+			mpb.Measurement{
+				DeviceId:  "foo",
+				Timestamp: pbTimestamp,
+				Temp:      wpb.Float(18.1),
+				RawValues: []*mpb.RawValue{
+					{Metric: "temp", Sensor: "mcp9808", Value: 18.5, CalibrationVersion: "v1"},
+				},
+			},
+			StorableMeasurement{
+				DeviceID:  "foo",
+				Timestamp: testTimestamp,
+				Temp:      floatPtr(18.1),
+				RawValues: []RawValue{
+					{Metric: "temp", Sensor: "mcp9808", Value: 18.5, CalibrationVersion: "v1"},
+				},
+			},
+			true,
+		},
+		{"valid_with_readings",
+			mpb.Measurement{
+				DeviceId:  "foo",
+				Timestamp: pbTimestamp,
+				Temp:      wpb.Float(18.1),
+				Readings: []*mpb.Reading{
+					{Metric: "temp", Sensor: "mcp9808", Location: "probe-in", Value: 18.1},
+					{Metric: "temp", Sensor: "bme280", Location: "probe-out", Value: 4.5},
+				},
+			},
+			StorableMeasurement{
+				DeviceID:  "foo",
+				Timestamp: testTimestamp,
+				Temp:      floatPtr(18.1),
+				Readings: []Reading{
+					{Metric: "temp", Sensor: "mcp9808", Location: "probe-in", Value: 18.1},
+					{Metric: "temp", Sensor: "bme280", Location: "probe-out", Value: 4.5},
+				},
+			},
+			true,
+		},
+		{"valid_with_summaries",
+			mpb.Measurement{
+				DeviceId:  "foo",
+				Timestamp: pbTimestamp,
+				Window:    durationpb.New(5 * time.Minute),
+				Temp:      wpb.Float(18.1),
+				Readings: []*mpb.Reading{
+					{Metric: "temp", Sensor: "mcp9808", Value: 18.1},
+				},
+				Summaries: []*mpb.Summary{
+					{Metric: "temp", Sensor: "mcp9808", Min: 17.5, Max: 18.5, Mean: 18.1, Count: 10},
+				},
+			},
+			StorableMeasurement{
+				DeviceID:  "foo",
+				Timestamp: testTimestamp,
+				Window:    5 * time.Minute,
+				Temp:      floatPtr(18.1),
+				Readings: []Reading{
+					{Metric: "temp", Sensor: "mcp9808", Value: 18.1},
+				},
+				Summaries: []Summary{
+					{Metric: "temp", Sensor: "mcp9808", Min: 17.5, Max: 18.5, Mean: 18.1, Count: 10},
+				},
+			},
+			true,
+		},
+		{"valid_with_heartbeat",
+			mpb.Measurement{
+				DeviceId:  "foo",
+				Timestamp: pbTimestamp,
+				Heartbeat: durationpb.New(time.Hour),
+				Temp:      wpb.Float(18.5),
+			},
+			StorableMeasurement{
+				DeviceID:  "foo",
+				Timestamp: testTimestamp,
+				Heartbeat: time.Hour,
+				Temp:      floatPtr(18.5),
+			},
+			true,
+		},
+		{"valid_with_sequence",
+			mpb.Measurement{
+				DeviceId:  "foo",
+				Timestamp: pbTimestamp,
+				Sequence:  42,
+				BootId:    "c0ffee",
+				Temp:      wpb.Float(18.5),
+			},
+			StorableMeasurement{
+				DeviceID:  "foo",
+				Timestamp: testTimestamp,
+				Sequence:  42,
+				BootID:    "c0ffee",
+				Temp:      floatPtr(18.5),
+			},
+			true,
+		},
 		{"nil_timestamp",
 			mpb.Measurement{
 				DeviceId:  "foo",
@@ -156,6 +257,31 @@ func TestNewStorableMeasurement(t *testing.T) {
 	}
 }
 
+// TestDatastoreSaveStruct checks that every StorableMeasurement can be saved to and loaded
+// from Datastore, which doesn't support some field types, e.g. unsigned integers.
+func TestDatastoreSaveStruct(t *testing.T) {
+	for _, c := range conversionCases {
+		if !c.valid {
+			continue
+		}
+
+		t.Run(c.name, func(t *testing.T) {
+			props, err := datastore.SaveStruct(&c.sm)
+			if err != nil {
+				t.Fatalf("Unexpected error saving: %v", err)
+			}
+
+			var got StorableMeasurement
+			if err := datastore.LoadStruct(&got, props); err != nil {
+				t.Fatalf("Unexpected error loading: %v", err)
+			}
+			if diff := cmp.Diff(got, c.sm); diff != "" {
+				t.Errorf("Unexpected result (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
+
 func TestNewMeasurement(t *testing.T) {
 	for _, c := range conversionCases {
 		t.Run(c.name, func(t *testing.T) {
@@ -172,7 +298,7 @@ func TestNewMeasurement(t *testing.T) {
 				return
 			}
 
-			if diff := cmp.Diff(got, c.m, cmpopts.IgnoreUnexported(mpb.Measurement{}, tspb.Timestamp{}, wpb.FloatValue{})); diff != "" {
+			if diff := cmp.Diff(got, c.m, cmpopts.IgnoreUnexported(mpb.Measurement{}, mpb.RawValue{}, mpb.Reading{}, mpb.Summary{}, tspb.Timestamp{}, durationpb.Duration{}, wpb.FloatValue{})); diff != "" {
 				t.Errorf("Unexpected result (-got +want):\n%s", diff)
 			}
 		})
@@ -241,3 +367,127 @@ func TestGetMetricFailure(t *testing.T) {
 		t.Errorf("got metric, expected none: %v", got)
 	}
 }
+
+func TestOverdue(t *testing.T) {
+	cases := []struct {
+		name string
+		sm   StorableMeasurement
+		now  time.Time
+		want bool
+	}{
+		{"no_heartbeat", StorableMeasurement{Timestamp: testTimestamp}, testTimestamp.Add(48 * time.Hour), false},
+		{"within_heartbeat", StorableMeasurement{Timestamp: testTimestamp, Heartbeat: time.Hour}, testTimestamp.Add(time.Hour), false},
+		{"within_slack", StorableMeasurement{Timestamp: testTimestamp, Heartbeat: time.Hour}, testTimestamp.Add(2 * time.Hour), false},
+		{"overdue", StorableMeasurement{Timestamp: testTimestamp, Heartbeat: time.Hour}, testTimestamp.Add(2*time.Hour + time.Second), true},
+		{"window", StorableMeasurement{Timestamp: testTimestamp, Window: time.Hour, Heartbeat: time.Hour}, testTimestamp.Add(3 * time.Hour), false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := c.sm.Overdue(c.now); got != c.want {
+				t.Errorf("got %t, want %t", got, c.want)
+			}
+		})
+	}
+}
+
+func TestSplitBySensor(t *testing.T) {
+	cases := []struct {
+		name         string
+		sm           StorableMeasurement
+		want         StorableMeasurement
+		wantBySensor map[string]StorableMeasurement
+	}{
+		{"no_readings",
+			StorableMeasurement{DeviceID: "foo", Timestamp: testTimestamp, Temp: floatPtr(18.5)},
+			StorableMeasurement{DeviceID: "foo", Timestamp: testTimestamp, Temp: floatPtr(18.5)},
+			nil,
+		},
+		{"one_sensor_per_metric",
+			StorableMeasurement{DeviceID: "foo", Timestamp: testTimestamp, Temp: floatPtr(18.5), RH: floatPtr(50),
+				Readings: []Reading{
+					{Metric: "temp", Sensor: "mcp9808", Value: 18.5},
+					{Metric: "rh", Sensor: "bme280", Value: 50},
+				}},
+			StorableMeasurement{DeviceID: "foo", Timestamp: testTimestamp, Temp: floatPtr(18.5), RH: floatPtr(50),
+				Readings: []Reading{
+					{Metric: "temp", Sensor: "mcp9808", Value: 18.5},
+					{Metric: "rh", Sensor: "bme280", Value: 50},
+				}},
+			nil,
+		},
+		{"split",
+			StorableMeasurement{DeviceID: "foo", Timestamp: testTimestamp, Temp: floatPtr(18.5), RH: floatPtr(50),
+				Readings: []Reading{
+					{Metric: "temp", Sensor: "mcp9808", Location: "probe-in", Value: 18.5},
+					{Metric: "temp", Sensor: "bme280", Value: 4.5},
+					{Metric: "rh", Sensor: "bme280", Value: 50},
+				}},
+			StorableMeasurement{DeviceID: "foo", Timestamp: testTimestamp, RH: floatPtr(50),
+				Readings: []Reading{
+					{Metric: "temp", Sensor: "mcp9808", Location: "probe-in", Value: 18.5},
+					{Metric: "temp", Sensor: "bme280", Value: 4.5},
+					{Metric: "rh", Sensor: "bme280", Value: 50},
+				}},
+			map[string]StorableMeasurement{
+				"probe-in": {DeviceID: "foo", Timestamp: testTimestamp, Temp: floatPtr(18.5)},
+				"bme280":   {DeviceID: "foo", Timestamp: testTimestamp, Temp: floatPtr(4.5)},
+			},
+		},
+		{"split_summaries",
+			StorableMeasurement{DeviceID: "foo", Timestamp: testTimestamp, Window: time.Minute, Temp: floatPtr(18.5), RH: floatPtr(50),
+				Readings: []Reading{
+					{Metric: "temp", Sensor: "mcp9808", Value: 18.5},
+					{Metric: "temp", Sensor: "bme280", Value: 4.5},
+					{Metric: "rh", Sensor: "bme280", Value: 50},
+				},
+				Summaries: []Summary{
+					{Metric: "temp", Sensor: "mcp9808", Min: 18, Max: 19, Mean: 18.5, Count: 2},
+					{Metric: "temp", Sensor: "bme280", Min: 4, Max: 5, Mean: 4.5, Count: 2},
+					{Metric: "rh", Sensor: "bme280", Min: 45, Max: 55, Mean: 50, Count: 2},
+				}},
+			StorableMeasurement{DeviceID: "foo", Timestamp: testTimestamp, Window: time.Minute, RH: floatPtr(50),
+				Readings: []Reading{
+					{Metric: "temp", Sensor: "mcp9808", Value: 18.5},
+					{Metric: "temp", Sensor: "bme280", Value: 4.5},
+					{Metric: "rh", Sensor: "bme280", Value: 50},
+				},
+				Summaries: []Summary{
+					{Metric: "rh", Sensor: "bme280", Min: 45, Max: 55, Mean: 50, Count: 2},
+				}},
+			map[string]StorableMeasurement{
+				"mcp9808": {DeviceID: "foo", Timestamp: testTimestamp, Window: time.Minute, Temp: floatPtr(18.5),
+					Summaries: []Summary{{Metric: "temp", Sensor: "mcp9808", Min: 18, Max: 19, Mean: 18.5, Count: 2}}},
+				"bme280": {DeviceID: "foo", Timestamp: testTimestamp, Window: time.Minute, Temp: floatPtr(4.5),
+					Summaries: []Summary{{Metric: "temp", Sensor: "bme280", Min: 4, Max: 5, Mean: 4.5, Count: 2}}},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, gotBySensor := c.sm.SplitBySensor()
+			if diff := cmp.Diff(got, c.want); diff != "" {
+				t.Errorf("Unexpected result (-got +want):\n%s", diff)
+			}
+			if diff := cmp.Diff(gotBySensor, c.wantBySensor); diff != "" {
+				t.Errorf("Unexpected result by sensor (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSetValue(t *testing.T) {
+	var sm StorableMeasurement
+	if !sm.SetValue("pm25", 12) {
+		t.Errorf("SetValue(%q) = false, want true", "pm25")
+	}
+	if sm.SetValue("nope", 1) {
+		t.Errorf("SetValue(%q) = true, want false", "nope")
+	}
+
+	want := StorableMeasurement{PM25: floatPtr(12)}
+	if diff := cmp.Diff(sm, want); diff != "" {
+		t.Errorf("Unexpected result (-got +want):\n%s", diff)
+	}
+}
@@ -9,6 +9,7 @@ package measurementpb
 import (
 	proto "github.com/golang/protobuf/proto"
 	descriptor "github.com/golang/protobuf/protoc-gen-go/descriptor"
+	duration "github.com/golang/protobuf/ptypes/duration"
 	empty "github.com/golang/protobuf/ptypes/empty"
 	timestamp "github.com/golang/protobuf/ptypes/timestamp"
 	wrappers "github.com/golang/protobuf/ptypes/wrappers"
@@ -99,6 +100,36 @@ type Measurement struct {
 	// immediately after it is taken, e.g. if the network goes down and
 	// measurements are stored locally before upload is attempted again later.
 	UploadTimestamp *timestamp.Timestamp `protobuf:"bytes,4,opt,name=upload_timestamp,json=uploadTimestamp,proto3" json:"upload_timestamp,omitempty"`
+	// The values reported by sensors before calibration was applied. There is one
+	// entry for each metric that was corrected on the device. Keeping them allows
+	// data to be reprocessed if the calibration changes.
+	RawValues []*RawValue `protobuf:"bytes,8,rep,name=raw_values,json=rawValues,proto3" json:"raw_values,omitempty"`
+	// Alert status reported by a temperature sensor with hardware alerts, e.g. the MCP9808.
+	// Only set if the sensor's alerts are configured.
+	TempAlert *TempAlert `protobuf:"bytes,9,opt,name=temp_alert,json=tempAlert,proto3" json:"temp_alert,omitempty"`
+	// The values of each metric as reported by each sensor. A metric field above
+	// holds only one value, so if more than one sensor reports a metric then the
+	// field holds the first sensor's value and every sensor's value is here.
+	// Measurements from older devices have no readings.
+	Readings []*Reading `protobuf:"bytes,10,rep,name=readings,proto3" json:"readings,omitempty"`
+	// Set if the measurement summarizes samples taken over a window of time, in
+	// which case timestamp is the start of the window and window is its length.
+	// Each metric field above and each reading holds the mean over the window.
+	Window *duration.Duration `protobuf:"bytes,11,opt,name=window,proto3" json:"window,omitempty"`
+	// The distribution of each sensor's samples of each metric over the window.
+	// Only set if window is set.
+	Summaries []*Summary `protobuf:"bytes,12,rep,name=summaries,proto3" json:"summaries,omitempty"`
+	// Set if the device publishes by exception, i.e. it skips samples that are
+	// close to the last published values. It's the longest the device should go
+	// without publishing, so a longer gap means that measurements were lost.
+	Heartbeat *duration.Duration `protobuf:"bytes,13,opt,name=heartbeat,proto3" json:"heartbeat,omitempty"`
+	// Numbers the measurements published by the device. It increases by one with
+	// each measurement and it's persisted across restarts, so a gap means that
+	// measurements were lost. It restarts from 0 if the device loses its state.
+	Sequence uint64 `protobuf:"varint,14,opt,name=sequence,proto3" json:"sequence,omitempty"`
+	// Identifies the boot of the device on which the measurement was taken.
+	// Sequence numbers are compared only within a boot.
+	BootId string `protobuf:"bytes,15,opt,name=boot_id,json=bootId,proto3" json:"boot_id,omitempty"`
 }
 
 func (x *Measurement) Reset() {
@@ -182,6 +213,487 @@ func (x *Measurement) GetUploadTimestamp() *timestamp.Timestamp {
 	return nil
 }
 
+func (x *Measurement) GetRawValues() []*RawValue {
+	if x != nil {
+		return x.RawValues
+	}
+	return nil
+}
+
+func (x *Measurement) GetTempAlert() *TempAlert {
+	if x != nil {
+		return x.TempAlert
+	}
+	return nil
+}
+
+func (x *Measurement) GetReadings() []*Reading {
+	if x != nil {
+		return x.Readings
+	}
+	return nil
+}
+
+func (x *Measurement) GetWindow() *duration.Duration {
+	if x != nil {
+		return x.Window
+	}
+	return nil
+}
+
+func (x *Measurement) GetSummaries() []*Summary {
+	if x != nil {
+		return x.Summaries
+	}
+	return nil
+}
+
+func (x *Measurement) GetHeartbeat() *duration.Duration {
+	if x != nil {
+		return x.Heartbeat
+	}
+	return nil
+}
+
+func (x *Measurement) GetSequence() uint64 {
+	if x != nil {
+		return x.Sequence
+	}
+	return 0
+}
+
+func (x *Measurement) GetBootId() string {
+	if x != nil {
+		return x.BootId
+	}
+	return ""
+}
+
+// MeasurementBatch holds measurements that are published in one message to save
+// bandwidth. It's published to the "batch" subfolder of the telemetry topic so
+// that it can be told apart from a single Measurement.
+type MeasurementBatch struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Measurements []*Measurement `protobuf:"bytes,1,rep,name=measurements,proto3" json:"measurements,omitempty"`
+}
+
+func (x *MeasurementBatch) Reset() {
+	*x = MeasurementBatch{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_measurement_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *MeasurementBatch) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MeasurementBatch) ProtoMessage() {}
+
+func (x *MeasurementBatch) ProtoReflect() protoreflect.Message {
+	mi := &file_measurement_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MeasurementBatch.ProtoReflect.Descriptor instead.
+func (*MeasurementBatch) Descriptor() ([]byte, []int) {
+	return file_measurement_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *MeasurementBatch) GetMeasurements() []*Measurement {
+	if x != nil {
+		return x.Measurements
+	}
+	return nil
+}
+
+// SignedBatch is a MeasurementBatch signed with the private key of the device
+// that took the measurements, so that the device_id of each can be trusted. It's
+// published to the "signed" subfolder of the telemetry topic.
+type SignedBatch struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// A serialized MeasurementBatch. It's kept serialized so that the signature is
+	// verified over exactly the bytes that were signed.
+	Batch []byte `protobuf:"bytes,1,opt,name=batch,proto3" json:"batch,omitempty"`
+	// The ASN.1 DER-encoded ECDSA signature of the SHA-256 digest of batch.
+	Signature []byte `protobuf:"bytes,2,opt,name=signature,proto3" json:"signature,omitempty"`
+}
+
+func (x *SignedBatch) Reset() {
+	*x = SignedBatch{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_measurement_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SignedBatch) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SignedBatch) ProtoMessage() {}
+
+func (x *SignedBatch) ProtoReflect() protoreflect.Message {
+	mi := &file_measurement_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SignedBatch.ProtoReflect.Descriptor instead.
+func (*SignedBatch) Descriptor() ([]byte, []int) {
+	return file_measurement_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *SignedBatch) GetBatch() []byte {
+	if x != nil {
+		return x.Batch
+	}
+	return nil
+}
+
+func (x *SignedBatch) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+// Summary describes the samples of one metric reported by one sensor over a window.
+type Summary struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The name of the field in Measurement that holds the metric, e.g. "temp".
+	Metric string `protobuf:"bytes,1,opt,name=metric,proto3" json:"metric,omitempty"`
+	// The name of the sensor that reported the samples, e.g. "sds011".
+	Sensor string `protobuf:"bytes,2,opt,name=sensor,proto3" json:"sensor,omitempty"`
+	// Where the sensor is, e.g. "probe-in". Optional.
+	Location string  `protobuf:"bytes,3,opt,name=location,proto3" json:"location,omitempty"`
+	Min      float32 `protobuf:"fixed32,4,opt,name=min,proto3" json:"min,omitempty"`
+	Max      float32 `protobuf:"fixed32,5,opt,name=max,proto3" json:"max,omitempty"`
+	Mean     float32 `protobuf:"fixed32,6,opt,name=mean,proto3" json:"mean,omitempty"`
+	Count    uint32  `protobuf:"varint,7,opt,name=count,proto3" json:"count,omitempty"`
+}
+
+func (x *Summary) Reset() {
+	*x = Summary{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_measurement_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Summary) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Summary) ProtoMessage() {}
+
+func (x *Summary) ProtoReflect() protoreflect.Message {
+	mi := &file_measurement_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Summary.ProtoReflect.Descriptor instead.
+func (*Summary) Descriptor() ([]byte, []int) {
+	return file_measurement_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Summary) GetMetric() string {
+	if x != nil {
+		return x.Metric
+	}
+	return ""
+}
+
+func (x *Summary) GetSensor() string {
+	if x != nil {
+		return x.Sensor
+	}
+	return ""
+}
+
+func (x *Summary) GetLocation() string {
+	if x != nil {
+		return x.Location
+	}
+	return ""
+}
+
+func (x *Summary) GetMin() float32 {
+	if x != nil {
+		return x.Min
+	}
+	return 0
+}
+
+func (x *Summary) GetMax() float32 {
+	if x != nil {
+		return x.Max
+	}
+	return 0
+}
+
+func (x *Summary) GetMean() float32 {
+	if x != nil {
+		return x.Mean
+	}
+	return 0
+}
+
+func (x *Summary) GetCount() uint32 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+// Reading is the value of one metric as reported by one sensor.
+type Reading struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The name of the field in Measurement that holds the metric, e.g. "temp".
+	Metric string `protobuf:"bytes,1,opt,name=metric,proto3" json:"metric,omitempty"`
+	// The name of the sensor that reported the value, e.g. "mcp9808".
+	Sensor string `protobuf:"bytes,2,opt,name=sensor,proto3" json:"sensor,omitempty"`
+	// Where the sensor is, e.g. "probe-in". Optional.
+	Location string  `protobuf:"bytes,3,opt,name=location,proto3" json:"location,omitempty"`
+	Value    float32 `protobuf:"fixed32,4,opt,name=value,proto3" json:"value,omitempty"`
+}
+
+func (x *Reading) Reset() {
+	*x = Reading{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_measurement_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Reading) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Reading) ProtoMessage() {}
+
+func (x *Reading) ProtoReflect() protoreflect.Message {
+	mi := &file_measurement_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Reading.ProtoReflect.Descriptor instead.
+func (*Reading) Descriptor() ([]byte, []int) {
+	return file_measurement_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Reading) GetMetric() string {
+	if x != nil {
+		return x.Metric
+	}
+	return ""
+}
+
+func (x *Reading) GetSensor() string {
+	if x != nil {
+		return x.Sensor
+	}
+	return ""
+}
+
+func (x *Reading) GetLocation() string {
+	if x != nil {
+		return x.Location
+	}
+	return ""
+}
+
+func (x *Reading) GetValue() float32 {
+	if x != nil {
+		return x.Value
+	}
+	return 0
+}
+
+// RawValue is an uncalibrated sensor value.
+type RawValue struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The name of the field in Measurement that holds the calibrated value, e.g. "temp".
+	Metric string `protobuf:"bytes,1,opt,name=metric,proto3" json:"metric,omitempty"`
+	// The name of the sensor that reported the value, e.g. "mcp9808".
+	Sensor string  `protobuf:"bytes,2,opt,name=sensor,proto3" json:"sensor,omitempty"`
+	Value  float32 `protobuf:"fixed32,3,opt,name=value,proto3" json:"value,omitempty"`
+	// The version of the calibration that was applied to the value.
+	CalibrationVersion string `protobuf:"bytes,4,opt,name=calibration_version,json=calibrationVersion,proto3" json:"calibration_version,omitempty"`
+}
+
+func (x *RawValue) Reset() {
+	*x = RawValue{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_measurement_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RawValue) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RawValue) ProtoMessage() {}
+
+func (x *RawValue) ProtoReflect() protoreflect.Message {
+	mi := &file_measurement_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RawValue.ProtoReflect.Descriptor instead.
+func (*RawValue) Descriptor() ([]byte, []int) {
+	return file_measurement_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *RawValue) GetMetric() string {
+	if x != nil {
+		return x.Metric
+	}
+	return ""
+}
+
+func (x *RawValue) GetSensor() string {
+	if x != nil {
+		return x.Sensor
+	}
+	return ""
+}
+
+func (x *RawValue) GetValue() float32 {
+	if x != nil {
+		return x.Value
+	}
+	return 0
+}
+
+func (x *RawValue) GetCalibrationVersion() string {
+	if x != nil {
+		return x.CalibrationVersion
+	}
+	return ""
+}
+
+// TempAlert holds the alert status bits of a temperature sensor. A bit is set if the
+// condition held for any of the samples that went into the reading.
+type TempAlert struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Lower    bool `protobuf:"varint,1,opt,name=lower,proto3" json:"lower,omitempty"`       // Temperature below the lower limit.
+	Upper    bool `protobuf:"varint,2,opt,name=upper,proto3" json:"upper,omitempty"`       // Temperature above the upper limit.
+	Critical bool `protobuf:"varint,3,opt,name=critical,proto3" json:"critical,omitempty"` // Temperature at or above the critical limit.
+}
+
+func (x *TempAlert) Reset() {
+	*x = TempAlert{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_measurement_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *TempAlert) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TempAlert) ProtoMessage() {}
+
+func (x *TempAlert) ProtoReflect() protoreflect.Message {
+	mi := &file_measurement_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TempAlert.ProtoReflect.Descriptor instead.
+func (*TempAlert) Descriptor() ([]byte, []int) {
+	return file_measurement_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *TempAlert) GetLower() bool {
+	if x != nil {
+		return x.Lower
+	}
+	return false
+}
+
+func (x *TempAlert) GetUpper() bool {
+	if x != nil {
+		return x.Upper
+	}
+	return false
+}
+
+func (x *TempAlert) GetCritical() bool {
+	if x != nil {
+		return x.Critical
+	}
+	return false
+}
+
 type GetDevicesResponse struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -193,7 +705,7 @@ type GetDevicesResponse struct {
 func (x *GetDevicesResponse) Reset() {
 	*x = GetDevicesResponse{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_measurement_proto_msgTypes[2]
+		mi := &file_measurement_proto_msgTypes[8]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -206,7 +718,7 @@ func (x *GetDevicesResponse) String() string {
 func (*GetDevicesResponse) ProtoMessage() {}
 
 func (x *GetDevicesResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_measurement_proto_msgTypes[2]
+	mi := &file_measurement_proto_msgTypes[8]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -219,7 +731,7 @@ func (x *GetDevicesResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetDevicesResponse.ProtoReflect.Descriptor instead.
 func (*GetDevicesResponse) Descriptor() ([]byte, []int) {
-	return file_measurement_proto_rawDescGZIP(), []int{2}
+	return file_measurement_proto_rawDescGZIP(), []int{8}
 }
 
 func (x *GetDevicesResponse) GetDeviceId() []string {
@@ -240,7 +752,7 @@ type GetLatestRequest struct {
 func (x *GetLatestRequest) Reset() {
 	*x = GetLatestRequest{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_measurement_proto_msgTypes[3]
+		mi := &file_measurement_proto_msgTypes[9]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -253,7 +765,7 @@ func (x *GetLatestRequest) String() string {
 func (*GetLatestRequest) ProtoMessage() {}
 
 func (x *GetLatestRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_measurement_proto_msgTypes[3]
+	mi := &file_measurement_proto_msgTypes[9]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -266,7 +778,7 @@ func (x *GetLatestRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetLatestRequest.ProtoReflect.Descriptor instead.
 func (*GetLatestRequest) Descriptor() ([]byte, []int) {
-	return file_measurement_proto_rawDescGZIP(), []int{3}
+	return file_measurement_proto_rawDescGZIP(), []int{9}
 }
 
 func (x *GetLatestRequest) GetDeviceId() string {
@@ -310,6 +822,8 @@ var file_measurement_proto_rawDesc = []byte{
 	0x6f, 0x74, 0x6f, 0x12, 0x0b, 0x6d, 0x65, 0x61, 0x73, 0x75, 0x72, 0x65, 0x6d, 0x65, 0x6e, 0x74,
 	0x1a, 0x20, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75,
 	0x66, 0x2f, 0x64, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x6f, 0x72, 0x2e, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x1a, 0x1e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x62, 0x75, 0x66, 0x2f, 0x64, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x2e, 0x70, 0x72, 0x6f,
 	0x74, 0x6f, 0x1a, 0x1b, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f,
 	0x62, 0x75, 0x66, 0x2f, 0x65, 0x6d, 0x70, 0x74, 0x79, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x1a,
 	0x1f, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66,
@@ -320,7 +834,7 @@ var file_measurement_proto_rawDesc = []byte{
 	0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x12, 0x16, 0x0a, 0x06, 0x6d, 0x65, 0x74, 0x72, 0x69, 0x63,
 	0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x6d, 0x65, 0x74, 0x72, 0x69, 0x63, 0x12, 0x12,
 	0x0a, 0x04, 0x75, 0x6e, 0x69, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x75, 0x6e,
-	0x69, 0x74, 0x22, 0xe6, 0x03, 0x0a, 0x0b, 0x4d, 0x65, 0x61, 0x73, 0x75, 0x72, 0x65, 0x6d, 0x65,
+	0x69, 0x74, 0x22, 0xca, 0x06, 0x0a, 0x0b, 0x4d, 0x65, 0x61, 0x73, 0x75, 0x72, 0x65, 0x6d, 0x65,
 	0x6e, 0x74, 0x12, 0x3d, 0x0a, 0x09, 0x64, 0x65, 0x76, 0x69, 0x63, 0x65, 0x5f, 0x69, 0x64, 0x18,
 	0x01, 0x20, 0x01, 0x28, 0x09, 0x42, 0x20, 0x82, 0xb5, 0x18, 0x1c, 0x5e, 0x5b, 0x61, 0x2d, 0x7a,
 	0x5d, 0x5b, 0x61, 0x2d, 0x7a, 0x30, 0x2d, 0x39, 0x2b, 0x2e, 0x25, 0x7e, 0x5f, 0x2d, 0x5d, 0x7b,
@@ -328,61 +842,123 @@ var file_measurement_proto_rawDesc = []byte{
 	0x64, 0x12, 0x38, 0x0a, 0x09, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x18, 0x02,
 	0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72,
 	0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70,
-	0x52, 0x09, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x12, 0x44, 0x0a, 0x04, 0x74,
+	0x52, 0x09, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x12, 0x40, 0x0a, 0x04, 0x74,
 	0x65, 0x6d, 0x70, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1b, 0x2e, 0x67, 0x6f, 0x6f, 0x67,
 	0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x46, 0x6c, 0x6f, 0x61,
-	0x74, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x42, 0x13, 0x8a, 0xb5, 0x18, 0x06, 0x0a, 0x04, 0x74, 0x65,
-	0x6d, 0x70, 0x8a, 0xb5, 0x18, 0x05, 0x12, 0x03, 0xc2, 0xb0, 0x43, 0x52, 0x04, 0x74, 0x65, 0x6d,
-	0x70, 0x12, 0x49, 0x0a, 0x04, 0x70, 0x6d, 0x32, 0x35, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32,
-	0x1b, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75,
-	0x66, 0x2e, 0x46, 0x6c, 0x6f, 0x61, 0x74, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x42, 0x18, 0x8a, 0xb5,
-	0x18, 0x07, 0x0a, 0x05, 0x50, 0x4d, 0x32, 0x2e, 0x35, 0x8a, 0xb5, 0x18, 0x09, 0x12, 0x07, 0xce,
-	0xbc, 0x67, 0x2f, 0x6d, 0xc2, 0xb3, 0x52, 0x04, 0x70, 0x6d, 0x32, 0x35, 0x12, 0x48, 0x0a, 0x04,
-	0x70, 0x6d, 0x31, 0x30, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1b, 0x2e, 0x67, 0x6f, 0x6f,
-	0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x46, 0x6c, 0x6f,
-	0x61, 0x74, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x42, 0x17, 0x8a, 0xb5, 0x18, 0x06, 0x0a, 0x04, 0x50,
-	0x4d, 0x31, 0x30, 0x8a, 0xb5, 0x18, 0x09, 0x12, 0x07, 0xce, 0xbc, 0x67, 0x2f, 0x6d, 0xc2, 0xb3,
-	0x52, 0x04, 0x70, 0x6d, 0x31, 0x30, 0x12, 0x3c, 0x0a, 0x02, 0x72, 0x68, 0x18, 0x07, 0x20, 0x01,
+	0x74, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x42, 0x0f, 0x8a, 0xb5, 0x18, 0x0b, 0x0a, 0x04, 0x74, 0x65,
+	0x6d, 0x70, 0x12, 0x03, 0xc2, 0xb0, 0x43, 0x52, 0x04, 0x74, 0x65, 0x6d, 0x70, 0x12, 0x45, 0x0a,
+	0x04, 0x70, 0x6d, 0x32, 0x35, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1b, 0x2e, 0x67, 0x6f,
+	0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x46, 0x6c,
+	0x6f, 0x61, 0x74, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x42, 0x14, 0x8a, 0xb5, 0x18, 0x10, 0x0a, 0x05,
+	0x50, 0x4d, 0x32, 0x2e, 0x35, 0x12, 0x07, 0xce, 0xbc, 0x67, 0x2f, 0x6d, 0xc2, 0xb3, 0x52, 0x04,
+	0x70, 0x6d, 0x32, 0x35, 0x12, 0x44, 0x0a, 0x04, 0x70, 0x6d, 0x31, 0x30, 0x18, 0x06, 0x20, 0x01,
 	0x28, 0x0b, 0x32, 0x1b, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74,
 	0x6f, 0x62, 0x75, 0x66, 0x2e, 0x46, 0x6c, 0x6f, 0x61, 0x74, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x42,
-	0x0f, 0x8a, 0xb5, 0x18, 0x04, 0x0a, 0x02, 0x52, 0x48, 0x8a, 0xb5, 0x18, 0x03, 0x12, 0x01, 0x25,
+	0x13, 0x8a, 0xb5, 0x18, 0x0f, 0x0a, 0x04, 0x50, 0x4d, 0x31, 0x30, 0x12, 0x07, 0xce, 0xbc, 0x67,
+	0x2f, 0x6d, 0xc2, 0xb3, 0x52, 0x04, 0x70, 0x6d, 0x31, 0x30, 0x12, 0x38, 0x0a, 0x02, 0x72, 0x68,
+	0x18, 0x07, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1b, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x46, 0x6c, 0x6f, 0x61, 0x74, 0x56, 0x61,
+	0x6c, 0x75, 0x65, 0x42, 0x0b, 0x8a, 0xb5, 0x18, 0x07, 0x0a, 0x02, 0x52, 0x48, 0x12, 0x01, 0x25,
 	0x52, 0x02, 0x72, 0x68, 0x12, 0x45, 0x0a, 0x10, 0x75, 0x70, 0x6c, 0x6f, 0x61, 0x64, 0x5f, 0x74,
 	0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a,
 	0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66,
 	0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x0f, 0x75, 0x70, 0x6c, 0x6f,
-	0x61, 0x64, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x22, 0x31, 0x0a, 0x12, 0x47,
-	0x65, 0x74, 0x44, 0x65, 0x76, 0x69, 0x63, 0x65, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73,
-	0x65, 0x12, 0x1b, 0x0a, 0x09, 0x64, 0x65, 0x76, 0x69, 0x63, 0x65, 0x5f, 0x69, 0x64, 0x18, 0x01,
-	0x20, 0x03, 0x28, 0x09, 0x52, 0x08, 0x64, 0x65, 0x76, 0x69, 0x63, 0x65, 0x49, 0x64, 0x22, 0x2f,
-	0x0a, 0x10, 0x47, 0x65, 0x74, 0x4c, 0x61, 0x74, 0x65, 0x73, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65,
-	0x73, 0x74, 0x12, 0x1b, 0x0a, 0x09, 0x64, 0x65, 0x76, 0x69, 0x63, 0x65, 0x5f, 0x69, 0x64, 0x18,
-	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x64, 0x65, 0x76, 0x69, 0x63, 0x65, 0x49, 0x64, 0x32,
-	0xa5, 0x01, 0x0a, 0x12, 0x4d, 0x65, 0x61, 0x73, 0x75, 0x72, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x53,
-	0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x47, 0x0a, 0x0a, 0x47, 0x65, 0x74, 0x44, 0x65, 0x76,
-	0x69, 0x63, 0x65, 0x73, 0x12, 0x16, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72,
-	0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x1a, 0x1f, 0x2e, 0x6d,
-	0x65, 0x61, 0x73, 0x75, 0x72, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x2e, 0x47, 0x65, 0x74, 0x44, 0x65,
-	0x76, 0x69, 0x63, 0x65, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x12,
-	0x46, 0x0a, 0x09, 0x47, 0x65, 0x74, 0x4c, 0x61, 0x74, 0x65, 0x73, 0x74, 0x12, 0x1d, 0x2e, 0x6d,
-	0x65, 0x61, 0x73, 0x75, 0x72, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x2e, 0x47, 0x65, 0x74, 0x4c, 0x61,
-	0x74, 0x65, 0x73, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x18, 0x2e, 0x6d, 0x65,
-	0x61, 0x73, 0x75, 0x72, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x2e, 0x4d, 0x65, 0x61, 0x73, 0x75, 0x72,
-	0x65, 0x6d, 0x65, 0x6e, 0x74, 0x22, 0x00, 0x3a, 0x35, 0x0a, 0x05, 0x72, 0x65, 0x67, 0x65, 0x78,
-	0x12, 0x1d, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62,
-	0x75, 0x66, 0x2e, 0x46, 0x69, 0x65, 0x6c, 0x64, 0x4f, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18,
-	0xd0, 0x86, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x72, 0x65, 0x67, 0x65, 0x78, 0x3a, 0x71,
-	0x0a, 0x13, 0x6d, 0x65, 0x61, 0x73, 0x75, 0x72, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x5f, 0x6f, 0x70,
-	0x74, 0x69, 0x6f, 0x6e, 0x73, 0x12, 0x1d, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70,
+	0x61, 0x64, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x12, 0x34, 0x0a, 0x0a, 0x72,
+	0x61, 0x77, 0x5f, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x73, 0x18, 0x08, 0x20, 0x03, 0x28, 0x0b, 0x32,
+	0x15, 0x2e, 0x6d, 0x65, 0x61, 0x73, 0x75, 0x72, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x2e, 0x52, 0x61,
+	0x77, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x52, 0x09, 0x72, 0x61, 0x77, 0x56, 0x61, 0x6c, 0x75, 0x65,
+	0x73, 0x12, 0x35, 0x0a, 0x0a, 0x74, 0x65, 0x6d, 0x70, 0x5f, 0x61, 0x6c, 0x65, 0x72, 0x74, 0x18,
+	0x09, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x6d, 0x65, 0x61, 0x73, 0x75, 0x72, 0x65, 0x6d,
+	0x65, 0x6e, 0x74, 0x2e, 0x54, 0x65, 0x6d, 0x70, 0x41, 0x6c, 0x65, 0x72, 0x74, 0x52, 0x09, 0x74,
+	0x65, 0x6d, 0x70, 0x41, 0x6c, 0x65, 0x72, 0x74, 0x12, 0x30, 0x0a, 0x08, 0x72, 0x65, 0x61, 0x64,
+	0x69, 0x6e, 0x67, 0x73, 0x18, 0x0a, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x6d, 0x65, 0x61,
+	0x73, 0x75, 0x72, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x2e, 0x52, 0x65, 0x61, 0x64, 0x69, 0x6e, 0x67,
+	0x52, 0x08, 0x72, 0x65, 0x61, 0x64, 0x69, 0x6e, 0x67, 0x73, 0x12, 0x31, 0x0a, 0x06, 0x77, 0x69,
+	0x6e, 0x64, 0x6f, 0x77, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x67, 0x6f, 0x6f,
+	0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x44, 0x75, 0x72,
+	0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x06, 0x77, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x12, 0x32, 0x0a,
+	0x09, 0x73, 0x75, 0x6d, 0x6d, 0x61, 0x72, 0x69, 0x65, 0x73, 0x18, 0x0c, 0x20, 0x03, 0x28, 0x0b,
+	0x32, 0x14, 0x2e, 0x6d, 0x65, 0x61, 0x73, 0x75, 0x72, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x2e, 0x53,
+	0x75, 0x6d, 0x6d, 0x61, 0x72, 0x79, 0x52, 0x09, 0x73, 0x75, 0x6d, 0x6d, 0x61, 0x72, 0x69, 0x65,
+	0x73, 0x12, 0x37, 0x0a, 0x09, 0x68, 0x65, 0x61, 0x72, 0x74, 0x62, 0x65, 0x61, 0x74, 0x18, 0x0d,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x44, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52,
+	0x09, 0x68, 0x65, 0x61, 0x72, 0x74, 0x62, 0x65, 0x61, 0x74, 0x12, 0x1a, 0x0a, 0x08, 0x73, 0x65,
+	0x71, 0x75, 0x65, 0x6e, 0x63, 0x65, 0x18, 0x0e, 0x20, 0x01, 0x28, 0x04, 0x52, 0x08, 0x73, 0x65,
+	0x71, 0x75, 0x65, 0x6e, 0x63, 0x65, 0x12, 0x17, 0x0a, 0x07, 0x62, 0x6f, 0x6f, 0x74, 0x5f, 0x69,
+	0x64, 0x18, 0x0f, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x62, 0x6f, 0x6f, 0x74, 0x49, 0x64, 0x22,
+	0x50, 0x0a, 0x10, 0x4d, 0x65, 0x61, 0x73, 0x75, 0x72, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x42, 0x61,
+	0x74, 0x63, 0x68, 0x12, 0x3c, 0x0a, 0x0c, 0x6d, 0x65, 0x61, 0x73, 0x75, 0x72, 0x65, 0x6d, 0x65,
+	0x6e, 0x74, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x6d, 0x65, 0x61, 0x73,
+	0x75, 0x72, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x2e, 0x4d, 0x65, 0x61, 0x73, 0x75, 0x72, 0x65, 0x6d,
+	0x65, 0x6e, 0x74, 0x52, 0x0c, 0x6d, 0x65, 0x61, 0x73, 0x75, 0x72, 0x65, 0x6d, 0x65, 0x6e, 0x74,
+	0x73, 0x22, 0x41, 0x0a, 0x0b, 0x53, 0x69, 0x67, 0x6e, 0x65, 0x64, 0x42, 0x61, 0x74, 0x63, 0x68,
+	0x12, 0x14, 0x0a, 0x05, 0x62, 0x61, 0x74, 0x63, 0x68, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52,
+	0x05, 0x62, 0x61, 0x74, 0x63, 0x68, 0x12, 0x1c, 0x0a, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74,
+	0x75, 0x72, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61,
+	0x74, 0x75, 0x72, 0x65, 0x22, 0xa3, 0x01, 0x0a, 0x07, 0x53, 0x75, 0x6d, 0x6d, 0x61, 0x72, 0x79,
+	0x12, 0x16, 0x0a, 0x06, 0x6d, 0x65, 0x74, 0x72, 0x69, 0x63, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x06, 0x6d, 0x65, 0x74, 0x72, 0x69, 0x63, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x65, 0x6e, 0x73,
+	0x6f, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x73, 0x65, 0x6e, 0x73, 0x6f, 0x72,
+	0x12, 0x1a, 0x0a, 0x08, 0x6c, 0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x03, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x08, 0x6c, 0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x10, 0x0a, 0x03,
+	0x6d, 0x69, 0x6e, 0x18, 0x04, 0x20, 0x01, 0x28, 0x02, 0x52, 0x03, 0x6d, 0x69, 0x6e, 0x12, 0x10,
+	0x0a, 0x03, 0x6d, 0x61, 0x78, 0x18, 0x05, 0x20, 0x01, 0x28, 0x02, 0x52, 0x03, 0x6d, 0x61, 0x78,
+	0x12, 0x12, 0x0a, 0x04, 0x6d, 0x65, 0x61, 0x6e, 0x18, 0x06, 0x20, 0x01, 0x28, 0x02, 0x52, 0x04,
+	0x6d, 0x65, 0x61, 0x6e, 0x12, 0x14, 0x0a, 0x05, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x18, 0x07, 0x20,
+	0x01, 0x28, 0x0d, 0x52, 0x05, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x22, 0x6b, 0x0a, 0x07, 0x52, 0x65,
+	0x61, 0x64, 0x69, 0x6e, 0x67, 0x12, 0x16, 0x0a, 0x06, 0x6d, 0x65, 0x74, 0x72, 0x69, 0x63, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x6d, 0x65, 0x74, 0x72, 0x69, 0x63, 0x12, 0x16, 0x0a,
+	0x06, 0x73, 0x65, 0x6e, 0x73, 0x6f, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x73,
+	0x65, 0x6e, 0x73, 0x6f, 0x72, 0x12, 0x1a, 0x0a, 0x08, 0x6c, 0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f,
+	0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x6c, 0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f,
+	0x6e, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x02,
+	0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x22, 0x81, 0x01, 0x0a, 0x08, 0x52, 0x61, 0x77, 0x56,
+	0x61, 0x6c, 0x75, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x6d, 0x65, 0x74, 0x72, 0x69, 0x63, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x6d, 0x65, 0x74, 0x72, 0x69, 0x63, 0x12, 0x16, 0x0a, 0x06,
+	0x73, 0x65, 0x6e, 0x73, 0x6f, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x73, 0x65,
+	0x6e, 0x73, 0x6f, 0x72, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x03, 0x20,
+	0x01, 0x28, 0x02, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x2f, 0x0a, 0x13, 0x63, 0x61,
+	0x6c, 0x69, 0x62, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f,
+	0x6e, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x12, 0x63, 0x61, 0x6c, 0x69, 0x62, 0x72, 0x61,
+	0x74, 0x69, 0x6f, 0x6e, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x22, 0x53, 0x0a, 0x09, 0x54,
+	0x65, 0x6d, 0x70, 0x41, 0x6c, 0x65, 0x72, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x6c, 0x6f, 0x77, 0x65,
+	0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x08, 0x52, 0x05, 0x6c, 0x6f, 0x77, 0x65, 0x72, 0x12, 0x14,
+	0x0a, 0x05, 0x75, 0x70, 0x70, 0x65, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x08, 0x52, 0x05, 0x75,
+	0x70, 0x70, 0x65, 0x72, 0x12, 0x1a, 0x0a, 0x08, 0x63, 0x72, 0x69, 0x74, 0x69, 0x63, 0x61, 0x6c,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52, 0x08, 0x63, 0x72, 0x69, 0x74, 0x69, 0x63, 0x61, 0x6c,
+	0x22, 0x31, 0x0a, 0x12, 0x47, 0x65, 0x74, 0x44, 0x65, 0x76, 0x69, 0x63, 0x65, 0x73, 0x52, 0x65,
+	0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x1b, 0x0a, 0x09, 0x64, 0x65, 0x76, 0x69, 0x63, 0x65,
+	0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x03, 0x28, 0x09, 0x52, 0x08, 0x64, 0x65, 0x76, 0x69, 0x63,
+	0x65, 0x49, 0x64, 0x22, 0x2f, 0x0a, 0x10, 0x47, 0x65, 0x74, 0x4c, 0x61, 0x74, 0x65, 0x73, 0x74,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1b, 0x0a, 0x09, 0x64, 0x65, 0x76, 0x69, 0x63,
+	0x65, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x64, 0x65, 0x76, 0x69,
+	0x63, 0x65, 0x49, 0x64, 0x32, 0xa5, 0x01, 0x0a, 0x12, 0x4d, 0x65, 0x61, 0x73, 0x75, 0x72, 0x65,
+	0x6d, 0x65, 0x6e, 0x74, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x47, 0x0a, 0x0a, 0x47,
+	0x65, 0x74, 0x44, 0x65, 0x76, 0x69, 0x63, 0x65, 0x73, 0x12, 0x16, 0x2e, 0x67, 0x6f, 0x6f, 0x67,
+	0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x45, 0x6d, 0x70, 0x74,
+	0x79, 0x1a, 0x1f, 0x2e, 0x6d, 0x65, 0x61, 0x73, 0x75, 0x72, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x2e,
+	0x47, 0x65, 0x74, 0x44, 0x65, 0x76, 0x69, 0x63, 0x65, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
+	0x73, 0x65, 0x22, 0x00, 0x12, 0x46, 0x0a, 0x09, 0x47, 0x65, 0x74, 0x4c, 0x61, 0x74, 0x65, 0x73,
+	0x74, 0x12, 0x1d, 0x2e, 0x6d, 0x65, 0x61, 0x73, 0x75, 0x72, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x2e,
+	0x47, 0x65, 0x74, 0x4c, 0x61, 0x74, 0x65, 0x73, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x1a, 0x18, 0x2e, 0x6d, 0x65, 0x61, 0x73, 0x75, 0x72, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x2e, 0x4d,
+	0x65, 0x61, 0x73, 0x75, 0x72, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x22, 0x00, 0x3a, 0x35, 0x0a, 0x05,
+	0x72, 0x65, 0x67, 0x65, 0x78, 0x12, 0x1d, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70,
 	0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x46, 0x69, 0x65, 0x6c, 0x64, 0x4f, 0x70, 0x74,
-	0x69, 0x6f, 0x6e, 0x73, 0x18, 0xd1, 0x86, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1f, 0x2e, 0x6d,
-	0x65, 0x61, 0x73, 0x75, 0x72, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x2e, 0x4d, 0x65, 0x61, 0x73, 0x75,
-	0x72, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x4f, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x52, 0x12, 0x6d,
-	0x65, 0x61, 0x73, 0x75, 0x72, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x4f, 0x70, 0x74, 0x69, 0x6f, 0x6e,
-	0x73, 0x42, 0x37, 0x5a, 0x35, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f,
-	0x6d, 0x74, 0x72, 0x61, 0x76, 0x65, 0x72, 0x2f, 0x65, 0x6e, 0x76, 0x69, 0x72, 0x6f, 0x6e, 0x6d,
-	0x65, 0x6e, 0x74, 0x61, 0x6c, 0x2d, 0x73, 0x65, 0x6e, 0x73, 0x6f, 0x72, 0x2f, 0x6d, 0x65, 0x61,
-	0x73, 0x75, 0x72, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74,
-	0x6f, 0x33,
+	0x69, 0x6f, 0x6e, 0x73, 0x18, 0xd0, 0x86, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x72, 0x65,
+	0x67, 0x65, 0x78, 0x3a, 0x71, 0x0a, 0x13, 0x6d, 0x65, 0x61, 0x73, 0x75, 0x72, 0x65, 0x6d, 0x65,
+	0x6e, 0x74, 0x5f, 0x6f, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x12, 0x1d, 0x2e, 0x67, 0x6f, 0x6f,
+	0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x46, 0x69, 0x65,
+	0x6c, 0x64, 0x4f, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0xd1, 0x86, 0x03, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x1f, 0x2e, 0x6d, 0x65, 0x61, 0x73, 0x75, 0x72, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x2e,
+	0x4d, 0x65, 0x61, 0x73, 0x75, 0x72, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x4f, 0x70, 0x74, 0x69, 0x6f,
+	0x6e, 0x73, 0x52, 0x12, 0x6d, 0x65, 0x61, 0x73, 0x75, 0x72, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x4f,
+	0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x42, 0x37, 0x5a, 0x35, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62,
+	0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x6d, 0x74, 0x72, 0x61, 0x76, 0x65, 0x72, 0x2f, 0x65, 0x6e, 0x76,
+	0x69, 0x72, 0x6f, 0x6e, 0x6d, 0x65, 0x6e, 0x74, 0x61, 0x6c, 0x2d, 0x73, 0x65, 0x6e, 0x73, 0x6f,
+	0x72, 0x2f, 0x6d, 0x65, 0x61, 0x73, 0x75, 0x72, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x70, 0x62, 0x62,
+	0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -397,36 +973,50 @@ func file_measurement_proto_rawDescGZIP() []byte {
 	return file_measurement_proto_rawDescData
 }
 
-var file_measurement_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_measurement_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
 var file_measurement_proto_goTypes = []interface{}{
 	(*MeasurementOptions)(nil),      // 0: measurement.MeasurementOptions
 	(*Measurement)(nil),             // 1: measurement.Measurement
-	(*GetDevicesResponse)(nil),      // 2: measurement.GetDevicesResponse
-	(*GetLatestRequest)(nil),        // 3: measurement.GetLatestRequest
-	(*timestamp.Timestamp)(nil),     // 4: google.protobuf.Timestamp
-	(*wrappers.FloatValue)(nil),     // 5: google.protobuf.FloatValue
-	(*descriptor.FieldOptions)(nil), // 6: google.protobuf.FieldOptions
-	(*empty.Empty)(nil),             // 7: google.protobuf.Empty
+	(*MeasurementBatch)(nil),        // 2: measurement.MeasurementBatch
+	(*SignedBatch)(nil),             // 3: measurement.SignedBatch
+	(*Summary)(nil),                 // 4: measurement.Summary
+	(*Reading)(nil),                 // 5: measurement.Reading
+	(*RawValue)(nil),                // 6: measurement.RawValue
+	(*TempAlert)(nil),               // 7: measurement.TempAlert
+	(*GetDevicesResponse)(nil),      // 8: measurement.GetDevicesResponse
+	(*GetLatestRequest)(nil),        // 9: measurement.GetLatestRequest
+	(*timestamp.Timestamp)(nil),     // 10: google.protobuf.Timestamp
+	(*wrappers.FloatValue)(nil),     // 11: google.protobuf.FloatValue
+	(*duration.Duration)(nil),       // 12: google.protobuf.Duration
+	(*descriptor.FieldOptions)(nil), // 13: google.protobuf.FieldOptions
+	(*empty.Empty)(nil),             // 14: google.protobuf.Empty
 }
 var file_measurement_proto_depIdxs = []int32{
-	4,  // 0: measurement.Measurement.timestamp:type_name -> google.protobuf.Timestamp
-	5,  // 1: measurement.Measurement.temp:type_name -> google.protobuf.FloatValue
-	5,  // 2: measurement.Measurement.pm25:type_name -> google.protobuf.FloatValue
-	5,  // 3: measurement.Measurement.pm10:type_name -> google.protobuf.FloatValue
-	5,  // 4: measurement.Measurement.rh:type_name -> google.protobuf.FloatValue
-	4,  // 5: measurement.Measurement.upload_timestamp:type_name -> google.protobuf.Timestamp
-	6,  // 6: measurement.regex:extendee -> google.protobuf.FieldOptions
-	6,  // 7: measurement.measurement_options:extendee -> google.protobuf.FieldOptions
-	0,  // 8: measurement.measurement_options:type_name -> measurement.MeasurementOptions
-	7,  // 9: measurement.MeasurementService.GetDevices:input_type -> google.protobuf.Empty
-	3,  // 10: measurement.MeasurementService.GetLatest:input_type -> measurement.GetLatestRequest
-	2,  // 11: measurement.MeasurementService.GetDevices:output_type -> measurement.GetDevicesResponse
-	1,  // 12: measurement.MeasurementService.GetLatest:output_type -> measurement.Measurement
-	11, // [11:13] is the sub-list for method output_type
-	9,  // [9:11] is the sub-list for method input_type
-	8,  // [8:9] is the sub-list for extension type_name
-	6,  // [6:8] is the sub-list for extension extendee
-	0,  // [0:6] is the sub-list for field type_name
+	10, // 0: measurement.Measurement.timestamp:type_name -> google.protobuf.Timestamp
+	11, // 1: measurement.Measurement.temp:type_name -> google.protobuf.FloatValue
+	11, // 2: measurement.Measurement.pm25:type_name -> google.protobuf.FloatValue
+	11, // 3: measurement.Measurement.pm10:type_name -> google.protobuf.FloatValue
+	11, // 4: measurement.Measurement.rh:type_name -> google.protobuf.FloatValue
+	10, // 5: measurement.Measurement.upload_timestamp:type_name -> google.protobuf.Timestamp
+	6,  // 6: measurement.Measurement.raw_values:type_name -> measurement.RawValue
+	7,  // 7: measurement.Measurement.temp_alert:type_name -> measurement.TempAlert
+	5,  // 8: measurement.Measurement.readings:type_name -> measurement.Reading
+	12, // 9: measurement.Measurement.window:type_name -> google.protobuf.Duration
+	4,  // 10: measurement.Measurement.summaries:type_name -> measurement.Summary
+	12, // 11: measurement.Measurement.heartbeat:type_name -> google.protobuf.Duration
+	1,  // 12: measurement.MeasurementBatch.measurements:type_name -> measurement.Measurement
+	13, // 13: measurement.regex:extendee -> google.protobuf.FieldOptions
+	13, // 14: measurement.measurement_options:extendee -> google.protobuf.FieldOptions
+	0,  // 15: measurement.measurement_options:type_name -> measurement.MeasurementOptions
+	14, // 16: measurement.MeasurementService.GetDevices:input_type -> google.protobuf.Empty
+	9,  // 17: measurement.MeasurementService.GetLatest:input_type -> measurement.GetLatestRequest
+	8,  // 18: measurement.MeasurementService.GetDevices:output_type -> measurement.GetDevicesResponse
+	1,  // 19: measurement.MeasurementService.GetLatest:output_type -> measurement.Measurement
+	18, // [18:20] is the sub-list for method output_type
+	16, // [16:18] is the sub-list for method input_type
+	15, // [15:16] is the sub-list for extension type_name
+	13, // [13:15] is the sub-list for extension extendee
+	0,  // [0:13] is the sub-list for field type_name
 }
 
 func init() { file_measurement_proto_init() }
@@ -460,7 +1050,7 @@ func file_measurement_proto_init() {
 			}
 		}
 		file_measurement_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*GetDevicesResponse); i {
+			switch v := v.(*MeasurementBatch); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -472,6 +1062,78 @@ func file_measurement_proto_init() {
 			}
 		}
 		file_measurement_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SignedBatch); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_measurement_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Summary); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_measurement_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Reading); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_measurement_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RawValue); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_measurement_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*TempAlert); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_measurement_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GetDevicesResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_measurement_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*GetLatestRequest); i {
 			case 0:
 				return &v.state
@@ -490,7 +1152,7 @@ func file_measurement_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_measurement_proto_rawDesc,
 			NumEnums:      0,
-			NumMessages:   4,
+			NumMessages:   10,
 			NumExtensions: 2,
 			NumServices:   1,
 		},
@@ -14,6 +14,10 @@ import (
 	wpb "google.golang.org/protobuf/types/known/wrapperspb"
 )
 
+// BatchSubfolder is the subfolder of the telemetry topic to which devices publish
+// MeasurementBatch messages. IoT Core passes it on as the Pub/Sub message's subFolder attribute.
+const BatchSubfolder = "batch"
+
 func String(m mpb.Measurement) string {
 	var timestamp time.Time
 	if m.GetTimestamp() != nil {
@@ -66,10 +70,16 @@ func String(m mpb.Measurement) string {
 	return fmt.Sprintf("%s %s %s%s", m.GetDeviceId(), strings.Join(strs, ", "), timestamp.Format(time.RFC3339), delay)
 }
 
+// MaxFutureSkew is how far in the future a measurement's timestamp may be, to allow for devices'
+// clocks being a little ahead. A timestamp further in the future than this is from a device whose
+// clock is wrong.
+const MaxFutureSkew = time.Hour
+
 // Validate validates each field of the Measurement against an optional regex provided in the .proto file.
 // It returns nil if all fields are valid and no other errors occurred along the way. Example of how to
 // provide a regex in a .proto file:
 //   string device_id = 1 [(regex) = "^[a-z][a-z0-9+.%~_-]{2,254}$"];
+// It also rejects timestamps more than MaxFutureSkew in the future.
 func Validate(m *mpb.Measurement) error {
 	// First validate any required fields because protoreflect.Message.Range only
 	// iterates over "populated" fields. From the documentation on Range:
@@ -88,6 +98,12 @@ func Validate(m *mpb.Measurement) error {
 		return fmt.Errorf("measurementpbutil: field \"device_id\" is required")
 	}
 
+	if ts := m.GetTimestamp(); ts != nil {
+		if limit := time.Now().Add(MaxFutureSkew); ts.AsTime().After(limit) {
+			return fmt.Errorf("measurementpbutil: timestamp %v is more than %v in the future", ts.AsTime().Format(time.RFC3339), MaxFutureSkew)
+		}
+	}
+
 	var retErr error
 	r := m.ProtoReflect()
 	r.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
@@ -115,3 +131,44 @@ func Validate(m *mpb.Measurement) error {
 
 	return retErr
 }
+
+// Merge adds the values in src, which were reported by a single sensor, to dst. Each metric
+// that's set in src is recorded as a Reading tagged with the given sensor name and location,
+// and it's copied to the corresponding field of dst if that field isn't already set. Raw
+// values are appended and temperature alert bits are combined.
+func Merge(dst, src *mpb.Measurement, sensor, location string) {
+	dr := dst.ProtoReflect()
+	src.ProtoReflect().Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if !proto.HasExtension(fd.Options(), mpb.E_MeasurementOptions) {
+			return true
+		}
+
+		fv, ok := v.Message().Interface().(*wpb.FloatValue)
+		if !ok || fv == nil {
+			return true
+		}
+
+		dst.Readings = append(dst.Readings, &mpb.Reading{
+			Metric:   string(fd.Name()),
+			Sensor:   sensor,
+			Location: location,
+			Value:    fv.GetValue(),
+		})
+
+		if !dr.Has(fd) {
+			dr.Set(fd, protoreflect.ValueOfMessage(wpb.Float(fv.GetValue()).ProtoReflect()))
+		}
+		return true
+	})
+
+	dst.RawValues = append(dst.RawValues, src.GetRawValues()...)
+
+	if a := src.GetTempAlert(); a != nil {
+		if dst.TempAlert == nil {
+			dst.TempAlert = &mpb.TempAlert{}
+		}
+		dst.TempAlert.Lower = dst.TempAlert.GetLower() || a.GetLower()
+		dst.TempAlert.Upper = dst.TempAlert.GetUpper() || a.GetUpper()
+		dst.TempAlert.Critical = dst.TempAlert.GetCritical() || a.GetCritical()
+	}
+}
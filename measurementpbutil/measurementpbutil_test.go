@@ -4,7 +4,9 @@ import (
 	"testing"
 	"time"
 
+	"github.com/google/go-cmp/cmp"
 	mpb "github.com/mtraver/environmental-sensor/measurementpb"
+	"google.golang.org/protobuf/testing/protocmp"
 	tspb "google.golang.org/protobuf/types/known/timestamppb"
 	wpb "google.golang.org/protobuf/types/known/wrapperspb"
 )
@@ -59,6 +61,30 @@ func TestValidate(t *testing.T) {
 	}
 }
 
+func TestValidateTimestamp(t *testing.T) {
+	cases := []struct {
+		name   string
+		offset time.Duration
+		valid  bool
+	}{
+		{"past", -24 * time.Hour, true},
+		{"now", 0, true},
+		{"slightly_ahead", 10 * time.Minute, true},
+		{"far_future", 48 * time.Hour, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			m := getMeasurement(t, "foo")
+			m.Timestamp = tspb.New(time.Now().Add(c.offset))
+			err := Validate(m)
+			if valid := err == nil; valid != c.valid {
+				t.Errorf("got valid = %t, want %t (err = %v)", valid, c.valid, err)
+			}
+		})
+	}
+}
+
 func TestString(t *testing.T) {
 	cases := []struct {
 		name string
@@ -105,3 +131,43 @@ func TestString(t *testing.T) {
 		})
 	}
 }
+
+func TestMerge(t *testing.T) {
+	dst := &mpb.Measurement{
+		DeviceId:  "foo",
+		Timestamp: pbTimestamp,
+	}
+
+	Merge(dst, &mpb.Measurement{
+		Temp:      wpb.Float(18.5),
+		RawValues: []*mpb.RawValue{{Metric: "temp", Sensor: "a", Value: 18.0}},
+		TempAlert: &mpb.TempAlert{Upper: true},
+	}, "a", "probe-in")
+	Merge(dst, &mpb.Measurement{
+		Temp: wpb.Float(10.5),
+		Rh:   wpb.Float(60.0),
+	}, "b", "")
+
+	want := &mpb.Measurement{
+		DeviceId:  "foo",
+		Timestamp: pbTimestamp,
+		Temp:      wpb.Float(18.5),
+		Rh:        wpb.Float(60.0),
+		RawValues: []*mpb.RawValue{{Metric: "temp", Sensor: "a", Value: 18.0}},
+		TempAlert: &mpb.TempAlert{Upper: true},
+		Readings: []*mpb.Reading{
+			{Metric: "temp", Sensor: "a", Location: "probe-in", Value: 18.5},
+			{Metric: "temp", Sensor: "b", Value: 10.5},
+			{Metric: "rh", Sensor: "b", Value: 60.0},
+		},
+	}
+
+	if diff := cmp.Diff(dst, want, protocmp.Transform(), protocmp.SortRepeated(func(a, b *mpb.Reading) bool {
+		if a.GetSensor() != b.GetSensor() {
+			return a.GetSensor() < b.GetSensor()
+		}
+		return a.GetMetric() < b.GetMetric()
+	})); diff != "" {
+		t.Errorf("Unexpected result (-got +want):\n%s", diff)
+	}
+}
@@ -0,0 +1,75 @@
+package measurementpbutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+
+	mpb "github.com/mtraver/environmental-sensor/measurementpb"
+	"google.golang.org/protobuf/proto"
+)
+
+// SignedSubfolder is the subfolder of the telemetry topic to which devices publish
+// SignedBatch messages.
+const SignedSubfolder = "signed"
+
+// ErrBadSignature is returned by Verify if the signature of a SignedBatch doesn't
+// verify against any of the given keys.
+var ErrBadSignature = errors.New("measurementpbutil: bad signature")
+
+// ecdsaSignature is the ASN.1 structure of an ECDSA signature.
+type ecdsaSignature struct {
+	R, S *big.Int
+}
+
+// Sign returns a SignedBatch of the given measurements signed with the given key.
+func Sign(ms []*mpb.Measurement, key *ecdsa.PrivateKey) (*mpb.SignedBatch, error) {
+	b, err := proto.Marshal(&mpb.MeasurementBatch{Measurements: ms})
+	if err != nil {
+		return nil, err
+	}
+
+	digest := sha256.Sum256(b)
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		return nil, fmt.Errorf("measurementpbutil: failed to sign: %v", err)
+	}
+
+	sig, err := asn1.Marshal(ecdsaSignature{r, s})
+	if err != nil {
+		return nil, err
+	}
+
+	return &mpb.SignedBatch{
+		Batch:     b,
+		Signature: sig,
+	}, nil
+}
+
+// Verify checks the signature of the SignedBatch against each of the given keys and returns the
+// batch if it was signed with any of them. It returns ErrBadSignature if it wasn't.
+func Verify(sb *mpb.SignedBatch, keys []*ecdsa.PublicKey) (*mpb.MeasurementBatch, error) {
+	var sig ecdsaSignature
+	if rest, err := asn1.Unmarshal(sb.GetSignature(), &sig); err != nil || len(rest) != 0 || sig.R == nil || sig.S == nil {
+		return nil, ErrBadSignature
+	}
+
+	digest := sha256.Sum256(sb.GetBatch())
+	for _, key := range keys {
+		if !ecdsa.Verify(key, digest[:], sig.R, sig.S) {
+			continue
+		}
+
+		batch := &mpb.MeasurementBatch{}
+		if err := proto.Unmarshal(sb.GetBatch(), batch); err != nil {
+			return nil, err
+		}
+		return batch, nil
+	}
+
+	return nil, ErrBadSignature
+}
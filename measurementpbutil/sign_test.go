@@ -0,0 +1,68 @@
+package measurementpbutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	mpb "github.com/mtraver/environmental-sensor/measurementpb"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+func mustGenerateKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	return key
+}
+
+func TestSignVerify(t *testing.T) {
+	key := mustGenerateKey(t)
+	other := mustGenerateKey(t)
+	ms := []*mpb.Measurement{getMeasurement(t, "foo"), getMeasurement(t, "foo")}
+
+	sb, err := Sign(ms, key)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tampered := &mpb.SignedBatch{
+		Batch:     append([]byte{}, sb.Batch...),
+		Signature: sb.Signature,
+	}
+	tampered.Batch[len(tampered.Batch)-1] ^= 1
+
+	cases := []struct {
+		name  string
+		sb    *mpb.SignedBatch
+		keys  []*ecdsa.PublicKey
+		valid bool
+	}{
+		{"valid", sb, []*ecdsa.PublicKey{&key.PublicKey}, true},
+		{"second_key", sb, []*ecdsa.PublicKey{&other.PublicKey, &key.PublicKey}, true},
+		{"wrong_key", sb, []*ecdsa.PublicKey{&other.PublicKey}, false},
+		{"no_keys", sb, nil, false},
+		{"tampered", tampered, []*ecdsa.PublicKey{&key.PublicKey}, false},
+		{"no_signature", &mpb.SignedBatch{Batch: sb.Batch}, []*ecdsa.PublicKey{&key.PublicKey}, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			batch, err := Verify(c.sb, c.keys)
+			if valid := err == nil; valid != c.valid {
+				t.Fatalf("got valid = %t, want %t (err = %v)", valid, c.valid, err)
+			}
+			if !c.valid {
+				return
+			}
+
+			if diff := cmp.Diff(batch.GetMeasurements(), ms, protocmp.Transform()); diff != "" {
+				t.Errorf("Unexpected result (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
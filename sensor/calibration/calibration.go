@@ -0,0 +1,146 @@
+// Package calibration applies the sensor calibrations defined in the config proto
+// to measurements after they're taken.
+package calibration
+
+import (
+	"fmt"
+
+	"github.com/mtraver/environmental-sensor/configpb"
+	mpb "github.com/mtraver/environmental-sensor/measurementpb"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	wpb "google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// Calibrator holds the calibrations for a set of sensors.
+type Calibrator struct {
+	// Map of sensor name to the calibrations for that sensor.
+	calibrations map[string][]*configpb.Calibration
+}
+
+// New validates the given calibrations and returns a Calibrator that applies them.
+func New(calibrations []*configpb.Calibration) (*Calibrator, error) {
+	c := &Calibrator{
+		calibrations: make(map[string][]*configpb.Calibration),
+	}
+
+	seen := make(map[string]bool)
+	for _, cal := range calibrations {
+		if err := validate(cal); err != nil {
+			return nil, err
+		}
+
+		key := cal.GetSensor() + "/" + cal.GetMetric()
+		if seen[key] {
+			return nil, fmt.Errorf("calibration: more than one calibration for metric %q of sensor %q", cal.GetMetric(), cal.GetSensor())
+		}
+		seen[key] = true
+
+		c.calibrations[cal.GetSensor()] = append(c.calibrations[cal.GetSensor()], cal)
+	}
+
+	return c, nil
+}
+
+func validate(cal *configpb.Calibration) error {
+	if cal.GetSensor() == "" {
+		return fmt.Errorf("calibration: sensor must be set")
+	}
+
+	if _, err := metricField(cal.GetMetric()); err != nil {
+		return err
+	}
+
+	points := cal.GetPoints()
+	if len(points) == 1 {
+		return fmt.Errorf("calibration: %s/%s: at least two points are required", cal.GetSensor(), cal.GetMetric())
+	}
+	for i := 1; i < len(points); i++ {
+		if points[i].GetRaw() <= points[i-1].GetRaw() {
+			return fmt.Errorf("calibration: %s/%s: points must be sorted by raw value with no duplicates", cal.GetSensor(), cal.GetMetric())
+		}
+	}
+
+	return nil
+}
+
+// metricField returns the descriptor of the Measurement field with the given name. It returns
+// an error if no such field exists or if the field isn't a metric.
+func metricField(name string) (protoreflect.FieldDescriptor, error) {
+	fd := (&mpb.Measurement{}).ProtoReflect().Descriptor().Fields().ByName(protoreflect.Name(name))
+	if fd == nil {
+		return nil, fmt.Errorf("calibration: unknown metric %q", name)
+	}
+
+	if !proto.HasExtension(fd.Options(), mpb.E_MeasurementOptions) {
+		return nil, fmt.Errorf("calibration: field %q is not a metric", name)
+	}
+
+	return fd, nil
+}
+
+// Apply corrects the values in m that were reported by the named sensor. The raw value of
+// each corrected metric is appended to m's raw values. Metrics that aren't set in m are skipped.
+func (c *Calibrator) Apply(sensor string, m *mpb.Measurement) error {
+	if c == nil {
+		return nil
+	}
+
+	r := m.ProtoReflect()
+	for _, cal := range c.calibrations[sensor] {
+		fd, err := metricField(cal.GetMetric())
+		if err != nil {
+			return err
+		}
+
+		if !r.Has(fd) {
+			continue
+		}
+
+		fv, ok := r.Get(fd).Message().Interface().(*wpb.FloatValue)
+		if !ok {
+			return fmt.Errorf("calibration: field %q is not a FloatValue", cal.GetMetric())
+		}
+
+		raw := fv.GetValue()
+		r.Set(fd, protoreflect.ValueOfMessage(wpb.Float(Correct(cal, raw)).ProtoReflect()))
+
+		m.RawValues = append(m.RawValues, &mpb.RawValue{
+			Metric:             cal.GetMetric(),
+			Sensor:             sensor,
+			Value:              raw,
+			CalibrationVersion: cal.GetVersion(),
+		})
+	}
+
+	return nil
+}
+
+// Correct returns the calibrated value corresponding to the given raw value.
+func Correct(cal *configpb.Calibration, raw float32) float32 {
+	v := raw
+	if points := cal.GetPoints(); len(points) >= 2 {
+		v = interpolate(points, raw)
+	}
+
+	gain := cal.GetGain()
+	if gain == 0 {
+		gain = 1
+	}
+
+	return v*gain + cal.GetOffset()
+}
+
+// interpolate evaluates the piecewise-linear function defined by points at x. Points
+// must be sorted by raw value. Outside of the range of the points the first or last
+// segment is extended.
+func interpolate(points []*configpb.CalibrationPoint, x float32) float32 {
+	i := 1
+	for i < len(points)-1 && x > points[i].GetRaw() {
+		i++
+	}
+
+	p0, p1 := points[i-1], points[i]
+	slope := (p1.GetCorrected() - p0.GetCorrected()) / (p1.GetRaw() - p0.GetRaw())
+	return p0.GetCorrected() + slope*(x-p0.GetRaw())
+}
@@ -0,0 +1,112 @@
+package calibration
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/mtraver/environmental-sensor/configpb"
+	mpb "github.com/mtraver/environmental-sensor/measurementpb"
+	"google.golang.org/protobuf/testing/protocmp"
+	wpb "google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+var cmpFloats = cmpopts.EquateApprox(0, 0.0001)
+
+func points(p ...float32) []*configpb.CalibrationPoint {
+	var points []*configpb.CalibrationPoint
+	for i := 0; i+1 < len(p); i += 2 {
+		points = append(points, &configpb.CalibrationPoint{Raw: p[i], Corrected: p[i+1]})
+	}
+	return points
+}
+
+func TestCorrect(t *testing.T) {
+	cases := []struct {
+		name string
+		cal  *configpb.Calibration
+		raw  float32
+		want float32
+	}{
+		{"identity", &configpb.Calibration{}, 21.5, 21.5},
+		{"offset", &configpb.Calibration{Offset: -0.4}, 21.5, 21.1},
+		{"gain", &configpb.Calibration{Gain: 1.1}, 10, 11},
+		{"gain_and_offset", &configpb.Calibration{Gain: 2, Offset: 1}, 10, 21},
+		{"points_exact", &configpb.Calibration{Points: points(0, 0, 10, 12, 20, 22)}, 10, 12},
+		{"points_between", &configpb.Calibration{Points: points(0, 0, 10, 12, 20, 22)}, 15, 17},
+		{"points_below", &configpb.Calibration{Points: points(0, 0, 10, 12, 20, 22)}, -5, -6},
+		{"points_above", &configpb.Calibration{Points: points(0, 0, 10, 12, 20, 22)}, 30, 32},
+		{"points_and_offset", &configpb.Calibration{Points: points(0, 0, 10, 12), Offset: 1}, 5, 7},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := Correct(c.cal, c.raw); !cmp.Equal(got, c.want, cmpFloats) {
+				t.Errorf("got %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	cases := []struct {
+		name  string
+		cals  []*configpb.Calibration
+		valid bool
+	}{
+		{"empty", nil, true},
+		{"valid", []*configpb.Calibration{
+			{Sensor: "mcp9808", Metric: "temp", Offset: -0.4},
+			{Sensor: "sds011", Metric: "pm25", Points: points(0, 0, 50, 40)},
+		}, true},
+		{"no_sensor", []*configpb.Calibration{{Metric: "temp"}}, false},
+		{"unknown_metric", []*configpb.Calibration{{Sensor: "mcp9808", Metric: "foo"}}, false},
+		{"not_a_metric", []*configpb.Calibration{{Sensor: "mcp9808", Metric: "device_id"}}, false},
+		{"one_point", []*configpb.Calibration{{Sensor: "mcp9808", Metric: "temp", Points: points(0, 1)}}, false},
+		{"unsorted_points", []*configpb.Calibration{{Sensor: "mcp9808", Metric: "temp", Points: points(10, 0, 0, 1)}}, false},
+		{"duplicate", []*configpb.Calibration{
+			{Sensor: "mcp9808", Metric: "temp", Offset: -0.4},
+			{Sensor: "mcp9808", Metric: "temp", Offset: -0.2},
+		}, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := New(c.cals)
+			if valid := err == nil; valid != c.valid {
+				t.Errorf("got valid = %t, want %t (err = %v)", valid, c.valid, err)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	c, err := New([]*configpb.Calibration{
+		{Sensor: "mcp9808", Metric: "temp", Offset: -0.5, Version: "v2"},
+		{Sensor: "sds011", Metric: "pm25", Gain: 2},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	m := &mpb.Measurement{
+		DeviceId: "foo",
+		Temp:     wpb.Float(21.5),
+		Pm25:     wpb.Float(10),
+	}
+	if err := c.Apply("mcp9808", m); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := &mpb.Measurement{
+		DeviceId: "foo",
+		Temp:     wpb.Float(21),
+		Pm25:     wpb.Float(10),
+		RawValues: []*mpb.RawValue{
+			{Metric: "temp", Sensor: "mcp9808", Value: 21.5, CalibrationVersion: "v2"},
+		},
+	}
+	if diff := cmp.Diff(m, want, protocmp.Transform()); diff != "" {
+		t.Errorf("Unexpected result (-got +want):\n%s", diff)
+	}
+}
@@ -0,0 +1,210 @@
+// Package discover finds sensors connected to an I²C bus or to serial ports.
+package discover
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"periph.io/x/periph/conn/i2c"
+)
+
+// Device is a device found on a bus.
+type Device struct {
+	// The device's model, e.g. "MCP9808". If a device responded but couldn't be identified
+	// this is the model that's known to use its address followed by a question mark.
+	Model string
+
+	// The name of the sensor in the iotcorelogger config, e.g. "mcp9808". Empty if
+	// the device isn't a supported sensor or if it wasn't identified.
+	Sensor string
+
+	// The device's I²C address. Zero for serial devices.
+	Addr uint16
+
+	// The serial port the device is connected to. Empty for I²C devices.
+	Port string
+
+	// Whether the device was positively identified, e.g. by reading its device ID register.
+	Identified bool
+}
+
+func (d Device) String() string {
+	loc := fmt.Sprintf("I²C address %#x", d.Addr)
+	if d.Port != "" {
+		loc = "serial port " + d.Port
+	}
+
+	if !d.Identified {
+		return fmt.Sprintf("unidentified device at %s (%s)", loc, d.Model)
+	}
+	return fmt.Sprintf("%s at %s", d.Model, loc)
+}
+
+// i2cProbe describes how to find one kind of I²C device.
+type i2cProbe struct {
+	model  string
+	sensor string
+	addrs  []uint16
+
+	// identify talks to the device and returns the specific model if it's positively
+	// identified. It returns an error if the device didn't respond at all.
+	identify func(d *i2c.Dev) (string, bool, error)
+}
+
+var i2cProbes = []i2cProbe{
+	{
+		model:    "MCP9808",
+		sensor:   "mcp9808",
+		addrs:    addrRange(0x18, 0x1f),
+		identify: identifyMCP9808,
+	},
+	{
+		model:    "BME280",
+		addrs:    []uint16{0x76, 0x77},
+		identify: identifyBME280,
+	},
+	{
+		model:    "SHT3x",
+		addrs:    []uint16{0x44, 0x45},
+		identify: identifySHT3x,
+	},
+	{
+		model:    "SCD4x",
+		addrs:    []uint16{0x62},
+		identify: identifySCD4x,
+	},
+}
+
+func addrRange(first, last uint16) []uint16 {
+	var addrs []uint16
+	for a := first; a <= last; a++ {
+		addrs = append(addrs, a)
+	}
+	return addrs
+}
+
+// ScanI2C probes the bus at the addresses of known devices and returns the devices that
+// respond, in the order in which they were found.
+func ScanI2C(bus i2c.Bus) []Device {
+	var found []Device
+	for _, p := range i2cProbes {
+		for _, addr := range p.addrs {
+			model, ok, err := p.identify(&i2c.Dev{Bus: bus, Addr: addr})
+			if err != nil {
+				// Nothing at this address.
+				continue
+			}
+
+			d := Device{
+				Model:      p.model + "?",
+				Addr:       addr,
+				Identified: ok,
+			}
+			if ok {
+				d.Model = model
+				d.Sensor = p.sensor
+			}
+			found = append(found, d)
+		}
+	}
+
+	return found
+}
+
+// identifyMCP9808 checks the manufacturer ID and device ID registers.
+func identifyMCP9808(d *i2c.Dev) (string, bool, error) {
+	manufacturer := make([]byte, 2)
+	if err := d.Tx([]byte{0x06}, manufacturer); err != nil {
+		return "", false, err
+	}
+
+	device := make([]byte, 2)
+	if err := d.Tx([]byte{0x07}, device); err != nil {
+		return "", false, err
+	}
+
+	ok := binary.BigEndian.Uint16(manufacturer) == 0x0054 && device[0] == 0x04
+	return "MCP9808", ok, nil
+}
+
+// identifyBME280 checks the chip ID register. The BMP280 has the same address and
+// register layout, so it's identified too.
+func identifyBME280(d *i2c.Dev) (string, bool, error) {
+	id := make([]byte, 1)
+	if err := d.Tx([]byte{0xd0}, id); err != nil {
+		return "", false, err
+	}
+
+	switch id[0] {
+	case 0x60:
+		return "BME280", true, nil
+	case 0x56, 0x57, 0x58:
+		return "BMP280", true, nil
+	}
+	return "", false, nil
+}
+
+// identifySHT3x reads the status register. The SHT3x has no ID register, so a response
+// with a valid checksum is taken as identification.
+func identifySHT3x(d *i2c.Dev) (string, bool, error) {
+	resp, err := sensirionCommand(d, 0xf32d, 1)
+	if err != nil {
+		return "", false, err
+	}
+	return "SHT3x", resp != nil, nil
+}
+
+// identifySCD4x reads the serial number. The sensor must not be taking periodic measurements.
+func identifySCD4x(d *i2c.Dev) (string, bool, error) {
+	resp, err := sensirionCommand(d, 0x3682, 3)
+	if err != nil {
+		return "", false, err
+	}
+	return "SCD4x", resp != nil, nil
+}
+
+// sensirionCommand sends a 16-bit command to a Sensirion sensor and reads the given number
+// of 16-bit words in response. Each word is followed by a CRC. It returns a nil slice and
+// a nil error if the device responded but a CRC didn't match.
+func sensirionCommand(d *i2c.Dev, cmd uint16, words int) ([]uint16, error) {
+	w := make([]byte, 2)
+	binary.BigEndian.PutUint16(w, cmd)
+	if err := d.Tx(w, nil); err != nil {
+		return nil, err
+	}
+
+	// Both the SHT3x and SCD4x need a moment before the response can be read.
+	time.Sleep(time.Millisecond)
+
+	r := make([]byte, 3*words)
+	if err := d.Tx(nil, r); err != nil {
+		return nil, err
+	}
+
+	resp := make([]uint16, words)
+	for i := range resp {
+		word := r[3*i : 3*i+2]
+		if sensirionCRC(word) != r[3*i+2] {
+			return nil, nil
+		}
+		resp[i] = binary.BigEndian.Uint16(word)
+	}
+	return resp, nil
+}
+
+// sensirionCRC computes the CRC-8 used by Sensirion sensors: polynomial 0x31, initial value 0xff.
+func sensirionCRC(b []byte) byte {
+	crc := byte(0xff)
+	for _, v := range b {
+		crc ^= v
+		for i := 0; i < 8; i++ {
+			if crc&0x80 != 0 {
+				crc = crc<<1 ^ 0x31
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
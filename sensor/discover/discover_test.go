@@ -0,0 +1,183 @@
+package discover
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"periph.io/x/periph/conn/physic"
+)
+
+// fakeBus is an I²C bus with devices at the addresses in the map. Transactions to any other
+// address fail, as they would if no device acknowledged.
+type fakeBus map[uint16]func(w, r []byte) error
+
+func (b fakeBus) Tx(addr uint16, w, r []byte) error {
+	dev, ok := b[addr]
+	if !ok {
+		return errors.New("no ack")
+	}
+	return dev(w, r)
+}
+
+func (b fakeBus) SetSpeed(f physic.Frequency) error { return nil }
+func (b fakeBus) String() string                    { return "fake" }
+
+// registers returns a device that responds to reads of the given registers.
+func registers(regs map[byte][]byte) func(w, r []byte) error {
+	return func(w, r []byte) error {
+		if len(w) == 1 {
+			copy(r, regs[w[0]])
+		}
+		return nil
+	}
+}
+
+// sensirion returns a device that responds to the given 16-bit command with the given words,
+// each followed by a CRC.
+func sensirion(cmd uint16, words ...uint16) func(w, r []byte) error {
+	var last uint16
+	return func(w, r []byte) error {
+		if len(w) == 2 {
+			last = uint16(w[0])<<8 | uint16(w[1])
+			return nil
+		}
+		if last != cmd {
+			return errors.New("unexpected command")
+		}
+
+		var resp []byte
+		for _, word := range words {
+			b := []byte{byte(word >> 8), byte(word)}
+			resp = append(resp, b[0], b[1], sensirionCRC(b))
+		}
+		copy(r, resp)
+		return nil
+	}
+}
+
+func TestSensirionCRC(t *testing.T) {
+	// The example from the SHT3x datasheet.
+	if got := sensirionCRC([]byte{0xbe, 0xef}); got != 0x92 {
+		t.Errorf("got %#02x, want 0x92", got)
+	}
+}
+
+func TestScanI2C(t *testing.T) {
+	cases := []struct {
+		name string
+		bus  fakeBus
+		want []Device
+	}{
+		{"empty", fakeBus{}, nil},
+		{"mcp9808", fakeBus{
+			0x19: registers(map[byte][]byte{0x06: {0x00, 0x54}, 0x07: {0x04, 0x00}}),
+		}, []Device{{Model: "MCP9808", Sensor: "mcp9808", Addr: 0x19, Identified: true}}},
+		{"mcp9808_wrong_id", fakeBus{
+			0x18: registers(map[byte][]byte{0x06: {0x00, 0x00}, 0x07: {0x00, 0x00}}),
+		}, []Device{{Model: "MCP9808?", Addr: 0x18}}},
+		{"bme280", fakeBus{
+			0x76: registers(map[byte][]byte{0xd0: {0x60}}),
+		}, []Device{{Model: "BME280", Addr: 0x76, Identified: true}}},
+		{"bmp280", fakeBus{
+			0x77: registers(map[byte][]byte{0xd0: {0x58}}),
+		}, []Device{{Model: "BMP280", Addr: 0x77, Identified: true}}},
+		{"sht3x", fakeBus{
+			0x44: sensirion(0xf32d, 0x8010),
+		}, []Device{{Model: "SHT3x", Addr: 0x44, Identified: true}}},
+		{"sht3x_bad_crc", fakeBus{
+			0x45: registers(nil),
+		}, []Device{{Model: "SHT3x?", Addr: 0x45}}},
+		{"scd4x", fakeBus{
+			0x62: sensirion(0x3682, 0xf896, 0x9f07, 0x3bbe),
+		}, []Device{{Model: "SCD4x", Addr: 0x62, Identified: true}}},
+		{"several", fakeBus{
+			0x18: registers(map[byte][]byte{0x06: {0x00, 0x54}, 0x07: {0x04, 0x01}}),
+			0x1a: registers(map[byte][]byte{0x06: {0x00, 0x54}, 0x07: {0x04, 0x01}}),
+			0x76: registers(map[byte][]byte{0xd0: {0x60}}),
+		}, []Device{
+			{Model: "MCP9808", Sensor: "mcp9808", Addr: 0x18, Identified: true},
+			{Model: "MCP9808", Sensor: "mcp9808", Addr: 0x1a, Identified: true},
+			{Model: "BME280", Addr: 0x76, Identified: true},
+		}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := ScanI2C(c.bus)
+			if diff := cmp.Diff(got, c.want); diff != "" {
+				t.Errorf("Unexpected result (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+// fakePort is a serial port that returns the bytes in passive until it's written to, after
+// which it returns the bytes in reply.
+type fakePort struct {
+	passive []byte
+	reply   []byte
+	written []byte
+}
+
+func (p *fakePort) Read(b []byte) (int, error) {
+	src := &p.passive
+	if p.written != nil {
+		src = &p.reply
+	}
+	if len(*src) == 0 {
+		return 0, io.EOF
+	}
+
+	n := copy(b, *src)
+	*src = (*src)[n:]
+	return n, nil
+}
+
+func (p *fakePort) Write(b []byte) (int, error) {
+	p.written = append(p.written, b...)
+	return len(b), nil
+}
+
+func TestProbeSerial(t *testing.T) {
+	sds011Frame := []byte{0xaa, 0xc0, 0xd4, 0x04, 0x3a, 0x0a, 0xa1, 0x60, 0x1d, 0xab}
+	pmsFrame := []byte{0x42, 0x4d, 0x00, 0x04, 0x01, 0x02, 0x00, 0x96}
+
+	cases := []struct {
+		name      string
+		port      *fakePort
+		want      Device
+		wantFound bool
+		wantQuery bool
+	}{
+		{"nothing", &fakePort{}, Device{}, false, true},
+		{"garbage", &fakePort{passive: []byte{0x01, 0x02, 0xaa, 0xc0}}, Device{}, false, true},
+		{"sds011_active", &fakePort{passive: append([]byte{0xff, 0x00}, sds011Frame...)},
+			Device{Model: "SDS011", Sensor: "sds011", Port: "/dev/ttyUSB0", Identified: true}, true, false},
+		{"sds011_query", &fakePort{reply: sds011Frame},
+			Device{Model: "SDS011", Sensor: "sds011", Port: "/dev/ttyUSB0", Identified: true}, true, true},
+		{"sds011_bad_checksum", &fakePort{passive: append(sds011Frame[:8:8], 0x00, 0xab)}, Device{}, false, true},
+		{"pms", &fakePort{passive: pmsFrame},
+			Device{Model: "PMS", Port: "/dev/ttyUSB0", Identified: true}, true, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, found, err := probeSerial(c.port, "/dev/ttyUSB0", 0)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if found != c.wantFound {
+				t.Errorf("got found = %t, want %t", found, c.wantFound)
+			}
+			if diff := cmp.Diff(got, c.want); diff != "" {
+				t.Errorf("Unexpected result (-got +want):\n%s", diff)
+			}
+			if queried := bytes.Equal(c.port.written, sds011Query); queried != c.wantQuery {
+				t.Errorf("got queried = %t, want %t", queried, c.wantQuery)
+			}
+		})
+	}
+}
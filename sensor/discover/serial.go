@@ -0,0 +1,125 @@
+package discover
+
+import (
+	"io"
+	"time"
+
+	serial "github.com/albenik/go-serial/v2"
+)
+
+const (
+	sds011FrameLen = 10
+	pmsHeaderLen   = 4
+)
+
+// sds011Query asks an SDS011 in query mode for a measurement. It's addressed to all sensors (0xffff).
+var sds011Query = []byte{0xaa, 0xb4, 0x04, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0xff, 0xff, 0x02, 0xab}
+
+// ProbeSerial opens the given serial port and listens for frames from an SDS011 or a
+// Plantower PMS sensor. It returns false if no known frames were seen.
+func ProbeSerial(port string, listen time.Duration) (Device, bool, error) {
+	p, err := serial.Open(port, serial.WithBaudrate(9600), serial.WithDataBits(8),
+		serial.WithParity(serial.NoParity), serial.WithStopBits(serial.OneStopBit), serial.WithReadTimeout(100))
+	if err != nil {
+		return Device{}, false, err
+	}
+	defer p.Close()
+
+	return probeSerial(p, port, listen)
+}
+
+// probeSerial listens for frames on rw. Sensors in active mode send frames without being asked,
+// so it first listens passively. If nothing is heard it sends an SDS011 query and listens again,
+// as that's how an SDS011 in query mode (as iotcorelogger leaves it) can be found.
+func probeSerial(rw io.ReadWriter, port string, listen time.Duration) (Device, bool, error) {
+	buf, err := readFor(rw, listen)
+	if err != nil {
+		return Device{}, false, err
+	}
+	if model, sensor, ok := identifyFrames(buf); ok {
+		return Device{Model: model, Sensor: sensor, Port: port, Identified: true}, true, nil
+	}
+
+	if _, err := rw.Write(sds011Query); err != nil {
+		return Device{}, false, err
+	}
+	buf, err = readFor(rw, listen)
+	if err != nil {
+		return Device{}, false, err
+	}
+	if model, sensor, ok := identifyFrames(buf); ok {
+		return Device{Model: model, Sensor: sensor, Port: port, Identified: true}, true, nil
+	}
+
+	return Device{}, false, nil
+}
+
+// readFor reads from r until the given duration has passed or r returns io.EOF. It reads
+// at least once.
+func readFor(r io.Reader, d time.Duration) ([]byte, error) {
+	var buf []byte
+	chunk := make([]byte, 64)
+	deadline := time.Now().Add(d)
+	for {
+		n, err := r.Read(chunk)
+		buf = append(buf, chunk[:n]...)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return buf, err
+		}
+
+		if !time.Now().Before(deadline) {
+			break
+		}
+	}
+	return buf, nil
+}
+
+// identifyFrames looks for a complete, valid frame from a known sensor anywhere in b.
+func identifyFrames(b []byte) (model string, sensor string, ok bool) {
+	for i := range b {
+		if isSDS011Frame(b[i:]) {
+			return "SDS011", "sds011", true
+		}
+		if isPMSFrame(b[i:]) {
+			return "PMS", "", true
+		}
+	}
+	return "", "", false
+}
+
+// isSDS011Frame reports whether b starts with a data or command reply frame from an SDS011.
+func isSDS011Frame(b []byte) bool {
+	if len(b) < sds011FrameLen {
+		return false
+	}
+	if b[0] != 0xaa || (b[1] != 0xc0 && b[1] != 0xc5) || b[9] != 0xab {
+		return false
+	}
+
+	var sum byte
+	for _, v := range b[2:8] {
+		sum += v
+	}
+	return sum == b[8]
+}
+
+// isPMSFrame reports whether b starts with a frame from a Plantower PMS sensor (e.g. the PMS5003).
+// The frame is a two-byte header, a two-byte length, data, and a two-byte sum of all preceding bytes.
+func isPMSFrame(b []byte) bool {
+	if len(b) < pmsHeaderLen || b[0] != 0x42 || b[1] != 0x4d {
+		return false
+	}
+
+	n := int(b[2])<<8 | int(b[3])
+	if n < 2 || len(b) < pmsHeaderLen+n {
+		return false
+	}
+
+	var sum uint16
+	for _, v := range b[:pmsHeaderLen+n-2] {
+		sum += uint16(v)
+	}
+	return sum == uint16(b[pmsHeaderLen+n-2])<<8|uint16(b[pmsHeaderLen+n-1])
+}
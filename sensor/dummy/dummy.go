@@ -4,10 +4,17 @@ import (
 	"log"
 
 	mpb "github.com/mtraver/environmental-sensor/measurementpb"
+	"github.com/mtraver/environmental-sensor/sensor"
 )
 
 type Dummy struct{}
 
+func (d Dummy) Capabilities() sensor.Capabilities {
+	return sensor.Capabilities{
+		Model: "dummy",
+	}
+}
+
 func (d Dummy) Init() error {
 	log.Printf("DUMMY SENSOR INIT")
 	return nil
@@ -1,11 +1,17 @@
 package mcp9808
 
 import (
+	"encoding/binary"
+	"fmt"
+	"math"
 	"time"
 
+	"github.com/mtraver/environmental-sensor/configpb"
 	mpb "github.com/mtraver/environmental-sensor/measurementpb"
+	"github.com/mtraver/environmental-sensor/sensor"
 	wpb "google.golang.org/protobuf/types/known/wrapperspb"
 	"periph.io/x/periph/conn/i2c"
+	"periph.io/x/periph/conn/mmr"
 	"periph.io/x/periph/conn/physic"
 	"periph.io/x/periph/experimental/devices/mcp9808"
 )
@@ -15,19 +21,154 @@ const (
 	sampleInterval = 1
 )
 
+// Register addresses. See the datasheet:
+// https://ww1.microchip.com/downloads/en/DeviceDoc/25095A.pdf
+const (
+	regConfig     byte = 0x01
+	regUpperAlert byte = 0x02
+	regLowerAlert byte = 0x03
+	regCritAlert  byte = 0x04
+	regTemp       byte = 0x05
+)
+
+// Bits of the config register.
+const (
+	configHystShift     = 9
+	configIntClear      = 1 << 5
+	configAlertControl  = 1 << 3
+	configAlertSelect   = 1 << 2
+	configAlertPolarity = 1 << 1
+	configAlertMode     = 1 << 0
+)
+
+// Alert status bits of the ambient temperature register.
+const (
+	tempCritical = 1 << 15
+	tempUpper    = 1 << 14
+	tempLower    = 1 << 13
+)
+
+// Opts configures an MCP9808. The zero value uses the default address and resolution
+// and leaves the alert output disabled.
+type Opts struct {
+	mcp9808.Opts
+	Alert *configpb.MCP9808Alert
+}
+
+// OptsFromConfig converts the config proto to Opts.
+func OptsFromConfig(c *configpb.MCP9808Config) (Opts, error) {
+	opts := Opts{
+		Alert: c.GetAlert(),
+	}
+	opts.Addr = int(c.GetAddress())
+
+	switch c.GetResolution() {
+	case configpb.MCP9808Config_RESOLUTION_0_5:
+		opts.Res = mcp9808.Low
+	case configpb.MCP9808Config_RESOLUTION_0_25:
+		opts.Res = mcp9808.Medium
+	case configpb.MCP9808Config_RESOLUTION_0_125:
+		opts.Res = mcp9808.High
+	case configpb.MCP9808Config_RESOLUTION_DEFAULT, configpb.MCP9808Config_RESOLUTION_0_0625:
+		opts.Res = mcp9808.Maximum
+	default:
+		return Opts{}, fmt.Errorf("mcp9808: unknown resolution %v", c.GetResolution())
+	}
+
+	if a := opts.Alert; a != nil && !(a.GetLower() < a.GetUpper() && a.GetUpper() < a.GetCritical()) {
+		return Opts{}, fmt.Errorf("mcp9808: alert limits must satisfy lower < upper < critical")
+	}
+
+	return opts, nil
+}
+
 type MCP9808 struct {
-	dev *mcp9808.Dev
+	regs  mmr.Dev8
+	addr  uint16
+	alert bool
+
+	// The value written to the config register.
+	config uint16
 }
 
-func New(bus i2c.BusCloser) (*MCP9808, error) {
-	d, err := mcp9808.New(bus, &mcp9808.DefaultOpts)
-	if err != nil {
+func New(bus i2c.Bus, opts Opts) (*MCP9808, error) {
+	// periph's driver sets the resolution and takes the sensor out of shutdown.
+	// Everything else is done with direct register access because the driver
+	// overwrites the alert limit registers each time it checks for alerts.
+	if _, err := mcp9808.New(bus, &opts.Opts); err != nil {
 		return nil, err
 	}
 
-	return &MCP9808{
-		dev: d,
-	}, nil
+	addr := mcp9808.DefaultOpts.Addr
+	if opts.Addr != 0 {
+		addr = opts.Addr
+	}
+	s := &MCP9808{
+		regs: mmr.Dev8{
+			Conn:  &i2c.Dev{Bus: bus, Addr: uint16(addr)},
+			Order: binary.BigEndian,
+		},
+		addr: uint16(addr),
+	}
+
+	if opts.Alert != nil {
+		if err := s.setAlert(opts.Alert); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// setAlert programs the alert limit registers and enables the alert output.
+func (s *MCP9808) setAlert(a *configpb.MCP9808Alert) error {
+	limits := []struct {
+		reg byte
+		c   float32
+	}{
+		{regLowerAlert, a.GetLower()},
+		{regUpperAlert, a.GetUpper()},
+		{regCritAlert, a.GetCritical()},
+	}
+	for _, l := range limits {
+		b, err := alertBits(l.c)
+		if err != nil {
+			return err
+		}
+		if err := s.regs.WriteUint16(l.reg, b); err != nil {
+			return fmt.Errorf("mcp9808: failed to write alert limit: %v", err)
+		}
+	}
+
+	config := configWord(a)
+	if err := s.regs.WriteUint16(regConfig, config); err != nil {
+		return fmt.Errorf("mcp9808: failed to write config: %v", err)
+	}
+
+	s.alert = true
+	s.config = config
+	return nil
+}
+
+// clearInterrupt clears the alert output if it's in interrupt mode. In that mode the output
+// stays asserted until it's cleared, so it's cleared each time the alert status is read.
+func (s *MCP9808) clearInterrupt() error {
+	w, ok := interruptClearWord(s.config)
+	if !ok {
+		return nil
+	}
+	if err := s.regs.WriteUint16(regConfig, w); err != nil {
+		return fmt.Errorf("mcp9808: failed to clear interrupt: %v", err)
+	}
+	return nil
+}
+
+func (s *MCP9808) Capabilities() sensor.Capabilities {
+	return sensor.Capabilities{
+		Metrics: []string{"temp"},
+		Model:   "MCP9808",
+		Bus:     fmt.Sprintf("i2c:%#x", s.addr),
+	}
 }
 
 func (s *MCP9808) Init() error {
@@ -35,12 +176,22 @@ func (s *MCP9808) Init() error {
 }
 
 func (s *MCP9808) Sense(m *mpb.Measurement) error {
-	temps, err := s.readTempMulti(numSamples, time.Duration(sampleInterval)*time.Second)
+	temps, status, err := s.readTempMulti(numSamples, time.Duration(sampleInterval)*time.Second)
 	if err != nil {
 		return err
 	}
 
 	m.Temp = wpb.Float(mean(temps))
+	if s.alert {
+		m.TempAlert = &mpb.TempAlert{
+			Lower:    status&tempLower != 0,
+			Upper:    status&tempUpper != 0,
+			Critical: status&tempCritical != 0,
+		}
+		if err := s.clearInterrupt(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -48,21 +199,72 @@ func (s *MCP9808) Shutdown() error {
 	return nil
 }
 
-func (s *MCP9808) readTempMulti(samples int, interval time.Duration) ([]physic.Temperature, error) {
+// readTempMulti reads the temperature the given number of times. It also returns
+// the alert status bits of all samples ORed together.
+func (s *MCP9808) readTempMulti(samples int, interval time.Duration) ([]physic.Temperature, uint16, error) {
 	temps := make([]physic.Temperature, samples)
+	var status uint16
 	for i := 0; i < samples; i++ {
-		temp, err := s.dev.SenseTemp()
+		b, err := s.regs.ReadUint16(regTemp)
 		if err != nil {
-			return temps, err
+			return temps, status, fmt.Errorf("mcp9808: failed to read temperature: %v", err)
 		}
 
-		temps[i] = temp
+		temps[i] = bitsToTemperature(b)
+		status |= b & (tempCritical | tempUpper | tempLower)
 		if i < samples-1 {
 			time.Sleep(interval)
 		}
 	}
 
-	return temps, nil
+	return temps, status, nil
+}
+
+// bitsToTemperature converts the contents of the ambient temperature register to a temperature.
+func bitsToTemperature(b uint16) physic.Temperature {
+	t := physic.Temperature(b&0x0fff) * 62500 * physic.MicroKelvin
+	if b&0x1000 != 0 {
+		// Account for the sign bit.
+		t -= 256 * physic.Celsius
+	}
+	return t + physic.ZeroCelsius
+}
+
+// alertBits converts a temperature in °C to the format of the alert limit registers.
+func alertBits(c float32) (uint16, error) {
+	if c < -40 || c > 125 {
+		return 0, fmt.Errorf("mcp9808: alert limit %v°C out of range [-40, 125]", c)
+	}
+
+	// The registers have a resolution of 0.25°C and hold a two's complement value
+	// in bits 12 through 2.
+	quarters := int16(math.Round(float64(c) * 4))
+	return (uint16(quarters) << 2) & 0x1ffc, nil
+}
+
+// configWord returns the value of the config register that enables the given alert.
+func configWord(a *configpb.MCP9808Alert) uint16 {
+	w := uint16(a.GetHysteresis())<<configHystShift | configAlertControl
+	if a.GetCriticalOnly() {
+		w |= configAlertSelect
+	}
+	if a.GetActiveHigh() {
+		w |= configAlertPolarity
+	}
+	if a.GetInterruptMode() {
+		w |= configAlertMode
+	}
+	return w
+}
+
+// interruptClearWord returns the value to write to the config register to clear the alert
+// output, given the register's value. It returns false if the output isn't in interrupt
+// mode, in which case there's nothing to clear.
+func interruptClearWord(config uint16) (uint16, bool) {
+	if config&configAlertMode == 0 {
+		return 0, false
+	}
+	return config | configIntClear, true
 }
 
 func mean(s []physic.Temperature) float32 {
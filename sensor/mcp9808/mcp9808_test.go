@@ -0,0 +1,214 @@
+package mcp9808
+
+import (
+	"testing"
+
+	"github.com/mtraver/environmental-sensor/configpb"
+	"periph.io/x/periph/conn/i2c/i2ctest"
+	"periph.io/x/periph/conn/physic"
+)
+
+func TestBitsToTemperature(t *testing.T) {
+	cases := []struct {
+		name string
+		b    uint16
+		want physic.Temperature
+	}{
+		{"zero", 0x0000, physic.ZeroCelsius},
+		{"positive", 0x0190, 25*physic.Celsius + physic.ZeroCelsius},
+		{"fraction", 0x0194, 25*physic.Celsius + 250*physic.MilliCelsius + physic.ZeroCelsius},
+		{"negative", 0x1ff0, -1*physic.Celsius + physic.ZeroCelsius},
+		{"alert_bits_ignored", 0xe190, 25*physic.Celsius + physic.ZeroCelsius},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := bitsToTemperature(c.b); got != c.want {
+				t.Errorf("got %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestAlertBits(t *testing.T) {
+	cases := []struct {
+		name  string
+		c     float32
+		want  uint16
+		valid bool
+	}{
+		{"zero", 0, 0x0000, true},
+		{"positive", 25, 0x0190, true},
+		{"quarter", 25.25, 0x0194, true},
+		{"rounded", 25.3, 0x0194, true},
+		{"negative", -1, 0x1ff0, true},
+		{"max", 125, 0x07d0, true},
+		{"min", -40, 0x1d80, true},
+		{"too_high", 125.5, 0, false},
+		{"too_low", -40.5, 0, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := alertBits(c.c)
+			if valid := err == nil; valid != c.valid {
+				t.Fatalf("got valid = %t, want %t (err = %v)", valid, c.valid, err)
+			}
+			if got != c.want {
+				t.Errorf("got %#04x, want %#04x", got, c.want)
+			}
+		})
+	}
+}
+
+func TestConfigWord(t *testing.T) {
+	cases := []struct {
+		name  string
+		alert *configpb.MCP9808Alert
+		want  uint16
+
+		// The value written to clear the interrupt, or 0 if the output isn't in interrupt mode.
+		clear uint16
+	}{
+		{"defaults", &configpb.MCP9808Alert{}, 0x0008, 0},
+		{"hysteresis", &configpb.MCP9808Alert{Hysteresis: configpb.MCP9808Alert_HYSTERESIS_6}, 0x0608, 0},
+		{"interrupt", &configpb.MCP9808Alert{InterruptMode: true}, 0x0009, 0x0029},
+		{"all", &configpb.MCP9808Alert{
+			Hysteresis:    configpb.MCP9808Alert_HYSTERESIS_1_5,
+			InterruptMode: true,
+			ActiveHigh:    true,
+			CriticalOnly:  true,
+		}, 0x020f, 0x022f},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := configWord(c.alert)
+			if got != c.want {
+				t.Errorf("got %#04x, want %#04x", got, c.want)
+			}
+
+			clear, ok := interruptClearWord(got)
+			if wantOK := c.clear != 0; ok != wantOK {
+				t.Fatalf("got ok = %t, want %t", ok, wantOK)
+			}
+			if clear != c.clear {
+				t.Errorf("got clear %#04x, want %#04x", clear, c.clear)
+			}
+		})
+	}
+}
+
+func TestOptsFromConfig(t *testing.T) {
+	cases := []struct {
+		name   string
+		config *configpb.MCP9808Config
+		valid  bool
+	}{
+		{"nil", nil, true},
+		{"resolution", &configpb.MCP9808Config{Resolution: configpb.MCP9808Config_RESOLUTION_0_25}, true},
+		{"alert", &configpb.MCP9808Config{Alert: &configpb.MCP9808Alert{Lower: 10, Upper: 30, Critical: 40}}, true},
+		{"alert_unordered", &configpb.MCP9808Config{Alert: &configpb.MCP9808Alert{Lower: 10, Upper: 40, Critical: 30}}, false},
+		{"bad_resolution", &configpb.MCP9808Config{Resolution: 17}, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := OptsFromConfig(c.config)
+			if valid := err == nil; valid != c.valid {
+				t.Errorf("got valid = %t, want %t (err = %v)", valid, c.valid, err)
+			}
+		})
+	}
+}
+
+func TestNewWithAlert(t *testing.T) {
+	opts, err := OptsFromConfig(&configpb.MCP9808Config{
+		Address: 0x19,
+		Alert: &configpb.MCP9808Alert{
+			Lower:      10,
+			Upper:      30,
+			Critical:   40,
+			Hysteresis: configpb.MCP9808Alert_HYSTERESIS_3,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	bus := &i2ctest.Playback{
+		Ops: []i2ctest.IO{
+			// Resolution and enable, done by periph's driver.
+			{Addr: 0x19, W: []byte{0x08, 0x03}},
+			{Addr: 0x19, W: []byte{0x01, 0x00, 0x00}},
+			// Alert limits.
+			{Addr: 0x19, W: []byte{0x03, 0x00, 0xa0}},
+			{Addr: 0x19, W: []byte{0x02, 0x01, 0xe0}},
+			{Addr: 0x19, W: []byte{0x04, 0x02, 0x80}},
+			// Config.
+			{Addr: 0x19, W: []byte{0x01, 0x04, 0x08}},
+			// Temperature reads, the second with the upper alert bit set.
+			{Addr: 0x19, W: []byte{0x05}, R: []byte{0x01, 0xe0}},
+			{Addr: 0x19, W: []byte{0x05}, R: []byte{0x41, 0xf0}},
+		},
+	}
+
+	s, err := New(bus, opts)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	temps, status, err := s.readTempMulti(2, 0)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got, want := mean(temps), float32(30.5); got != want {
+		t.Errorf("got mean %v, want %v", got, want)
+	}
+	if status != tempUpper {
+		t.Errorf("got status %#04x, want %#04x", status, tempUpper)
+	}
+
+	if err := bus.Close(); err != nil {
+		t.Errorf("Not all I²C ops were performed: %v", err)
+	}
+}
+
+func TestClearInterrupt(t *testing.T) {
+	opts, err := OptsFromConfig(&configpb.MCP9808Config{
+		Alert: &configpb.MCP9808Alert{
+			Lower:         10,
+			Upper:         30,
+			Critical:      40,
+			InterruptMode: true,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	bus := &i2ctest.Playback{
+		Ops: []i2ctest.IO{
+			{Addr: 0x18, W: []byte{0x08, 0x03}},
+			{Addr: 0x18, W: []byte{0x01, 0x00, 0x00}},
+			{Addr: 0x18, W: []byte{0x03, 0x00, 0xa0}},
+			{Addr: 0x18, W: []byte{0x02, 0x01, 0xe0}},
+			{Addr: 0x18, W: []byte{0x04, 0x02, 0x80}},
+			{Addr: 0x18, W: []byte{0x01, 0x00, 0x09}},
+			// The interrupt is cleared by setting the clear bit.
+			{Addr: 0x18, W: []byte{0x01, 0x00, 0x29}},
+		},
+	}
+
+	s, err := New(bus, opts)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := s.clearInterrupt(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if err := bus.Close(); err != nil {
+		t.Errorf("Not all I²C ops were performed: %v", err)
+	}
+}
@@ -1,9 +1,17 @@
 package sds011
 
 import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sync"
 	"time"
 
+	"github.com/mtraver/environmental-sensor/configpb"
 	mpb "github.com/mtraver/environmental-sensor/measurementpb"
+	"github.com/mtraver/environmental-sensor/sensor"
 	"github.com/mtraver/sds011"
 	wpb "google.golang.org/protobuf/types/known/wrapperspb"
 )
@@ -11,34 +19,191 @@ import (
 const (
 	numSamples     = 3
 	sampleInterval = 1
+
+	// DefaultPort is the serial port used if none is configured.
+	DefaultPort = "/dev/ttyUSB0"
+
+	// DefaultWarmup is how long the fan should run before the sensor is read, per the datasheet.
+	DefaultWarmup = 30 * time.Second
+
+	// DefaultRatedHours is the rated life of the sensor's laser diode.
+	DefaultRatedHours = 8000
+
+	// In working period mode the sensor runs for this long at the start of each period.
+	periodOnTime = 30 * time.Second
+
+	// A warning is logged when this fraction of the rated life has been used.
+	lifeWarningFraction = 0.9
 )
 
+// device is the subset of the sds011.Dev API used by SDS011. It exists so that tests can
+// substitute a fake.
+type device interface {
+	Wake() error
+	Sleep() error
+	SetMode(m sds011.Mode) error
+	SetPeriod(minutes int) error
+	Sense() (sds011.Measurement, error)
+	GetFirmwareVersion() ([]byte, error)
+}
+
+// Opts configures an SDS011. The zero value reads the sensor immediately after waking it
+// and doesn't persist fan-hours.
+type Opts struct {
+	// Minimum time between waking the sensor and reading it.
+	Warmup time.Duration
+
+	// If non-zero the sensor's working period mode is used. In minutes.
+	WorkingPeriod int
+
+	// Rated life of the sensor in fan-hours. If zero no end-of-life warning is logged.
+	RatedHours float64
+
+	// Path to a file in which to persist fan-hours across restarts. If empty they're
+	// tracked only in memory.
+	StatePath string
+}
+
+// OptsFromConfig converts the config proto to a port name and Opts.
+func OptsFromConfig(c *configpb.SDS011Config) (string, Opts, error) {
+	port := c.GetPort()
+	if port == "" {
+		port = DefaultPort
+	}
+
+	opts := Opts{
+		Warmup:        DefaultWarmup,
+		WorkingPeriod: int(c.GetWorkingPeriodMinutes()),
+		RatedHours:    DefaultRatedHours,
+	}
+
+	if c.GetWarmup() != nil {
+		if err := c.GetWarmup().CheckValid(); err != nil {
+			return "", Opts{}, fmt.Errorf("sds011: bad warmup: %v", err)
+		}
+		opts.Warmup = c.GetWarmup().AsDuration()
+		if opts.Warmup < 0 {
+			return "", Opts{}, fmt.Errorf("sds011: warmup must not be negative")
+		}
+	}
+
+	if opts.WorkingPeriod > 30 {
+		return "", Opts{}, fmt.Errorf("sds011: working period must be in [0, 30]")
+	}
+
+	if c.GetRatedHours() != 0 {
+		opts.RatedHours = float64(c.GetRatedHours())
+	}
+
+	return port, opts, nil
+}
+
 type SDS011 struct {
-	dev sds011.Dev
+	dev  device
+	port string
+	opts Opts
+
+	// These are replaced in tests.
+	now   func() time.Time
+	sleep func(time.Duration)
+
+	mu sync.Mutex
+	// The time at which the sensor was last woken. Zero if it's asleep.
+	wokeAt time.Time
+	// The time up to which fan-hours have been accounted for.
+	accountedTo time.Time
+	state       state
+	firmware    string
+	warnedLife  bool
+}
+
+// state is what's persisted to Opts.StatePath.
+type state struct {
+	FanHours float64 `json:"fan_hours"`
 }
 
-func New(name string) (*SDS011, error) {
+func New(name string, opts Opts) (*SDS011, error) {
 	d, err := sds011.New(name)
 	if err != nil {
 		return nil, err
 	}
 
-	return &SDS011{
-		dev: d,
-	}, nil
+	s, err := newSDS011(&d, opts)
+	if err != nil {
+		return nil, err
+	}
+	s.port = name
+	return s, nil
+}
+
+func newSDS011(d device, opts Opts) (*SDS011, error) {
+	s := &SDS011{
+		dev:   d,
+		opts:  opts,
+		now:   time.Now,
+		sleep: time.Sleep,
+	}
+
+	if opts.StatePath != "" {
+		b, err := ioutil.ReadFile(opts.StatePath)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		} else if err == nil {
+			if err := json.Unmarshal(b, &s.state); err != nil {
+				return nil, fmt.Errorf("sds011: failed to parse state file %s: %v", opts.StatePath, err)
+			}
+		}
+	}
+
+	return s, nil
+}
+
+func (s *SDS011) Capabilities() sensor.Capabilities {
+	return sensor.Capabilities{
+		Metrics: []string{"pm25", "pm10"},
+		Model:   "SDS011",
+		Bus:     "serial:" + s.port,
+	}
 }
 
 func (s *SDS011) Init() error {
 	if err := s.dev.Wake(); err != nil {
-		return nil
+		return fmt.Errorf("sds011: failed to wake: %v", err)
+	}
+
+	s.mu.Lock()
+	now := s.now()
+	if s.wokeAt.IsZero() {
+		s.wokeAt = now
+		s.accountedTo = now
 	}
+	s.mu.Unlock()
+
 	if err := s.dev.SetMode(sds011.ModeQuery); err != nil {
-		return nil
+		return fmt.Errorf("sds011: failed to set query mode: %v", err)
+	}
+
+	if err := s.dev.SetPeriod(s.opts.WorkingPeriod); err != nil {
+		return fmt.Errorf("sds011: failed to set working period: %v", err)
+	}
+
+	v, err := s.dev.GetFirmwareVersion()
+	if err != nil {
+		return fmt.Errorf("sds011: failed to get firmware version: %v", err)
 	}
+	// The sensor ID isn't reported. It's in the firmware version reply, but
+	// github.com/mtraver/sds011 only returns the version and doesn't expose the port.
+	s.mu.Lock()
+	s.firmware = formatFirmwareVersion(v)
+	s.mu.Unlock()
+	log.Printf("SDS011 firmware version %s, %.1f fan-hours used", s.FirmwareVersion(), s.FanHours())
+
 	return nil
 }
 
 func (s *SDS011) Sense(m *mpb.Measurement) error {
+	s.waitForWarmup()
+
 	values := make([]sds011.Measurement, numSamples)
 	for i := 0; i < numSamples; i++ {
 		v, err := s.dev.Sense()
@@ -48,18 +213,117 @@ func (s *SDS011) Sense(m *mpb.Measurement) error {
 
 		values[i] = v
 		if i < numSamples-1 {
-			time.Sleep(sampleInterval * time.Second)
+			s.sleep(sampleInterval * time.Second)
 		}
 	}
 
 	avg := mean(values)
 	m.Pm25 = wpb.Float(avg.PM25)
 	m.Pm10 = wpb.Float(avg.PM10)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.logAccountFanTime()
 	return nil
 }
 
 func (s *SDS011) Shutdown() error {
-	return s.dev.Sleep()
+	if err := s.dev.Sleep(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.logAccountFanTime()
+	s.wokeAt = time.Time{}
+	return nil
+}
+
+// FirmwareVersion returns the sensor's firmware version, which is its release date.
+// It's empty until Init has succeeded.
+func (s *SDS011) FirmwareVersion() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.firmware
+}
+
+// FanHours returns the total number of hours that the sensor's fan has run.
+func (s *SDS011) FanHours() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state.FanHours
+}
+
+// waitForWarmup sleeps until the sensor has been awake for the warm-up duration. In working
+// period mode the sensor manages its own fan so there's nothing to wait for.
+func (s *SDS011) waitForWarmup() {
+	if s.opts.WorkingPeriod != 0 {
+		return
+	}
+
+	s.mu.Lock()
+	wokeAt := s.wokeAt
+	s.mu.Unlock()
+
+	if wokeAt.IsZero() {
+		// Init wasn't called, so we don't know how long the sensor has been awake.
+		return
+	}
+
+	if remaining := s.opts.Warmup - s.now().Sub(wokeAt); remaining > 0 {
+		log.Printf("Waiting %v for SDS011 to warm up", remaining)
+		s.sleep(remaining)
+	}
+}
+
+// accountFanTime adds the time the fan has run since the last call to the total and
+// persists it. s.mu must be held.
+func (s *SDS011) accountFanTime() error {
+	if s.wokeAt.IsZero() {
+		return nil
+	}
+
+	now := s.now()
+	elapsed := now.Sub(s.accountedTo)
+	s.accountedTo = now
+
+	if s.opts.WorkingPeriod != 0 {
+		// The fan only runs at the start of each period.
+		elapsed = time.Duration(float64(elapsed) * float64(periodOnTime) / float64(time.Duration(s.opts.WorkingPeriod)*time.Minute))
+	}
+	s.state.FanHours += elapsed.Hours()
+
+	if s.opts.RatedHours > 0 && !s.warnedLife && s.state.FanHours >= lifeWarningFraction*s.opts.RatedHours {
+		log.Printf("SDS011 has used %.0f of its rated %.0f fan-hours and is nearing end of life", s.state.FanHours, s.opts.RatedHours)
+		s.warnedLife = true
+	}
+
+	if s.opts.StatePath == "" {
+		return nil
+	}
+
+	b, err := json.Marshal(s.state)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(s.opts.StatePath, b, 0600)
+}
+
+// logAccountFanTime accounts for fan time, logging rather than returning an error. Failing
+// to persist fan-hours, e.g. because the disk is full, shouldn't lose a reading or fail a
+// shutdown. s.mu must be held.
+func (s *SDS011) logAccountFanTime() {
+	if err := s.accountFanTime(); err != nil {
+		log.Printf("sds011: failed to account for fan time: %v", err)
+	}
+}
+
+// formatFirmwareVersion formats the year, month, and day bytes returned by the sensor.
+func formatFirmwareVersion(v []byte) string {
+	if len(v) != 3 {
+		return fmt.Sprintf("%x", v)
+	}
+	return fmt.Sprintf("20%02d-%02d-%02d", v[0], v[1], v[2])
 }
 
 func mean(m []sds011.Measurement) sds011.Measurement {
@@ -1,10 +1,14 @@
 package sds011
 
 import (
+	"errors"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
+	mpb "github.com/mtraver/environmental-sensor/measurementpb"
 	"github.com/mtraver/sds011"
 )
 
@@ -66,3 +70,218 @@ func TestMean(t *testing.T) {
 		})
 	}
 }
+
+type fakeDev struct {
+	wakeErr    error
+	setModeErr error
+	awake      bool
+	period     int
+	sensed     int
+}
+
+func (d *fakeDev) Wake() error {
+	if d.wakeErr != nil {
+		return d.wakeErr
+	}
+	d.awake = true
+	return nil
+}
+
+func (d *fakeDev) Sleep() error {
+	d.awake = false
+	return nil
+}
+
+func (d *fakeDev) SetMode(m sds011.Mode) error {
+	return d.setModeErr
+}
+
+func (d *fakeDev) SetPeriod(minutes int) error {
+	d.period = minutes
+	return nil
+}
+
+func (d *fakeDev) Sense() (sds011.Measurement, error) {
+	d.sensed++
+	return sds011.Measurement{PM25: 10, PM10: 20}, nil
+}
+
+func (d *fakeDev) GetFirmwareVersion() ([]byte, error) {
+	return []byte{18, 11, 16}, nil
+}
+
+// fakeClock is a clock that only advances when sleep is called.
+type fakeClock struct {
+	t     time.Time
+	slept []time.Duration
+}
+
+func (c *fakeClock) now() time.Time {
+	return c.t
+}
+
+func (c *fakeClock) sleep(d time.Duration) {
+	c.slept = append(c.slept, d)
+	c.t = c.t.Add(d)
+}
+
+func newTestSDS011(t *testing.T, d device, opts Opts) (*SDS011, *fakeClock) {
+	t.Helper()
+
+	s, err := newSDS011(d, opts)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	clock := &fakeClock{t: time.Date(2020, time.August, 30, 0, 0, 0, 0, time.UTC)}
+	s.now = clock.now
+	s.sleep = clock.sleep
+	return s, clock
+}
+
+func TestInitErrors(t *testing.T) {
+	cases := []struct {
+		name string
+		dev  *fakeDev
+	}{
+		{"wake", &fakeDev{wakeErr: errors.New("wake failed")}},
+		{"set_mode", &fakeDev{setModeErr: errors.New("set mode failed")}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s, _ := newTestSDS011(t, c.dev, Opts{})
+			if err := s.Init(); err == nil {
+				t.Errorf("Expected error, got no error")
+			}
+		})
+	}
+}
+
+func TestInitFirmwareVersion(t *testing.T) {
+	s, _ := newTestSDS011(t, &fakeDev{}, Opts{})
+	if err := s.Init(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if got, want := s.FirmwareVersion(), "2018-11-16"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestSenseWaitsForWarmup(t *testing.T) {
+	s, clock := newTestSDS011(t, &fakeDev{}, Opts{Warmup: 30 * time.Second})
+	if err := s.Init(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	clock.t = clock.t.Add(10 * time.Second)
+
+	var m mpb.Measurement
+	if err := s.Sense(&m); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := []time.Duration{20 * time.Second, time.Second, time.Second}
+	if diff := cmp.Diff(clock.slept, want); diff != "" {
+		t.Errorf("Unexpected sleeps (-got +want):\n%s", diff)
+	}
+
+	// The sensor is warm now, so the next Sense shouldn't wait.
+	clock.slept = nil
+	if err := s.Sense(&m); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(clock.slept, want[1:]); diff != "" {
+		t.Errorf("Unexpected sleeps (-got +want):\n%s", diff)
+	}
+}
+
+func TestSenseWorkingPeriodNoWarmup(t *testing.T) {
+	dev := &fakeDev{}
+	s, clock := newTestSDS011(t, dev, Opts{Warmup: 30 * time.Second, WorkingPeriod: 5})
+	if err := s.Init(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if dev.period != 5 {
+		t.Errorf("got working period %d, want 5", dev.period)
+	}
+
+	var m mpb.Measurement
+	if err := s.Sense(&m); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(clock.slept) != numSamples-1 {
+		t.Errorf("got %d sleeps, want %d", len(clock.slept), numSamples-1)
+	}
+}
+
+func TestFanHours(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "sds011.json")
+
+	s, clock := newTestSDS011(t, &fakeDev{}, Opts{StatePath: statePath})
+	if err := s.Init(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	clock.t = clock.t.Add(90 * time.Minute)
+	if err := s.Shutdown(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Time spent asleep doesn't count.
+	clock.t = clock.t.Add(10 * time.Hour)
+	if err := s.Shutdown(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if got, want := s.FanHours(), 1.5; !cmp.Equal(got, want, cmpFloats) {
+		t.Errorf("got %v fan-hours, want %v", got, want)
+	}
+
+	// Fan-hours are persisted across restarts.
+	s2, clock2 := newTestSDS011(t, &fakeDev{}, Opts{StatePath: statePath, WorkingPeriod: 1})
+	if got, want := s2.FanHours(), 1.5; !cmp.Equal(got, want, cmpFloats) {
+		t.Errorf("got %v fan-hours after restart, want %v", got, want)
+	}
+
+	// In working period mode the fan runs for 30 seconds each period.
+	if err := s2.Init(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	clock2.t = clock2.t.Add(2 * time.Hour)
+	if err := s2.Shutdown(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got, want := s2.FanHours(), 2.5; !cmp.Equal(got, want, cmpFloats) {
+		t.Errorf("got %v fan-hours, want %v", got, want)
+	}
+}
+
+func TestFanHoursUnwritable(t *testing.T) {
+	// The state file is in a directory that doesn't exist, so it can't be written.
+	statePath := filepath.Join(t.TempDir(), "missing", "sds011.json")
+
+	s, clock := newTestSDS011(t, &fakeDev{}, Opts{StatePath: statePath})
+	if err := s.Init(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	clock.t = clock.t.Add(time.Hour)
+
+	// The reading is still returned.
+	var m mpb.Measurement
+	if err := s.Sense(&m); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if m.GetPm25() == nil || m.GetPm10() == nil {
+		t.Errorf("got measurement %v, want PM2.5 and PM10", &m)
+	}
+
+	if err := s.Shutdown(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Fan-hours are still counted in memory, including the two seconds between samples.
+	if got, want := s.FanHours(), 1+2.0/3600; !cmp.Equal(got, want, cmpFloats) {
+		t.Errorf("got %v fan-hours, want %v", got, want)
+	}
+}
@@ -2,6 +2,7 @@ package sensor
 
 import (
 	"fmt"
+	"sort"
 	"sync"
 
 	mpb "github.com/mtraver/environmental-sensor/measurementpb"
@@ -23,6 +24,21 @@ type Sensor interface {
 	Shutdown() error
 }
 
+// Capabilities describes what a sensor is and what it measures.
+type Capabilities struct {
+	// The names of the fields in the Measurement proto that the sensor sets, e.g. "temp".
+	Metrics []string `json:"metrics"`
+	// The sensor's make or model, e.g. "MCP9808".
+	Model string `json:"model"`
+	// How the sensor is connected, e.g. "i2c:0x18" or "serial:/dev/ttyUSB0".
+	Bus string `json:"bus"`
+}
+
+// Describer is an optional interface implemented by sensors that can describe their capabilities.
+type Describer interface {
+	Capabilities() Capabilities
+}
+
 // Register adds a Sensor to the set of available sensors.
 func Register(name string, s Sensor) {
 	sensorsMu.Lock()
@@ -49,3 +65,32 @@ func Get(name string) (Sensor, error) {
 	}
 	return sensors[name], nil
 }
+
+// Names returns the names of all registered sensors in sorted order.
+func Names() []string {
+	sensorsMu.Lock()
+	defer sensorsMu.Unlock()
+
+	names := make([]string, 0, len(sensors))
+	for name := range sensors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Describe looks up a sensor by name and returns its capabilities. The returned bool
+// is false if the sensor doesn't implement Describer. It returns an error if no sensor
+// with the given name is found.
+func Describe(name string) (Capabilities, bool, error) {
+	s, err := Get(name)
+	if err != nil {
+		return Capabilities{}, false, err
+	}
+
+	d, ok := s.(Describer)
+	if !ok {
+		return Capabilities{}, false, nil
+	}
+	return d.Capabilities(), true, nil
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	mpb "github.com/mtraver/environmental-sensor/measurementpb"
+	"github.com/mtraver/environmental-sensor/web/cache"
+)
+
+func TestCachezHandler(t *testing.T) {
+	ctx := context.Background()
+	c := cache.NewLocal()
+	c.Add(ctx, "foo", &mpb.Measurement{DeviceId: "foo"})
+	c.Get(ctx, "foo", &mpb.Measurement{})
+	c.Get(ctx, "bar", &mpb.Measurement{})
+
+	h := cachezHandler{
+		Cache:    &c,
+		Template: testTemplates,
+	}
+
+	status, body := get(t, h, "/cachez")
+	if status != http.StatusOK {
+		t.Errorf("got status %d, want %d", status, http.StatusOK)
+	}
+	if want := "<p>1 / 2</p>"; !strings.Contains(body, want) {
+		t.Errorf("Response doesn't contain %q", want)
+	}
+}
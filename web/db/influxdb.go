@@ -2,65 +2,260 @@ package db
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
 
 	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	influxhttp "github.com/influxdata/influxdb-client-go/v2/api/http"
 	"github.com/influxdata/influxdb-client-go/v2/api/write"
 	"github.com/mtraver/environmental-sensor/measurement"
 	mpb "github.com/mtraver/environmental-sensor/measurementpb"
 )
 
-func newInfluxDBPoints(m *mpb.Measurement) ([]*write.Point, error) {
+// The fields that hold a measurement's metadata rather than its metrics. They're added to each
+// of the measurement's points, so that any of its points can be read back on its own.
+const (
+	// The upload timestamp, in nanoseconds since the epoch. Only delayed measurements have it.
+	influxDBUploadField = "upload_ts"
+
+	// The sequence number and boot ID. Only measurements with a boot ID have them.
+	influxDBSequenceField = "seq"
+	influxDBBootIDField   = "boot_id"
+)
+
+// InfluxDBOptions configures how measurements are written to and read from InfluxDB. Empty
+// names are given their defaults.
+type InfluxDBOptions struct {
+	// The name of the InfluxDB measurement that metrics are written to and read from.
+	// Defaults to "stat".
+	Measurement string
+
+	// The keys of the tags holding the device ID, and the sensor and its location for
+	// per-sensor readings. Default to "device", "sensor", and "location".
+	DeviceTag   string
+	SensorTag   string
+	LocationTag string
+
+	// Tags added to every point, e.g. to tell apart deployments writing to the same bucket.
+	Tags map[string]string
+
+	// Queries for time ranges longer than DownsampleAfter return at most MaxPoints
+	// measurements per device, each summarizing a window of the range. Zero means never.
+	DownsampleAfter time.Duration
+	MaxPoints       int
+}
+
+func (o InfluxDBOptions) withDefaults() InfluxDBOptions {
+	if o.Measurement == "" {
+		o.Measurement = "stat"
+	}
+	if o.DeviceTag == "" {
+		o.DeviceTag = "device"
+	}
+	if o.SensorTag == "" {
+		o.SensorTag = "sensor"
+	}
+	if o.LocationTag == "" {
+		o.LocationTag = "location"
+	}
+	return o
+}
+
+// ParseInfluxDBTags parses tags given as comma-separated key=value pairs, e.g. "site=home,env=prod".
+func ParseInfluxDBTags(s string) (map[string]string, error) {
+	tags := make(map[string]string)
+	for _, kv := range strings.Split(s, ",") {
+		kv = strings.TrimSpace(kv)
+		if kv == "" {
+			continue
+		}
+
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("db: bad InfluxDB tag %q, want key=value", kv)
+		}
+		tags[parts[0]] = parts[1]
+	}
+	return tags, nil
+}
+
+func newInfluxDBPoints(m *mpb.Measurement, opts InfluxDBOptions) ([]*write.Point, error) {
+	opts = opts.withDefaults()
+
 	sm, err := measurement.NewStorableMeasurement(m)
 	if err != nil {
 		return nil, err
 	}
 
-	vm := sm.ValueMap()
-	points := make([]*write.Point, 0, len(vm))
-	for name, v := range vm {
-		p := influxdb2.NewPointWithMeasurement("stat")
+	// Metrics with per-sensor readings get one point per reading, tagged with the sensor
+	// (and its location, if it has one) so that the sensors' values are kept apart.
+	var points []*write.Point
+	hasReadings := make(map[string]bool)
+	for _, r := range sm.Readings {
+		p := newInfluxDBPoint(opts, opts.Measurement, sm.DeviceID).AddTag(opts.SensorTag, r.Sensor)
+		if r.Location != "" {
+			p = p.AddTag(opts.LocationTag, r.Location)
+		}
+
+		points = append(points, p.AddField(r.Metric, r.Value).SetTime(sm.Timestamp))
+		hasReadings[r.Metric] = true
+	}
+
+	for name, v := range sm.ValueMap() {
+		key := name
 		if metric, ok := measurement.GetMetric(name); ok {
-			p = p.AddField(metric.Abbrv, v)
-		} else {
-			p = p.AddField(name, v)
+			key = metric.Abbrv
+		}
+		if hasReadings[key] {
+			continue
 		}
 
-		points = append(points, p.AddTag("device", sm.DeviceID).SetTime(sm.Timestamp))
+		points = append(points, newInfluxDBPoint(opts, opts.Measurement, sm.DeviceID).AddField(key, v).SetTime(sm.Timestamp))
+	}
+
+	for _, p := range points {
+		if !sm.UploadTimestamp.IsZero() {
+			p.AddField(influxDBUploadField, sm.UploadTimestamp.UnixNano())
+		}
+		if sm.BootID != "" {
+			p.AddField(influxDBSequenceField, sm.Sequence)
+			p.AddField(influxDBBootIDField, sm.BootID)
+		}
 	}
 
 	return points, nil
 }
 
+// newInfluxDBPoint returns a point in the given InfluxDB measurement with the tags that every
+// point from the device has.
+func newInfluxDBPoint(opts InfluxDBOptions, name string, deviceID string) *write.Point {
+	p := influxdb2.NewPointWithMeasurement(name)
+
+	keys := make([]string, 0, len(opts.Tags))
+	for k := range opts.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		p = p.AddTag(k, opts.Tags[k])
+	}
+
+	return p.AddTag(opts.DeviceTag, deviceID)
+}
+
+// InfluxDB writes measurements to and reads them from an InfluxDB 2 bucket. It keeps one
+// client, and so one pool of connections, for its lifetime.
 type InfluxDB struct {
-	serverURL string
-	token     string
-	org       string
-	bucket    string
+	client   influxdb2.Client
+	org      string
+	bucket   string
+	writeURL string
+	opts     InfluxDBOptions
 }
 
-func NewInfluxDB(serverURL, token, org, bucket string) *InfluxDB {
-	return &InfluxDB{
-		serverURL: serverURL,
-		token:     token,
-		org:       org,
-		bucket:    bucket,
+func NewInfluxDB(serverURL, token, org, bucket string, opts InfluxDBOptions) (*InfluxDB, error) {
+	client := influxdb2.NewClientWithOptions(serverURL, token, influxdb2.DefaultOptions().SetHTTPRequestTimeout(10))
+
+	u, err := url.Parse(client.HTTPService().ServerAPIURL())
+	if err != nil {
+		return nil, err
 	}
+	u, err = u.Parse("write")
+	if err != nil {
+		return nil, err
+	}
+	params := url.Values{}
+	params.Set("org", org)
+	params.Set("bucket", bucket)
+	params.Set("precision", "ns")
+	u.RawQuery = params.Encode()
+
+	return &InfluxDB{
+		client:   client,
+		org:      org,
+		bucket:   bucket,
+		writeURL: u.String(),
+		opts:     opts.withDefaults(),
+	}, nil
 }
 
+// Save writes the measurement's points. It makes one try: a failed write is re-tried by the
+// caller, e.g. an ingest.Output, if the returned error says that re-trying might help.
+//
+// The client's blocking write API isn't used because after a failed write it queues the
+// points and may report later writes as successful without having made them.
 func (db *InfluxDB) Save(ctx context.Context, m *mpb.Measurement) error {
-	points, err := newInfluxDBPoints(m)
+	points, err := newInfluxDBPoints(m, db.opts)
 	if err != nil {
 		return err
 	}
 
-	client := influxdb2.NewClient(db.serverURL, db.token)
-	defer client.Close()
-
-	writeAPI := client.WriteAPI(db.org, db.bucket)
+	var lines strings.Builder
 	for _, p := range points {
-		writeAPI.WritePoint(p)
+		write.PointToLineProtocolBuffer(p, &lines, time.Nanosecond)
 	}
-	writeAPI.Flush()
 
+	if err := db.write(ctx, lines.String()); err != nil {
+		werr := &influxDBWriteError{err: err}
+		errors.As(err, &werr.http)
+		return werr
+	}
 	return nil
 }
+
+// influxDBWriteError is the error returned by a failed write. It says whether re-trying might
+// help, i.e. whether the error is a network error, the server is rate limiting, or it's having
+// trouble, and how long the server asked for the write to be put off.
+type influxDBWriteError struct {
+	err  error
+	http *influxhttp.Error
+}
+
+func (e *influxDBWriteError) Error() string {
+	return fmt.Sprintf("db: failed to write to InfluxDB: %v", e.err)
+}
+
+func (e *influxDBWriteError) Unwrap() error {
+	return e.err
+}
+
+func (e *influxDBWriteError) Temporary() bool {
+	if e.http == nil {
+		return false
+	}
+	code := e.http.StatusCode
+	return code == 0 || code == http.StatusTooManyRequests || code >= 500
+}
+
+func (e *influxDBWriteError) RetryAfter() time.Duration {
+	if e.http == nil {
+		return 0
+	}
+	return time.Duration(e.http.RetryAfter) * time.Second
+}
+
+func (db *InfluxDB) write(ctx context.Context, body string) error {
+	if perr := db.client.HTTPService().DoPostRequest(ctx, db.writeURL, strings.NewReader(body), func(req *http.Request) {
+		req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	}, func(resp *http.Response) error {
+		// Drain the body so that the connection can be reused.
+		_, err := io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+		return err
+	}); perr != nil {
+		return perr
+	}
+	return nil
+}
+
+// Close closes the client's idle connections.
+func (db *InfluxDB) Close() {
+	db.client.Close()
+}
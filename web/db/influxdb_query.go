@@ -0,0 +1,430 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/influxdata/influxdb-client-go/v2/api/query"
+	"github.com/mtraver/environmental-sensor/measurement"
+)
+
+// fluxString returns s as a Flux string literal.
+func fluxString(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "${", `\${`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+	return `"` + r.Replace(s) + `"`
+}
+
+// fluxTime returns t as a Flux time literal.
+func fluxTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// fluxSource returns the start of a query for the points of the given InfluxDB measurement
+// in the time range, which is given as arguments to Flux's range function, that have the
+// tags that every point has.
+func (db *InfluxDB) fluxSource(name string, timeRange string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "from(bucket: %s)\n", fluxString(db.bucket))
+	fmt.Fprintf(&b, "  |> range(%s)\n", timeRange)
+	fmt.Fprintf(&b, "  |> filter(fn: (r) => r._measurement == %s)\n", fluxString(name))
+
+	keys := make([]string, 0, len(db.opts.Tags))
+	for k := range db.opts.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&b, "  |> filter(fn: (r) => r[%s] == %s)\n", fluxString(k), fluxString(db.opts.Tags[k]))
+	}
+
+	return b.String()
+}
+
+// fluxPivot is the step of a query that turns the points' fields into columns, so that each
+// row of the result holds the fields of one point.
+const fluxPivot = "  |> pivot(rowKey: [\"_time\"], columnKey: [\"_field\"], valueColumn: \"_value\")\n"
+
+// influxDBRowKey identifies the measurement that a row belongs to. All of a measurement's points
+// have its device and timestamp.
+type influxDBRowKey struct {
+	device string
+	t      time.Time
+}
+
+// influxDBRows assembles measurements from the rows of a pivoted query result (see fluxPivot).
+// Rows with a sensor tag hold the readings of that sensor and the others hold the measurement's
+// values.
+type influxDBRows struct {
+	opts InfluxDBOptions
+	sms  map[influxDBRowKey]*measurement.StorableMeasurement
+
+	// The metrics of each measurement whose values were read, rather than taken from readings.
+	set map[influxDBRowKey]map[string]bool
+}
+
+func newInfluxDBRows(opts InfluxDBOptions) *influxDBRows {
+	return &influxDBRows{
+		opts: opts,
+		sms:  make(map[influxDBRowKey]*measurement.StorableMeasurement),
+		set:  make(map[influxDBRowKey]map[string]bool),
+	}
+}
+
+// isTag reports whether the column of a pivoted result holds something other than a field.
+func (rows *influxDBRows) isTag(column string) bool {
+	if strings.HasPrefix(column, "_") || column == "result" || column == "table" {
+		return true
+	}
+	if column == rows.opts.DeviceTag || column == rows.opts.SensorTag || column == rows.opts.LocationTag {
+		return true
+	}
+	_, ok := rows.opts.Tags[column]
+	return ok
+}
+
+func (rows *influxDBRows) add(r *query.FluxRecord) error {
+	device, _ := r.ValueByKey(rows.opts.DeviceTag).(string)
+	sensor, _ := r.ValueByKey(rows.opts.SensorTag).(string)
+	location, _ := r.ValueByKey(rows.opts.LocationTag).(string)
+
+	k := influxDBRowKey{device, r.Time().UTC()}
+	sm, ok := rows.sms[k]
+	if !ok {
+		sm = &measurement.StorableMeasurement{DeviceID: device, Timestamp: k.t}
+		rows.sms[k] = sm
+		rows.set[k] = make(map[string]bool)
+	}
+
+	for column, v := range r.Values() {
+		if v == nil || rows.isTag(column) {
+			continue
+		}
+
+		switch column {
+		case influxDBUploadField:
+			if ns, ok := v.(int64); ok {
+				sm.UploadTimestamp = time.Unix(0, ns).UTC()
+			}
+			continue
+		case influxDBSequenceField:
+			if n, ok := v.(int64); ok {
+				sm.Sequence = n
+			}
+			continue
+		case influxDBBootIDField:
+			if id, ok := v.(string); ok {
+				sm.BootID = id
+			}
+			continue
+		}
+
+		var f float32
+		switch x := v.(type) {
+		case float64:
+			f = float32(x)
+		case int64:
+			f = float32(x)
+		default:
+			// Columns that aren't numbers are tags that the app doesn't know about.
+			continue
+		}
+
+		if sensor != "" {
+			sm.Readings = append(sm.Readings, measurement.Reading{
+				Metric:   column,
+				Sensor:   sensor,
+				Location: location,
+				Value:    f,
+			})
+		} else if sm.SetValue(column, f) {
+			rows.set[k][column] = true
+		}
+	}
+	return nil
+}
+
+// measurements returns the assembled measurements of each device, sorted by timestamp. Metrics
+// with readings aren't written as values, so each such metric's value is taken from its first
+// reading in order of sensor and location. The order in which the device read its sensors isn't
+// kept, so it may not be the value the device reported.
+func (rows *influxDBRows) measurements() map[string][]measurement.StorableMeasurement {
+	results := make(map[string][]measurement.StorableMeasurement)
+	for k, sm := range rows.sms {
+		sort.Slice(sm.Readings, func(i, j int) bool {
+			a, b := sm.Readings[i], sm.Readings[j]
+			if a.Metric != b.Metric {
+				return a.Metric < b.Metric
+			}
+			if a.Sensor != b.Sensor {
+				return a.Sensor < b.Sensor
+			}
+			return a.Location < b.Location
+		})
+
+		set := rows.set[k]
+		for _, r := range sm.Readings {
+			if !set[r.Metric] {
+				set[r.Metric] = sm.SetValue(r.Metric, r.Value)
+			}
+		}
+
+		results[k.device] = append(results[k.device], *sm)
+	}
+
+	for _, ms := range results {
+		sortByTimestamp(ms)
+	}
+	return results
+}
+
+// queryMeasurements runs the query, which must be pivoted, and assembles the measurements.
+func (db *InfluxDB) queryMeasurements(ctx context.Context, flux string) (map[string][]measurement.StorableMeasurement, error) {
+	rows := newInfluxDBRows(db.opts)
+	if err := db.query(ctx, flux, rows.add); err != nil {
+		return make(map[string][]measurement.StorableMeasurement), err
+	}
+	return rows.measurements(), nil
+}
+
+// query runs the query, calling f with each record of the result.
+func (db *InfluxDB) query(ctx context.Context, flux string, f func(r *query.FluxRecord) error) error {
+	result, err := db.client.QueryAPI(db.org).Query(ctx, flux)
+	if err != nil {
+		return fmt.Errorf("db: InfluxDB query failed: %v", err)
+	}
+	defer result.Close()
+
+	for result.Next() {
+		if err := f(result.Record()); err != nil {
+			return err
+		}
+	}
+	if result.Err() != nil {
+		return fmt.Errorf("db: InfluxDB query failed: %v", result.Err())
+	}
+	return nil
+}
+
+func sortByTimestamp(ms []measurement.StorableMeasurement) {
+	sort.SliceStable(ms, func(i, j int) bool {
+		return ms[i].Timestamp.Before(ms[j].Timestamp)
+	})
+}
+
+// Since gets all measurements with a timestamp greater than or equal to startTime. It returns
+// a map of device ID (a string) to a StorableMeasurement slice, and an error.
+func (db *InfluxDB) Since(ctx context.Context, startTime time.Time) (map[string][]measurement.StorableMeasurement, error) {
+	return db.Between(ctx, startTime, time.Now().UTC())
+}
+
+// DelayedSince gets all measurements with a non-nil upload timestamp greater than or equal to startTime.
+// It returns a map of device ID (a string) to a StorableMeasurement slice, and an error.
+func (db *InfluxDB) DelayedSince(ctx context.Context, startTime time.Time) (map[string][]measurement.StorableMeasurement, error) {
+	// Points are indexed by their measurement's timestamp, which may be long before its upload
+	// timestamp, so first find the earliest delayed measurement uploaded since startTime. Only
+	// its upload timestamp field is read, which only delayed measurements have.
+	uploaded := fmt.Sprintf("  |> filter(fn: (r) => r._field == %s and r._value >= %d)\n", fluxString(influxDBUploadField), startTime.UnixNano())
+	flux := db.fluxSource(db.opts.Measurement, "start: 0") + uploaded +
+		"  |> group()\n" +
+		"  |> min(column: \"_time\")\n"
+
+	var earliest time.Time
+	err := db.query(ctx, flux, func(r *query.FluxRecord) error {
+		if earliest.IsZero() || r.Time().Before(earliest) {
+			earliest = r.Time()
+		}
+		return nil
+	})
+	if err != nil || earliest.IsZero() {
+		return make(map[string][]measurement.StorableMeasurement), err
+	}
+
+	flux = db.fluxSource(db.opts.Measurement, "start: "+fluxTime(earliest)) + fluxPivot +
+		fmt.Sprintf("  |> filter(fn: (r) => exists r[%s] and r[%s] >= %d)\n",
+			fluxString(influxDBUploadField), fluxString(influxDBUploadField), startTime.UnixNano())
+	return db.queryMeasurements(ctx, flux)
+}
+
+// Between gets all measurements with a timestamp greater than or equal to startTime and less than or equal
+// to endTime. It returns a map of device ID (a string) to a StorableMeasurement slice, and an error.
+//
+// If the range is longer than the DownsampleAfter option then the measurements are downsampled
+// by InfluxDB (see downsampled) rather than read back as they were saved.
+func (db *InfluxDB) Between(ctx context.Context, startTime time.Time, endTime time.Time) (map[string][]measurement.StorableMeasurement, error) {
+	if db.opts.DownsampleAfter > 0 && endTime.Sub(startTime) > db.opts.DownsampleAfter {
+		return db.downsampled(ctx, startTime, endTime)
+	}
+
+	// The end of Flux's range is exclusive.
+	timeRange := fmt.Sprintf("start: %s, stop: %s", fluxTime(startTime), fluxTime(endTime.Add(time.Nanosecond)))
+	return db.queryMeasurements(ctx, db.fluxSource(db.opts.Measurement, timeRange)+fluxPivot)
+}
+
+// Latest gets the most recent measurement for each of the given device IDs. It returns a map of
+// device ID to StorableMeasurement, and an error. If no measurement is found for a device ID then
+// the returned map will not contain that device ID.
+func (db *InfluxDB) Latest(ctx context.Context, deviceIDs []string) (map[string]measurement.StorableMeasurement, error) {
+	latest := make(map[string]measurement.StorableMeasurement)
+	if len(deviceIDs) == 0 {
+		return latest, nil
+	}
+
+	ids := make([]string, len(deviceIDs))
+	for i, id := range deviceIDs {
+		ids[i] = fluxString(id)
+	}
+
+	flux := db.fluxSource(db.opts.Measurement, "start: 0") +
+		fmt.Sprintf("  |> filter(fn: (r) => contains(value: r[%s], set: [%s]))\n", fluxString(db.opts.DeviceTag), strings.Join(ids, ", ")) +
+		"  |> last()\n" + fluxPivot
+
+	// last gives the latest point of each field of each series: of each sensor's readings and
+	// of the values. A device's latest measurement is made of those at its latest timestamp.
+	results, err := db.queryMeasurements(ctx, flux)
+	if err != nil {
+		return make(map[string]measurement.StorableMeasurement), err
+	}
+	for id, ms := range results {
+		latest[id] = ms[len(ms)-1]
+	}
+	return latest, nil
+}
+
+// downsampleWindow returns the length of the windows that the range is split into so that
+// there are about maxPoints of them. It's a whole number of minutes. InfluxDB aligns windows
+// to the epoch rather than to the start of the range, so there may be one more.
+func downsampleWindow(startTime, endTime time.Time, maxPoints int) time.Duration {
+	if maxPoints < 1 {
+		maxPoints = 1
+	}
+
+	w := endTime.Sub(startTime) / time.Duration(maxPoints)
+	if r := w % time.Minute; r != 0 || w == 0 {
+		w += time.Minute - r
+	}
+	return w
+}
+
+// downsampled gets the measurements in the range summarized over windows of it. The metrics are
+// aggregated by InfluxDB, so only the window summaries are transferred. Each returned measurement
+// is timestamped at the start of its window and holds the means of the metrics, with their
+// minimums, maximums, means, and counts in its summaries and the means of per-sensor metrics in
+// its readings, as a measurement aggregated by a device does.
+func (db *InfluxDB) downsampled(ctx context.Context, startTime time.Time, endTime time.Time) (map[string][]measurement.StorableMeasurement, error) {
+	window := downsampleWindow(startTime, endTime, db.opts.MaxPoints)
+	every := fmt.Sprintf("%ds", int64(window/time.Second))
+
+	timeRange := fmt.Sprintf("start: %s, stop: %s", fluxTime(startTime), fluxTime(endTime.Add(time.Nanosecond)))
+	var flux strings.Builder
+	flux.WriteString("data = " + db.fluxSource(db.opts.Measurement, timeRange))
+	fmt.Fprintf(&flux, "  |> filter(fn: (r) => r._field != %s and r._field != %s and r._field != %s)\n",
+		fluxString(influxDBUploadField), fluxString(influxDBSequenceField), fluxString(influxDBBootIDField))
+	flux.WriteString("\nunion(tables: [\n")
+	for _, fn := range []string{"mean", "min", "max", "count"} {
+		fmt.Fprintf(&flux, "  data |> aggregateWindow(every: %s, fn: %s, timeSrc: \"_start\", createEmpty: false) |> set(key: \"_agg\", value: %s),\n",
+			every, fn, fluxString(fn))
+	}
+	flux.WriteString("])\n")
+
+	type key struct {
+		device string
+		t      time.Time
+	}
+	type summaryKey struct {
+		metric, sensor, location string
+	}
+	summaries := make(map[key]map[summaryKey]*measurement.Summary)
+
+	err := db.query(ctx, flux.String(), func(r *query.FluxRecord) error {
+		device, _ := r.ValueByKey(db.opts.DeviceTag).(string)
+		sensor, _ := r.ValueByKey(db.opts.SensorTag).(string)
+		location, _ := r.ValueByKey(db.opts.LocationTag).(string)
+		agg, _ := r.ValueByKey("_agg").(string)
+
+		k := key{device, r.Time().UTC()}
+		if summaries[k] == nil {
+			summaries[k] = make(map[summaryKey]*measurement.Summary)
+		}
+		sk := summaryKey{r.Field(), sensor, location}
+		s, ok := summaries[k][sk]
+		if !ok {
+			s = &measurement.Summary{Metric: r.Field(), Sensor: sensor, Location: location}
+			summaries[k][sk] = s
+		}
+
+		var v float64
+		switch x := r.Value().(type) {
+		case float64:
+			v = x
+		case int64:
+			v = float64(x)
+		default:
+			return fmt.Errorf("db: unexpected InfluxDB value of type %T", r.Value())
+		}
+
+		switch agg {
+		case "mean":
+			s.Mean = float32(v)
+		case "min":
+			s.Min = float32(v)
+		case "max":
+			s.Max = float32(v)
+		case "count":
+			s.Count = int64(v)
+		}
+		return nil
+	})
+	if err != nil {
+		return make(map[string][]measurement.StorableMeasurement), err
+	}
+
+	results := make(map[string][]measurement.StorableMeasurement)
+	for k, byMetric := range summaries {
+		sm := measurement.StorableMeasurement{
+			DeviceID:  k.device,
+			Timestamp: k.t,
+			Window:    window,
+		}
+
+		for _, s := range byMetric {
+			sm.Summaries = append(sm.Summaries, *s)
+		}
+		// Summaries of the device as a whole, which have no sensor, come first, so that they
+		// give the metrics' values rather than one sensor of many.
+		sort.Slice(sm.Summaries, func(i, j int) bool {
+			a, b := sm.Summaries[i], sm.Summaries[j]
+			if a.Metric != b.Metric {
+				return a.Metric < b.Metric
+			}
+			if a.Sensor != b.Sensor {
+				return a.Sensor < b.Sensor
+			}
+			return a.Location < b.Location
+		})
+
+		set := make(map[string]bool)
+		for _, s := range sm.Summaries {
+			if s.Sensor != "" {
+				sm.Readings = append(sm.Readings, measurement.Reading{
+					Metric:   s.Metric,
+					Sensor:   s.Sensor,
+					Location: s.Location,
+					Value:    s.Mean,
+				})
+			}
+			if !set[s.Metric] {
+				set[s.Metric] = sm.SetValue(s.Metric, s.Mean)
+			}
+		}
+
+		results[k.device] = append(results[k.device], sm)
+	}
+
+	for _, ms := range results {
+		sortByTimestamp(ms)
+	}
+	return results, nil
+}
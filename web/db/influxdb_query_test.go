@@ -0,0 +1,519 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+	"github.com/mtraver/environmental-sensor/measurement"
+	mpb "github.com/mtraver/environmental-sensor/measurementpb"
+	durationpb "google.golang.org/protobuf/types/known/durationpb"
+	wpb "google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// fluxTable is a table of a Flux query result. The first column of the CSV, which holds
+// annotations, is added.
+type fluxTable struct {
+	types   []string
+	columns []string
+	rows    [][]string
+}
+
+func (t fluxTable) String() string {
+	var b strings.Builder
+	b.WriteString("#datatype," + strings.Join(t.types, ",") + "\n")
+	b.WriteString("," + strings.Join(t.columns, ",") + "\n")
+	for _, r := range t.rows {
+		b.WriteString("," + strings.Join(r, ",") + "\n")
+	}
+	return b.String()
+}
+
+// pivotTables returns the tables that a pivoted query (see fluxPivot) for the points returns:
+// one per series, with a row per timestamp and a column per field.
+func pivotTables(points ...*write.Point) []fluxTable {
+	var keys []string
+	bySeries := make(map[string][]*write.Point)
+	for _, p := range points {
+		key := p.Name()
+		for _, tag := range p.TagList() {
+			key += "," + tag.Key + "=" + tag.Value
+		}
+		if _, ok := bySeries[key]; !ok {
+			keys = append(keys, key)
+		}
+		bySeries[key] = append(bySeries[key], p)
+	}
+
+	var tables []fluxTable
+	for _, key := range keys {
+		series := bySeries[key]
+
+		var times []time.Time
+		values := make(map[time.Time]map[string]string)
+		types := make(map[string]string)
+		for _, p := range series {
+			if values[p.Time()] == nil {
+				times = append(times, p.Time())
+				values[p.Time()] = make(map[string]string)
+			}
+			for _, f := range p.FieldList() {
+				switch v := f.Value.(type) {
+				case float64:
+					types[f.Key] = "double"
+					values[p.Time()][f.Key] = strconv.FormatFloat(v, 'g', -1, 64)
+				case int64:
+					types[f.Key] = "long"
+					values[p.Time()][f.Key] = strconv.FormatInt(v, 10)
+				default:
+					types[f.Key] = "string"
+					values[p.Time()][f.Key] = fmt.Sprint(v)
+				}
+			}
+		}
+
+		fields := make([]string, 0, len(types))
+		for f := range types {
+			fields = append(fields, f)
+		}
+		sort.Strings(fields)
+
+		table := fluxTable{
+			types:   []string{"dateTime:RFC3339", "string"},
+			columns: []string{"_time", "_measurement"},
+		}
+		tags := series[0].TagList()
+		for _, tag := range tags {
+			table.types = append(table.types, "string")
+			table.columns = append(table.columns, tag.Key)
+		}
+		for _, f := range fields {
+			table.types = append(table.types, types[f])
+			table.columns = append(table.columns, f)
+		}
+
+		for _, t := range times {
+			row := []string{t.Format(time.RFC3339Nano), series[0].Name()}
+			for _, tag := range tags {
+				row = append(row, tag.Value)
+			}
+			for _, f := range fields {
+				row = append(row, values[t][f])
+			}
+			table.rows = append(table.rows, row)
+		}
+		tables = append(tables, table)
+	}
+	return tables
+}
+
+// measurementTables returns the tables that a pivoted query returns for the points that the
+// measurements are written as.
+func measurementTables(t *testing.T, opts InfluxDBOptions, ms ...*mpb.Measurement) []fluxTable {
+	t.Helper()
+
+	var points []*write.Point
+	for _, m := range ms {
+		ps, err := newInfluxDBPoints(m, opts)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		points = append(points, ps...)
+	}
+	return pivotTables(points...)
+}
+
+// newInfluxDBQueryServer returns an InfluxDB whose queries are answered with the given tables,
+// and the query that was sent, once it has been.
+func newInfluxDBQueryServer(t *testing.T, opts InfluxDBOptions, status int, tables ...fluxTable) (*InfluxDB, *string, func()) {
+	t.Helper()
+	return newInfluxDBQueryServerFunc(t, opts, status, func(string) []fluxTable {
+		return tables
+	})
+}
+
+// newInfluxDBQueryServerFunc is like newInfluxDBQueryServer but each query is answered with
+// the tables that respond returns for it.
+func newInfluxDBQueryServerFunc(t *testing.T, opts InfluxDBOptions, status int, respond func(query string) []fluxTable) (*InfluxDB, *string, func()) {
+	t.Helper()
+
+	var query string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v2/query" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if got := r.URL.Query().Get("org"); got != "org" {
+			t.Errorf("got org %q, want %q", got, "org")
+		}
+
+		var body struct {
+			Query string `json:"query"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		query = body.Query
+
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		tables := respond(query)
+		strs := make([]string, len(tables))
+		for i, table := range tables {
+			strs[i] = table.String()
+		}
+		fmt.Fprint(w, strings.Join(strs, "\n"))
+	}))
+
+	influxDB, err := NewInfluxDB(ts.URL, "secret", "org", "bucket", opts)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	return influxDB, &query, func() {
+		influxDB.Close()
+		ts.Close()
+	}
+}
+
+func mustStorable(t *testing.T, m *mpb.Measurement) measurement.StorableMeasurement {
+	t.Helper()
+	sm, err := measurement.NewStorableMeasurement(m)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return sm
+}
+
+func TestInfluxDBBetween(t *testing.T) {
+	full := &mpb.Measurement{
+		DeviceId:        "foo",
+		Timestamp:       mustTimestampProto(testTimestamp.Add(time.Minute)),
+		UploadTimestamp: mustTimestampProto(testTimestamp.Add(time.Hour)),
+		Window:          durationpb.New(time.Minute),
+		Sequence:        42,
+		BootId:          "boot",
+		Temp:            wpb.Float(18.5),
+		Rh:              wpb.Float(55.0),
+		Readings: []*mpb.Reading{
+			{Metric: "temp", Sensor: "mcp9808", Location: "probe-in", Value: 18.5},
+			{Metric: "temp", Sensor: "bme280", Value: 4.5},
+		},
+	}
+	earlier := &mpb.Measurement{
+		DeviceId:  "foo",
+		Timestamp: pbTimestamp,
+		Temp:      wpb.Float(17.5),
+	}
+	other := &mpb.Measurement{
+		DeviceId:  "bar",
+		Timestamp: pbTimestamp,
+		Pm25:      wpb.Float(12.0),
+	}
+
+	opts := InfluxDBOptions{Tags: map[string]string{"site": "home"}}
+	influxDB, query, done := newInfluxDBQueryServer(t, opts, http.StatusOK,
+		measurementTables(t, opts, full, earlier, other)...)
+	defer done()
+
+	got, err := influxDB.Between(context.Background(), testTimestamp, testTimestamp.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// The window isn't written. The value of a metric with readings is that of the first
+	// sensor by name, not the first that the device read.
+	fullTemp, rh := float32(4.5), float32(55.0)
+	want := map[string][]measurement.StorableMeasurement{
+		"foo": {
+			mustStorable(t, earlier),
+			{
+				DeviceID:        "foo",
+				Timestamp:       testTimestamp.Add(time.Minute),
+				UploadTimestamp: testTimestamp.Add(time.Hour),
+				Sequence:        42,
+				BootID:          "boot",
+				Temp:            &fullTemp,
+				RH:              &rh,
+				Readings: []measurement.Reading{
+					{Metric: "temp", Sensor: "bme280", Value: 4.5},
+					{Metric: "temp", Sensor: "mcp9808", Location: "probe-in", Value: 18.5},
+				},
+			},
+		},
+		"bar": {mustStorable(t, other)},
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("Unexpected result (-got +want):\n%s", diff)
+	}
+
+	for _, s := range []string{
+		`from(bucket: "bucket")`,
+		`range(start: 2018-03-25T00:00:00Z, stop: 2018-03-25T01:00:00.000000001Z)`,
+		`r._measurement == "stat"`,
+		`r["site"] == "home"`,
+		`pivot(rowKey: ["_time"], columnKey: ["_field"], valueColumn: "_value")`,
+	} {
+		if !strings.Contains(*query, s) {
+			t.Errorf("Query doesn't contain %q:\n%s", s, *query)
+		}
+	}
+}
+
+// Points written before measurements' metadata was, e.g. by older versions of the app, are
+// read back too.
+func TestInfluxDBBetweenBaseline(t *testing.T) {
+	points := []*write.Point{
+		influxdb2.NewPointWithMeasurement("stat").AddTag("device", "foo").AddField("temp", float32(18.5)).SetTime(testTimestamp),
+		influxdb2.NewPointWithMeasurement("stat").AddTag("device", "foo").AddField("rh", float32(55)).SetTime(testTimestamp),
+		influxdb2.NewPointWithMeasurement("stat").AddTag("device", "foo").AddField("temp", float32(19)).SetTime(testTimestamp.Add(time.Minute)),
+		influxdb2.NewPointWithMeasurement("stat").AddTag("device", "bar").AddField("pm25", float32(12)).SetTime(testTimestamp),
+	}
+
+	influxDB, _, done := newInfluxDBQueryServer(t, InfluxDBOptions{}, http.StatusOK, pivotTables(points...)...)
+	defer done()
+
+	got, err := influxDB.Between(context.Background(), testTimestamp, testTimestamp.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := map[string][]measurement.StorableMeasurement{
+		"foo": {
+			mustStorable(t, &mpb.Measurement{DeviceId: "foo", Timestamp: pbTimestamp, Temp: wpb.Float(18.5), Rh: wpb.Float(55)}),
+			mustStorable(t, &mpb.Measurement{DeviceId: "foo", Timestamp: mustTimestampProto(testTimestamp.Add(time.Minute)), Temp: wpb.Float(19)}),
+		},
+		"bar": {mustStorable(t, &mpb.Measurement{DeviceId: "bar", Timestamp: pbTimestamp, Pm25: wpb.Float(12)})},
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("Unexpected result (-got +want):\n%s", diff)
+	}
+}
+
+func TestInfluxDBDelayedSince(t *testing.T) {
+	m := &mpb.Measurement{
+		DeviceId:        "foo",
+		Timestamp:       mustTimestampProto(testTimestamp.Add(-time.Hour)),
+		UploadTimestamp: mustTimestampProto(testTimestamp.Add(time.Hour)),
+		Temp:            wpb.Float(18.5),
+	}
+
+	var queries []string
+	influxDB, _, done := newInfluxDBQueryServerFunc(t, InfluxDBOptions{}, http.StatusOK, func(query string) []fluxTable {
+		queries = append(queries, query)
+		if len(queries) == 1 {
+			return []fluxTable{{
+				types:   []string{"dateTime:RFC3339", "long", "string", "string", "string"},
+				columns: []string{"_time", "_value", "_field", "_measurement", "device"},
+				rows: [][]string{
+					{testTimestamp.Add(-time.Hour).Format(time.RFC3339), fmt.Sprint(testTimestamp.Add(time.Hour).UnixNano()), "upload_ts", "stat", "foo"},
+				},
+			}}
+		}
+		return measurementTables(t, InfluxDBOptions{}, m)
+	})
+	defer done()
+
+	got, err := influxDB.DelayedSince(context.Background(), testTimestamp)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := map[string][]measurement.StorableMeasurement{"foo": {mustStorable(t, m)}}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("Unexpected result (-got +want):\n%s", diff)
+	}
+
+	if len(queries) != 2 {
+		t.Fatalf("got %d queries, want 2", len(queries))
+	}
+	for i, s := range []string{
+		`r._field == "upload_ts" and r._value >= 1521936000000000000`,
+		`range(start: 2018-03-24T23:00:00Z)`,
+	} {
+		if !strings.Contains(queries[i], s) {
+			t.Errorf("Query %d doesn't contain %q:\n%s", i, s, queries[i])
+		}
+	}
+}
+
+func TestInfluxDBDelayedSinceNone(t *testing.T) {
+	var queries int
+	influxDB, _, done := newInfluxDBQueryServerFunc(t, InfluxDBOptions{}, http.StatusOK, func(string) []fluxTable {
+		queries++
+		return nil
+	})
+	defer done()
+
+	got, err := influxDB.DelayedSince(context.Background(), testTimestamp)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("got %v, want no measurements", got)
+	}
+	if queries != 1 {
+		t.Errorf("got %d queries, want 1", queries)
+	}
+}
+
+func TestInfluxDBLatest(t *testing.T) {
+	// foo's latest measurement only has readings. Its values' series has an older point.
+	newer := &mpb.Measurement{
+		DeviceId:  "foo",
+		Timestamp: mustTimestampProto(testTimestamp.Add(time.Hour)),
+		Temp:      wpb.Float(18.5),
+		Readings: []*mpb.Reading{
+			{Metric: "temp", Sensor: "mcp9808", Value: 18.5},
+		},
+	}
+	older := &mpb.Measurement{DeviceId: "foo", Timestamp: pbTimestamp, Rh: wpb.Float(55)}
+	other := &mpb.Measurement{DeviceId: "bar", Timestamp: pbTimestamp, Pm25: wpb.Float(12.0)}
+
+	influxDB, query, done := newInfluxDBQueryServer(t, InfluxDBOptions{}, http.StatusOK,
+		measurementTables(t, InfluxDBOptions{}, newer, older, other)...)
+	defer done()
+
+	got, err := influxDB.Latest(context.Background(), []string{"foo", "bar", "baz"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := map[string]measurement.StorableMeasurement{
+		"foo": mustStorable(t, newer),
+		"bar": mustStorable(t, other),
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("Unexpected result (-got +want):\n%s", diff)
+	}
+	for _, s := range []string{`contains(value: r["device"], set: ["foo", "bar", "baz"])`, `last()`, `pivot(`} {
+		if !strings.Contains(*query, s) {
+			t.Errorf("Query doesn't contain %q:\n%s", s, *query)
+		}
+	}
+}
+
+func TestInfluxDBDownsampled(t *testing.T) {
+	opts := InfluxDBOptions{DownsampleAfter: 24 * time.Hour, MaxPoints: 100}
+	startTime := testTimestamp
+	endTime := testTimestamp.Add(7 * 24 * time.Hour)
+	window := 101 * time.Minute
+
+	aggTable := func(agg, typ string, rows ...[]string) fluxTable {
+		table := fluxTable{
+			types:   []string{"dateTime:RFC3339", typ, "string", "string", "string", "string", "string", "string"},
+			columns: []string{"_time", "_value", "_field", "_measurement", "device", "sensor", "location", "_agg"},
+		}
+		for _, r := range rows {
+			table.rows = append(table.rows, append(r, agg))
+		}
+		return table
+	}
+	ts := testTimestamp.Format(time.RFC3339)
+
+	influxDB, query, done := newInfluxDBQueryServer(t, opts, http.StatusOK,
+		aggTable("mean", "double", []string{ts, "12", "pm25", "stat", "foo", "", ""}, []string{ts, "18.5", "temp", "stat", "foo", "mcp9808", "probe-in"}),
+		aggTable("min", "double", []string{ts, "10", "pm25", "stat", "foo", "", ""}, []string{ts, "18", "temp", "stat", "foo", "mcp9808", "probe-in"}),
+		aggTable("max", "double", []string{ts, "14", "pm25", "stat", "foo", "", ""}, []string{ts, "19", "temp", "stat", "foo", "mcp9808", "probe-in"}),
+		aggTable("count", "long", []string{ts, "60", "pm25", "stat", "foo", "", ""}, []string{ts, "59", "temp", "stat", "foo", "mcp9808", "probe-in"}))
+	defer done()
+
+	got, err := influxDB.Between(context.Background(), startTime, endTime)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	pm25, temp := float32(12), float32(18.5)
+	want := map[string][]measurement.StorableMeasurement{
+		"foo": {{
+			DeviceID:  "foo",
+			Timestamp: testTimestamp,
+			Window:    window,
+			PM25:      &pm25,
+			Temp:      &temp,
+			Readings: []measurement.Reading{
+				{Metric: "temp", Sensor: "mcp9808", Location: "probe-in", Value: 18.5},
+			},
+			Summaries: []measurement.Summary{
+				{Metric: "pm25", Min: 10, Max: 14, Mean: 12, Count: 60},
+				{Metric: "temp", Sensor: "mcp9808", Location: "probe-in", Min: 18, Max: 19, Mean: 18.5, Count: 59},
+			},
+		}},
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("Unexpected result (-got +want):\n%s", diff)
+	}
+
+	for _, s := range []string{
+		`r._measurement == "stat")`,
+		`r._field != "upload_ts" and r._field != "seq" and r._field != "boot_id"`,
+		`aggregateWindow(every: 6060s, fn: mean, timeSrc: "_start", createEmpty: false)`,
+		`aggregateWindow(every: 6060s, fn: count, timeSrc: "_start", createEmpty: false)`,
+	} {
+		if !strings.Contains(*query, s) {
+			t.Errorf("Query doesn't contain %q:\n%s", s, *query)
+		}
+	}
+}
+
+func TestInfluxDBQueryError(t *testing.T) {
+	influxDB, _, done := newInfluxDBQueryServer(t, InfluxDBOptions{}, http.StatusInternalServerError)
+	defer done()
+
+	if _, err := influxDB.Between(context.Background(), testTimestamp, testTimestamp.Add(time.Hour)); err == nil {
+		t.Errorf("Expected error")
+	}
+}
+
+func TestDownsampleWindow(t *testing.T) {
+	cases := []struct {
+		name      string
+		dur       time.Duration
+		maxPoints int
+		want      time.Duration
+	}{
+		{"exact", 100 * time.Minute, 100, time.Minute},
+		{"rounded_up", 7 * 24 * time.Hour, 100, 101 * time.Minute},
+		{"short", time.Minute, 1000, time.Minute},
+		{"no_max", time.Hour, 0, time.Hour},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := downsampleWindow(testTimestamp, testTimestamp.Add(c.dur), c.maxPoints); got != c.want {
+				t.Errorf("got %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestFluxString(t *testing.T) {
+	cases := []struct {
+		s    string
+		want string
+	}{
+		{"foo", `"foo"`},
+		{`a"b`, `"a\"b"`},
+		{`a\b`, `"a\\b"`},
+		{"${x}", `"\${x}"`},
+	}
+
+	for _, c := range cases {
+		if got := fluxString(c.s); got != c.want {
+			t.Errorf("fluxString(%q) = %s, want %s", c.s, got, c.want)
+		}
+	}
+}
@@ -1,6 +1,11 @@
 package db
 
 import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"sort"
 	"testing"
 	"time"
@@ -40,6 +45,7 @@ func TestNewInfluxDBPoints(t *testing.T) {
 	cases := []struct {
 		name string
 		m    mpb.Measurement
+		opts InfluxDBOptions
 		want []*write.Point
 	}{
 		{
@@ -52,23 +58,90 @@ func TestNewInfluxDBPoints(t *testing.T) {
 				influxdb2.NewPointWithMeasurement("stat").AddTag("device", "foo").AddField("rh", 55.0).SetTime(testTimestamp),
 			},
 		},
+		{
+			name: "readings",
+			m: mpb.Measurement{
+				DeviceId:  "foo",
+				Timestamp: pbTimestamp,
+				Temp:      wpb.Float(18.5),
+				Rh:        wpb.Float(55.0),
+				Readings: []*mpb.Reading{
+					{Metric: "temp", Sensor: "mcp9808", Location: "probe-in", Value: 18.5},
+					{Metric: "temp", Sensor: "bme280", Value: 4.5},
+				},
+			},
+			want: []*write.Point{
+				influxdb2.NewPointWithMeasurement("stat").AddTag("device", "foo").AddTag("sensor", "mcp9808").AddTag("location", "probe-in").AddField("temp", float32(18.5)).SetTime(testTimestamp),
+				influxdb2.NewPointWithMeasurement("stat").AddTag("device", "foo").AddTag("sensor", "bme280").AddField("temp", float32(4.5)).SetTime(testTimestamp),
+				influxdb2.NewPointWithMeasurement("stat").AddTag("device", "foo").AddField("rh", 55.0).SetTime(testTimestamp),
+			},
+		},
+		{
+			name: "metadata",
+			m: mpb.Measurement{
+				DeviceId:        "foo",
+				Timestamp:       pbTimestamp,
+				UploadTimestamp: mustTimestampProto(testTimestamp.Add(time.Hour)),
+				Sequence:        42,
+				BootId:          "boot",
+				Temp:            wpb.Float(18.5),
+				Readings: []*mpb.Reading{
+					{Metric: "rh", Sensor: "bme280", Value: 55},
+				},
+			},
+			want: []*write.Point{
+				influxdb2.NewPointWithMeasurement("stat").AddTag("device", "foo").AddTag("sensor", "bme280").AddField("rh", float32(55)).
+					AddField("upload_ts", testTimestamp.Add(time.Hour).UnixNano()).AddField("seq", int64(42)).AddField("boot_id", "boot").SetTime(testTimestamp),
+				influxdb2.NewPointWithMeasurement("stat").AddTag("device", "foo").AddField("temp", 18.5).
+					AddField("upload_ts", testTimestamp.Add(time.Hour).UnixNano()).AddField("seq", int64(42)).AddField("boot_id", "boot").SetTime(testTimestamp),
+			},
+		},
+		{
+			name: "options",
+			m: mpb.Measurement{
+				DeviceId:  "foo",
+				Timestamp: pbTimestamp,
+				Temp:      wpb.Float(18.5),
+				Rh:        wpb.Float(55.0),
+				Readings: []*mpb.Reading{
+					{Metric: "temp", Sensor: "mcp9808", Location: "probe-in", Value: 18.5},
+				},
+			},
+			opts: InfluxDBOptions{
+				Measurement: "environment",
+				DeviceTag:   "host",
+				SensorTag:   "chip",
+				LocationTag: "where",
+				Tags:        map[string]string{"site": "home", "env": "prod"},
+			},
+			want: []*write.Point{
+				influxdb2.NewPointWithMeasurement("environment").AddTag("env", "prod").AddTag("site", "home").AddTag("host", "foo").AddTag("chip", "mcp9808").AddTag("where", "probe-in").AddField("temp", float32(18.5)).SetTime(testTimestamp),
+				influxdb2.NewPointWithMeasurement("environment").AddTag("env", "prod").AddTag("site", "home").AddTag("host", "foo").AddField("rh", 55.0).SetTime(testTimestamp),
+			},
+		},
 	}
 
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
-			got, err := newInfluxDBPoints(&testMeasurement)
+			got, err := newInfluxDBPoints(&c.m, c.opts)
 			if err != nil {
 				t.Errorf("Unexpected error: %v", err)
 			}
 
-			// Sort slices before comparing them. Sort by the key of the first field, which is
-			// brittle, but since in this case we only have one field per Point it works.
-			sort.Slice(got, func(i, j int) bool {
-				return got[i].FieldList()[0].Key < got[j].FieldList()[0].Key
-			})
-			sort.Slice(c.want, func(i, j int) bool {
-				return c.want[i].FieldList()[0].Key < c.want[j].FieldList()[0].Key
-			})
+			// Sort slices before comparing them. Sort by the key of the first field and then by
+			// the number of tags, which is brittle, but since in this case we only have one field
+			// per Point and readings of the same metric have different tags it works.
+			less := func(points []*write.Point) func(i, j int) bool {
+				return func(i, j int) bool {
+					ki, kj := points[i].FieldList()[0].Key, points[j].FieldList()[0].Key
+					if ki != kj {
+						return ki < kj
+					}
+					return len(points[i].TagList()) < len(points[j].TagList())
+				}
+			}
+			sort.Slice(got, less(got))
+			sort.Slice(c.want, less(c.want))
 
 			if diff := cmp.Diff(got, c.want, cmp.AllowUnexported(write.Point{})); diff != "" {
 				t.Errorf("Unexpected result (-got +want):\n%s", diff)
@@ -77,3 +150,133 @@ func TestNewInfluxDBPoints(t *testing.T) {
 	}
 
 }
+
+func TestParseInfluxDBTags(t *testing.T) {
+	cases := []struct {
+		name  string
+		s     string
+		want  map[string]string
+		valid bool
+	}{
+		{"empty", "", map[string]string{}, true},
+		{"one", "site=home", map[string]string{"site": "home"}, true},
+		{"several", " site=home, env=prod ", map[string]string{"site": "home", "env": "prod"}, true},
+		{"no_value", "site", nil, false},
+		{"empty_value", "site=", nil, false},
+		{"empty_key", "=home", nil, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := ParseInfluxDBTags(c.s)
+			if valid := err == nil; valid != c.valid {
+				t.Fatalf("got valid = %t, want %t (err = %v)", valid, c.valid, err)
+			}
+			if diff := cmp.Diff(got, c.want); diff != "" {
+				t.Errorf("Unexpected result (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestInfluxDBSave(t *testing.T) {
+	m := &mpb.Measurement{
+		DeviceId:  "foo",
+		Timestamp: pbTimestamp,
+		Temp:      wpb.Float(18.5),
+	}
+	wantBody := "stat,device=foo temp=18.5 1521936000000000000\n"
+
+	cases := []struct {
+		name       string
+		status     int
+		retryAfter string
+		valid      bool
+		temporary  bool
+		wait       time.Duration
+	}{
+		{"ok", http.StatusNoContent, "", true, false, 0},
+		{"unavailable", http.StatusServiceUnavailable, "", false, true, 0},
+		{"rate_limited", http.StatusTooManyRequests, "3", false, true, 3 * time.Second},
+		{"bad_request", http.StatusBadRequest, "", false, false, 0},
+		{"unauthorized", http.StatusUnauthorized, "", false, false, 0},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			requests := 0
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost || r.URL.Path != "/api/v2/write" {
+					t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+				}
+				if got, want := r.Header.Get("Authorization"), "Token secret"; got != want {
+					t.Errorf("got Authorization %q, want %q", got, want)
+				}
+				q := r.URL.Query()
+				if q.Get("org") != "org" || q.Get("bucket") != "bucket" || q.Get("precision") != "ns" {
+					t.Errorf("Unexpected query %q", r.URL.RawQuery)
+				}
+				b, _ := ioutil.ReadAll(r.Body)
+				if string(b) != wantBody {
+					t.Errorf("got body %q, want %q", b, wantBody)
+				}
+
+				requests++
+				if c.retryAfter != "" {
+					w.Header().Set("Retry-After", c.retryAfter)
+				}
+				w.WriteHeader(c.status)
+			}))
+			defer ts.Close()
+
+			influxDB, err := NewInfluxDB(ts.URL, "secret", "org", "bucket", InfluxDBOptions{})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			defer influxDB.Close()
+
+			err = influxDB.Save(context.Background(), m)
+			if valid := err == nil; valid != c.valid {
+				t.Errorf("got valid = %t, want %t (err = %v)", valid, c.valid, err)
+			}
+			// Saves aren't re-tried by the database.
+			if requests != 1 {
+				t.Errorf("got %d requests, want 1", requests)
+			}
+			if err == nil {
+				return
+			}
+
+			var werr *influxDBWriteError
+			if !errors.As(err, &werr) {
+				t.Fatalf("got error of type %T, want %T", err, werr)
+			}
+			if got := werr.Temporary(); got != c.temporary {
+				t.Errorf("got temporary = %t, want %t", got, c.temporary)
+			}
+			if got := werr.RetryAfter(); got != c.wait {
+				t.Errorf("got retry after %v, want %v", got, c.wait)
+			}
+		})
+	}
+}
+
+func TestInfluxDBSaveUnreachable(t *testing.T) {
+	ts := httptest.NewServer(http.NotFoundHandler())
+	ts.Close()
+
+	influxDB, err := NewInfluxDB(ts.URL, "secret", "org", "bucket", InfluxDBOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer influxDB.Close()
+
+	err = influxDB.Save(context.Background(), &testMeasurement)
+	if err == nil {
+		t.Fatalf("Expected error when InfluxDB is unreachable")
+	}
+	var werr *influxDBWriteError
+	if !errors.As(err, &werr) || !werr.Temporary() {
+		t.Errorf("got %v, want a temporary error", err)
+	}
+}
@@ -0,0 +1,144 @@
+package db
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mtraver/environmental-sensor/measurement"
+	mpb "github.com/mtraver/environmental-sensor/measurementpb"
+	"google.golang.org/protobuf/proto"
+)
+
+// memoryDB keeps measurements in memory. It's safe for concurrent use and behaves as the
+// Datastore database does, so it's meant for development and tests.
+type memoryDB struct {
+	mu sync.RWMutex
+
+	// Keyed by the measurements' Datastore keys (see StorableMeasurement.DBKey).
+	measurements map[string]*mpb.Measurement
+}
+
+func NewMemoryDB() *memoryDB {
+	return &memoryDB{
+		measurements: make(map[string]*mpb.Measurement),
+	}
+}
+
+// Save saves the given Measurement to the database. If the Measurement already exists in the
+// database it makes no change to the database and returns nil as the error.
+func (db *memoryDB) Save(ctx context.Context, m *mpb.Measurement) error {
+	sm, err := measurement.NewStorableMeasurement(m)
+	if err != nil {
+		return err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	key := sm.DBKey()
+	if _, ok := db.measurements[key]; ok {
+		return nil
+	}
+
+	// Keep a copy so that the caller can't change what's stored.
+	db.measurements[key] = proto.Clone(m).(*mpb.Measurement)
+	return nil
+}
+
+// filter returns the measurements for which keep returns true, grouped by device ID and sorted
+// by the time returned by orderBy. Each call returns new StorableMeasurements, so callers may
+// modify them.
+func (db *memoryDB) filter(keep func(sm measurement.StorableMeasurement) bool, orderBy func(sm measurement.StorableMeasurement) time.Time) (map[string][]measurement.StorableMeasurement, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	results := make(map[string][]measurement.StorableMeasurement)
+	for _, m := range db.measurements {
+		sm, err := measurement.NewStorableMeasurement(m)
+		if err != nil {
+			return make(map[string][]measurement.StorableMeasurement), err
+		}
+		if keep(sm) {
+			results[sm.DeviceID] = append(results[sm.DeviceID], sm)
+		}
+	}
+
+	for _, ms := range results {
+		sort.Slice(ms, func(i, j int) bool {
+			return orderBy(ms[i]).Before(orderBy(ms[j]))
+		})
+	}
+	return results, nil
+}
+
+func byTimestamp(sm measurement.StorableMeasurement) time.Time {
+	return sm.Timestamp
+}
+
+// Since gets all measurements with a timestamp greater than or equal to startTime. It returns
+// a map of device ID (a string) to a StorableMeasurement slice, and an error.
+func (db *memoryDB) Since(ctx context.Context, startTime time.Time) (map[string][]measurement.StorableMeasurement, error) {
+	return db.filter(func(sm measurement.StorableMeasurement) bool {
+		return !sm.Timestamp.Before(startTime)
+	}, byTimestamp)
+}
+
+// DelayedSince gets all measurements with a non-nil upload timestamp greater than or equal to startTime.
+// It returns a map of device ID (a string) to a StorableMeasurement slice, and an error.
+func (db *memoryDB) DelayedSince(ctx context.Context, startTime time.Time) (map[string][]measurement.StorableMeasurement, error) {
+	return db.filter(func(sm measurement.StorableMeasurement) bool {
+		return !sm.UploadTimestamp.IsZero() && !sm.UploadTimestamp.Before(startTime)
+	}, func(sm measurement.StorableMeasurement) time.Time {
+		return sm.UploadTimestamp
+	})
+}
+
+// Between gets all measurements with a timestamp greater than or equal to startTime and less than or equal
+// to endTime. It returns a map of device ID (a string) to a StorableMeasurement slice, and an error.
+func (db *memoryDB) Between(ctx context.Context, startTime time.Time, endTime time.Time) (map[string][]measurement.StorableMeasurement, error) {
+	return db.filter(func(sm measurement.StorableMeasurement) bool {
+		return !sm.Timestamp.Before(startTime) && !sm.Timestamp.After(endTime)
+	}, byTimestamp)
+}
+
+// Latest gets the most recent measurement for each of the given device IDs. It returns a map of
+// device ID to StorableMeasurement, and an error. If no measurement is found for a device ID then
+// the returned map will not contain that device ID.
+func (db *memoryDB) Latest(ctx context.Context, deviceIDs []string) (map[string]measurement.StorableMeasurement, error) {
+	wanted := make(map[string]bool, len(deviceIDs))
+	for _, id := range deviceIDs {
+		wanted[id] = true
+	}
+
+	all, err := db.filter(func(sm measurement.StorableMeasurement) bool {
+		return wanted[sm.DeviceID]
+	}, byTimestamp)
+	if err != nil {
+		return make(map[string]measurement.StorableMeasurement), err
+	}
+
+	latest := make(map[string]measurement.StorableMeasurement)
+	for id, ms := range all {
+		latest[id] = ms[len(ms)-1]
+	}
+	return latest, nil
+}
+
+// DeviceIDs returns the IDs of the devices that have measurements in the database, sorted.
+func (db *memoryDB) DeviceIDs(ctx context.Context) ([]string, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	var ids []string
+	for _, m := range db.measurements {
+		if id := m.GetDeviceId(); !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
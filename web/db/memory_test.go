@@ -0,0 +1,134 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/mtraver/environmental-sensor/measurement"
+	mpb "github.com/mtraver/environmental-sensor/measurementpb"
+	wpb "google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestMemoryDB(t *testing.T) {
+	ctx := context.Background()
+	db := NewMemoryDB()
+
+	delayed := &mpb.Measurement{
+		DeviceId:        "foo",
+		Timestamp:       mustTimestampProto(testTimestamp.Add(time.Hour)),
+		UploadTimestamp: mustTimestampProto(testTimestamp.Add(2 * time.Hour)),
+		Temp:            wpb.Float(18.5),
+	}
+	earlier := &mpb.Measurement{DeviceId: "foo", Timestamp: pbTimestamp, Temp: wpb.Float(17.5)}
+	other := &mpb.Measurement{DeviceId: "bar", Timestamp: mustTimestampProto(testTimestamp.Add(30 * time.Minute)), Pm25: wpb.Float(12.0)}
+
+	for _, m := range []*mpb.Measurement{delayed, other, earlier} {
+		if err := db.Save(ctx, m); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	// Saving a measurement with the same key is a no-op, as is changing a saved measurement.
+	if err := db.Save(ctx, &mpb.Measurement{DeviceId: "foo", Timestamp: pbTimestamp, Temp: wpb.Float(0)}); err != nil {
+		t.Fatalf("Unexpected error saving duplicate: %v", err)
+	}
+	earlierWant := mustStorable(t, earlier)
+	earlier.Temp = wpb.Float(1)
+
+	got, err := db.Since(ctx, testTimestamp)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	want := map[string][]measurement.StorableMeasurement{
+		"foo": {earlierWant, mustStorable(t, delayed)},
+		"bar": {mustStorable(t, other)},
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("Since: unexpected result (-got +want):\n%s", diff)
+	}
+
+	got, err = db.Between(ctx, testTimestamp.Add(time.Minute), testTimestamp.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	want = map[string][]measurement.StorableMeasurement{
+		"foo": {mustStorable(t, delayed)},
+		"bar": {mustStorable(t, other)},
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("Between: unexpected result (-got +want):\n%s", diff)
+	}
+
+	got, err = db.DelayedSince(ctx, testTimestamp)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	want = map[string][]measurement.StorableMeasurement{"foo": {mustStorable(t, delayed)}}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("DelayedSince: unexpected result (-got +want):\n%s", diff)
+	}
+
+	latest, err := db.Latest(ctx, []string{"foo", "bar", "baz"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	wantLatest := map[string]measurement.StorableMeasurement{
+		"foo": mustStorable(t, delayed),
+		"bar": mustStorable(t, other),
+	}
+	if diff := cmp.Diff(latest, wantLatest); diff != "" {
+		t.Errorf("Latest: unexpected result (-got +want):\n%s", diff)
+	}
+
+	ids, err := db.DeviceIDs(ctx)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(ids, []string{"bar", "foo"}); diff != "" {
+		t.Errorf("DeviceIDs: unexpected result (-got +want):\n%s", diff)
+	}
+}
+
+func TestMemoryDBConcurrent(t *testing.T) {
+	ctx := context.Background()
+	db := NewMemoryDB()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				m := &mpb.Measurement{
+					DeviceId:  fmt.Sprintf("dev%d", i),
+					Timestamp: mustTimestampProto(testTimestamp.Add(time.Duration(j) * time.Minute)),
+					Temp:      wpb.Float(float32(j)),
+				}
+				if err := db.Save(ctx, m); err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				if _, err := db.Latest(ctx, []string{m.DeviceId}); err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	got, err := db.Since(ctx, testTimestamp)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(got) != 10 {
+		t.Errorf("got %d devices, want 10", len(got))
+	}
+	for id, ms := range got {
+		if len(ms) != 10 {
+			t.Errorf("got %d measurements for %s, want 10", len(ms), id)
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/datastore"
+)
+
+// ErrNotQuarantined is returned when there's no quarantined message with a given ID.
+var ErrNotQuarantined = errors.New("db: no such quarantined message")
+
+// Quarantined message IDs are Pub/Sub message IDs, which are numeric, or generated. They're
+// restricted so that they're safe to use as file names.
+var quarantineIDRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Attribute is a Pub/Sub message attribute. Attributes are stored as a slice because
+// Datastore can't store maps.
+type Attribute struct {
+	Key   string `datastore:"key,noindex" json:"key"`
+	Value string `datastore:"value,noindex" json:"value"`
+}
+
+// QuarantinedMessage is a Pub/Sub message that was rejected by the push handler, kept with
+// the reason it was rejected so that it can be inspected and replayed.
+type QuarantinedMessage struct {
+	ID         string      `datastore:"-" json:"id"`
+	Received   time.Time   `datastore:"received" json:"received"`
+	Reason     string      `datastore:"reason,noindex" json:"reason"`
+	Data       []byte      `datastore:"data,noindex" json:"data"`
+	Attributes []Attribute `datastore:"attributes,noindex" json:"attributes"`
+}
+
+// NewQuarantinedMessage returns a QuarantinedMessage received now. If id is empty then one
+// is generated.
+func NewQuarantinedMessage(id, reason string, data []byte, attributes map[string]string) QuarantinedMessage {
+	now := time.Now().UTC()
+	if id == "" {
+		id = "gen-" + strconv.FormatInt(now.UnixNano(), 10)
+	}
+
+	keys := make([]string, 0, len(attributes))
+	for k := range attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	attrs := make([]Attribute, len(keys))
+	for i, k := range keys {
+		attrs[i] = Attribute{Key: k, Value: attributes[k]}
+	}
+
+	return QuarantinedMessage{
+		ID:         id,
+		Received:   now,
+		Reason:     reason,
+		Data:       data,
+		Attributes: attrs,
+	}
+}
+
+// AttributeMap returns the message's attributes as a map, as they're given in a Pub/Sub message.
+func (q QuarantinedMessage) AttributeMap() map[string]string {
+	m := make(map[string]string, len(q.Attributes))
+	for _, a := range q.Attributes {
+		m[a.Key] = a.Value
+	}
+	return m
+}
+
+func checkQuarantineID(id string) error {
+	if !quarantineIDRegex.MatchString(id) {
+		return fmt.Errorf("db: bad quarantined message ID %q", id)
+	}
+	return nil
+}
+
+// sortQuarantined sorts the messages from most to least recently received.
+func sortQuarantined(msgs []QuarantinedMessage) {
+	sort.Slice(msgs, func(i, j int) bool {
+		if !msgs[i].Received.Equal(msgs[j].Received) {
+			return msgs[i].Received.After(msgs[j].Received)
+		}
+		return msgs[i].ID < msgs[j].ID
+	})
+}
+
+type datastoreQuarantine struct {
+	kind   string
+	client *datastore.Client
+}
+
+// NewDatastoreQuarantine returns a quarantine that stores messages as Datastore entities of the given kind.
+func NewDatastoreQuarantine(projectID string, kind string) (*datastoreQuarantine, error) {
+	client, err := datastore.NewClient(context.Background(), projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &datastoreQuarantine{
+		kind:   kind,
+		client: client,
+	}, nil
+}
+
+// Add adds the message to the quarantine, replacing any message with the same ID.
+func (dq *datastoreQuarantine) Add(ctx context.Context, q QuarantinedMessage) error {
+	if err := checkQuarantineID(q.ID); err != nil {
+		return err
+	}
+
+	_, err := dq.client.Put(ctx, datastore.NameKey(dq.kind, q.ID, nil), &q)
+	return err
+}
+
+// List returns all quarantined messages, most recently received first.
+func (dq *datastoreQuarantine) List(ctx context.Context) ([]QuarantinedMessage, error) {
+	var msgs []QuarantinedMessage
+	keys, err := dq.client.GetAll(ctx, datastore.NewQuery(dq.kind).Order("-received"), &msgs)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, k := range keys {
+		msgs[i].ID = k.Name
+	}
+	return msgs, nil
+}
+
+// Get returns the quarantined message with the given ID.
+func (dq *datastoreQuarantine) Get(ctx context.Context, id string) (QuarantinedMessage, error) {
+	if err := checkQuarantineID(id); err != nil {
+		return QuarantinedMessage{}, err
+	}
+
+	var q QuarantinedMessage
+	if err := dq.client.Get(ctx, datastore.NameKey(dq.kind, id, nil), &q); err == datastore.ErrNoSuchEntity {
+		return QuarantinedMessage{}, ErrNotQuarantined
+	} else if err != nil {
+		return QuarantinedMessage{}, err
+	}
+
+	q.ID = id
+	return q, nil
+}
+
+// Delete removes the message with the given ID from the quarantine.
+func (dq *datastoreQuarantine) Delete(ctx context.Context, id string) error {
+	if err := checkQuarantineID(id); err != nil {
+		return err
+	}
+
+	return dq.client.Delete(ctx, datastore.NameKey(dq.kind, id, nil))
+}
+
+// fileQuarantine stores each quarantined message as a JSON file in a directory. It's meant
+// for running without Datastore.
+type fileQuarantine struct {
+	dir string
+}
+
+// NewFileQuarantine returns a quarantine that stores messages in the given directory,
+// creating it if need be.
+func NewFileQuarantine(dir string) (*fileQuarantine, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &fileQuarantine{dir: dir}, nil
+}
+
+func (fq *fileQuarantine) path(id string) string {
+	return filepath.Join(fq.dir, id+".json")
+}
+
+// Add adds the message to the quarantine, replacing any message with the same ID.
+func (fq *fileQuarantine) Add(ctx context.Context, q QuarantinedMessage) error {
+	if err := checkQuarantineID(q.ID); err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(q)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file and rename it so that a message is never half written.
+	tmp, err := ioutil.TempFile(fq.dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), fq.path(q.ID))
+}
+
+// List returns all quarantined messages, most recently received first.
+func (fq *fileQuarantine) List(ctx context.Context) ([]QuarantinedMessage, error) {
+	entries, err := ioutil.ReadDir(fq.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var msgs []QuarantinedMessage
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+
+		q, err := fq.Get(ctx, strings.TrimSuffix(e.Name(), ".json"))
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, q)
+	}
+
+	sortQuarantined(msgs)
+	return msgs, nil
+}
+
+// Get returns the quarantined message with the given ID.
+func (fq *fileQuarantine) Get(ctx context.Context, id string) (QuarantinedMessage, error) {
+	if err := checkQuarantineID(id); err != nil {
+		return QuarantinedMessage{}, err
+	}
+
+	b, err := ioutil.ReadFile(fq.path(id))
+	if os.IsNotExist(err) {
+		return QuarantinedMessage{}, ErrNotQuarantined
+	} else if err != nil {
+		return QuarantinedMessage{}, err
+	}
+
+	var q QuarantinedMessage
+	if err := json.Unmarshal(b, &q); err != nil {
+		return QuarantinedMessage{}, fmt.Errorf("db: failed to parse quarantined message %s: %v", id, err)
+	}
+	q.ID = id
+	return q, nil
+}
+
+// Delete removes the message with the given ID from the quarantine.
+func (fq *fileQuarantine) Delete(ctx context.Context, id string) error {
+	if err := checkQuarantineID(id); err != nil {
+		return err
+	}
+
+	if err := os.Remove(fq.path(id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
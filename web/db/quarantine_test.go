@@ -0,0 +1,81 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNewQuarantinedMessage(t *testing.T) {
+	attrs := map[string]string{"subFolder": "batch", "deviceId": "foo"}
+	q := NewQuarantinedMessage("", "bad", []byte("data"), attrs)
+
+	if err := checkQuarantineID(q.ID); err != nil {
+		t.Errorf("Generated ID is invalid: %v", err)
+	}
+
+	want := []Attribute{{"deviceId", "foo"}, {"subFolder", "batch"}}
+	if diff := cmp.Diff(q.Attributes, want); diff != "" {
+		t.Errorf("Unexpected result (-got +want):\n%s", diff)
+	}
+
+	if diff := cmp.Diff(q.AttributeMap(), attrs); diff != "" {
+		t.Errorf("Unexpected result (-got +want):\n%s", diff)
+	}
+}
+
+func TestFileQuarantine(t *testing.T) {
+	ctx := context.Background()
+	fq, err := NewFileQuarantine(t.TempDir())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	older := NewQuarantinedMessage("1", "bad signature", []byte{1, 2, 3}, map[string]string{"deviceId": "foo"})
+	older.Received = time.Date(2018, time.March, 25, 0, 0, 0, 0, time.UTC)
+	newer := NewQuarantinedMessage("2", "invalid measurement", []byte{4, 5}, nil)
+	newer.Received = time.Date(2018, time.March, 26, 0, 0, 0, 0, time.UTC)
+
+	for _, q := range []QuarantinedMessage{older, newer} {
+		if err := fq.Add(ctx, q); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	got, err := fq.List(ctx)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(got, []QuarantinedMessage{newer, older}); diff != "" {
+		t.Errorf("Unexpected result (-got +want):\n%s", diff)
+	}
+
+	// Adding a message with the same ID replaces it.
+	older.Reason = "still bad"
+	if err := fq.Add(ctx, older); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	q, err := fq.Get(ctx, "1")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(q, older); diff != "" {
+		t.Errorf("Unexpected result (-got +want):\n%s", diff)
+	}
+
+	if err := fq.Delete(ctx, "1"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := fq.Get(ctx, "1"); err != ErrNotQuarantined {
+		t.Errorf("got err = %v, want %v", err, ErrNotQuarantined)
+	}
+	if err := fq.Delete(ctx, "1"); err != nil {
+		t.Errorf("Unexpected error deleting twice: %v", err)
+	}
+
+	if err := fq.Add(ctx, NewQuarantinedMessage("../escape", "", nil, nil)); err == nil {
+		t.Errorf("Expected error for bad ID")
+	}
+}
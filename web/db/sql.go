@@ -0,0 +1,272 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mtraver/environmental-sensor/measurement"
+	mpb "github.com/mtraver/environmental-sensor/measurementpb"
+	"google.golang.org/protobuf/proto"
+)
+
+// sqlDialect holds what differs between the SQL databases that are supported.
+type sqlDialect struct {
+	// The type of binary columns.
+	blobType string
+
+	// Whether query parameters are numbered ($1, $2, ...) rather than question marks.
+	numberedParams bool
+}
+
+var sqlDialects = map[string]sqlDialect{
+	"sqlite3":  {blobType: "BLOB"},
+	"postgres": {blobType: "BYTEA", numberedParams: true},
+}
+
+// sqlDriverRegistered reports whether the driver with the given name has been imported.
+func sqlDriverRegistered(driver string) bool {
+	for _, d := range sql.Drivers() {
+		if d == driver {
+			return true
+		}
+	}
+	return false
+}
+
+// rebind rewrites the question mark parameters in the query for the dialect.
+func (d sqlDialect) rebind(query string) string {
+	if !d.numberedParams {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// sqlMigrations are the statements that create and update the schema, in order. Each is
+// applied once and then recorded in the schema_migrations table. Don't change a migration
+// that's been released; add another.
+//
+// Measurements are stored encoded, so that they read back exactly as they were saved, along
+// with the columns that they're queried by. Timestamps are nanoseconds since the epoch. The
+// ID is the measurement's Datastore key (see StorableMeasurement.DBKey), so that saving is
+// idempotent in the same way.
+var sqlMigrations = [][]string{
+	{
+		`CREATE TABLE measurements (
+			id TEXT PRIMARY KEY,
+			device_id TEXT NOT NULL,
+			timestamp BIGINT NOT NULL,
+			upload_timestamp BIGINT,
+			data {{blob}} NOT NULL
+		)`,
+		`CREATE INDEX measurements_device_id_timestamp ON measurements (device_id, timestamp)`,
+		`CREATE INDEX measurements_timestamp ON measurements (timestamp)`,
+		`CREATE INDEX measurements_upload_timestamp ON measurements (upload_timestamp)`,
+	},
+}
+
+type sqlDB struct {
+	db      *sql.DB
+	dialect sqlDialect
+}
+
+// NewSQLDB returns a database that stores measurements in SQLite or PostgreSQL. The driver must
+// be "sqlite3" or "postgres", and it must be registered by importing it. The schema is created
+// or updated if need be.
+func NewSQLDB(driver, dataSourceName string) (*sqlDB, error) {
+	dialect, ok := sqlDialects[driver]
+	if !ok {
+		return nil, fmt.Errorf("db: unsupported SQL driver %q", driver)
+	}
+	if !sqlDriverRegistered(driver) {
+		// The SQLite driver needs cgo, so binaries only have it if they're built with the
+		// sqlite3 build tag.
+		return nil, fmt.Errorf("db: SQL driver %q isn't built in", driver)
+	}
+
+	sqldb, err := sql.Open(driver, dataSourceName)
+	if err != nil {
+		return nil, err
+	}
+	if driver == "sqlite3" {
+		// SQLite allows one writer at a time, and an in-memory database is per connection.
+		sqldb.SetMaxOpenConns(1)
+	}
+
+	db := &sqlDB{
+		db:      sqldb,
+		dialect: dialect,
+	}
+	if err := db.migrate(context.Background()); err != nil {
+		sqldb.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// migrate applies the migrations that haven't been applied yet, each in a transaction.
+func (db *sqlDB) migrate(ctx context.Context) error {
+	if _, err := db.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY)`); err != nil {
+		return fmt.Errorf("db: failed to create schema_migrations table: %v", err)
+	}
+
+	var version int
+	if err := db.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+		return fmt.Errorf("db: failed to get schema version: %v", err)
+	}
+
+	for i := version; i < len(sqlMigrations); i++ {
+		tx, err := db.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+
+		for _, stmt := range sqlMigrations[i] {
+			stmt = strings.Replace(stmt, "{{blob}}", db.dialect.blobType, -1)
+			if _, err := tx.ExecContext(ctx, stmt); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("db: migration %d failed: %v", i+1, err)
+			}
+		}
+		if _, err := tx.ExecContext(ctx, db.dialect.rebind(`INSERT INTO schema_migrations (version) VALUES (?)`), i+1); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("db: migration %d failed: %v", i+1, err)
+		}
+
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("db: migration %d failed: %v", i+1, err)
+		}
+	}
+
+	return nil
+}
+
+// Close closes the database.
+func (db *sqlDB) Close() error {
+	return db.db.Close()
+}
+
+// Save saves the given Measurement to the database. If the Measurement already exists in the
+// database it makes no change to the database and returns nil as the error.
+func (db *sqlDB) Save(ctx context.Context, m *mpb.Measurement) error {
+	sm, err := measurement.NewStorableMeasurement(m)
+	if err != nil {
+		return err
+	}
+
+	data, err := proto.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	var uploadTimestamp sql.NullInt64
+	if !sm.UploadTimestamp.IsZero() {
+		uploadTimestamp = sql.NullInt64{Int64: sm.UploadTimestamp.UnixNano(), Valid: true}
+	}
+
+	_, err = db.db.ExecContext(ctx, db.dialect.rebind(
+		`INSERT INTO measurements (id, device_id, timestamp, upload_timestamp, data) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO NOTHING`),
+		sm.DBKey(), sm.DeviceID, sm.Timestamp.UnixNano(), uploadTimestamp, data)
+	return err
+}
+
+// decodeMeasurement decodes a measurement encoded as a protobuf.
+func decodeMeasurement(b []byte) (measurement.StorableMeasurement, error) {
+	var m mpb.Measurement
+	if err := proto.Unmarshal(b, &m); err != nil {
+		return measurement.StorableMeasurement{}, fmt.Errorf("db: failed to decode measurement: %v", err)
+	}
+	return measurement.NewStorableMeasurement(&m)
+}
+
+func (db *sqlDB) executeQuery(ctx context.Context, query string, args ...interface{}) (map[string][]measurement.StorableMeasurement, error) {
+	rows, err := db.db.QueryContext(ctx, db.dialect.rebind(query), args...)
+	if err != nil {
+		return make(map[string][]measurement.StorableMeasurement), err
+	}
+	defer rows.Close()
+
+	results := make(map[string][]measurement.StorableMeasurement)
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return make(map[string][]measurement.StorableMeasurement), err
+		}
+
+		sm, err := decodeMeasurement(data)
+		if err != nil {
+			return make(map[string][]measurement.StorableMeasurement), err
+		}
+		results[sm.DeviceID] = append(results[sm.DeviceID], sm)
+	}
+	if err := rows.Err(); err != nil {
+		return make(map[string][]measurement.StorableMeasurement), err
+	}
+
+	return results, nil
+}
+
+// Since gets all measurements with a timestamp greater than or equal to startTime. It returns
+// a map of device ID (a string) to a StorableMeasurement slice, and an error.
+func (db *sqlDB) Since(ctx context.Context, startTime time.Time) (map[string][]measurement.StorableMeasurement, error) {
+	return db.executeQuery(ctx, `SELECT data FROM measurements WHERE timestamp >= ? ORDER BY timestamp`, startTime.UnixNano())
+}
+
+// DelayedSince gets all measurements with a non-nil upload timestamp greater than or equal to startTime.
+// It returns a map of device ID (a string) to a StorableMeasurement slice, and an error.
+func (db *sqlDB) DelayedSince(ctx context.Context, startTime time.Time) (map[string][]measurement.StorableMeasurement, error) {
+	return db.executeQuery(ctx, `SELECT data FROM measurements WHERE upload_timestamp >= ? ORDER BY upload_timestamp`, startTime.UnixNano())
+}
+
+// Between gets all measurements with a timestamp greater than or equal to startTime and less than or equal
+// to endTime. It returns a map of device ID (a string) to a StorableMeasurement slice, and an error.
+func (db *sqlDB) Between(ctx context.Context, startTime time.Time, endTime time.Time) (map[string][]measurement.StorableMeasurement, error) {
+	return db.executeQuery(ctx, `SELECT data FROM measurements WHERE timestamp >= ? AND timestamp <= ? ORDER BY timestamp`,
+		startTime.UnixNano(), endTime.UnixNano())
+}
+
+// Latest gets the most recent measurement for each of the given device IDs. It returns a map of
+// device ID to StorableMeasurement, and an error. If no measurement is found for a device ID then
+// the returned map will not contain that device ID.
+func (db *sqlDB) Latest(ctx context.Context, deviceIDs []string) (map[string]measurement.StorableMeasurement, error) {
+	latest := make(map[string]measurement.StorableMeasurement)
+
+	for _, id := range deviceIDs {
+		if _, ok := latest[id]; ok {
+			continue
+		}
+
+		var data []byte
+		err := db.db.QueryRowContext(ctx, db.dialect.rebind(
+			`SELECT data FROM measurements WHERE device_id = ? ORDER BY timestamp DESC LIMIT 1`), id).Scan(&data)
+		if err == sql.ErrNoRows {
+			continue
+		} else if err != nil {
+			return latest, err
+		}
+
+		sm, err := decodeMeasurement(data)
+		if err != nil {
+			return latest, err
+		}
+		latest[id] = sm
+	}
+
+	return latest, nil
+}
@@ -0,0 +1,188 @@
+package db
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/mtraver/environmental-sensor/measurement"
+	mpb "github.com/mtraver/environmental-sensor/measurementpb"
+	durationpb "google.golang.org/protobuf/types/known/durationpb"
+	wpb "google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// testSQLDBs returns the databases to test against: SQLite, and PostgreSQL if POSTGRES_DSN is
+// set. The PostgreSQL database should be empty because the test doesn't clean up after itself.
+func testSQLDBs(t *testing.T) map[string]*sqlDB {
+	t.Helper()
+
+	sqlite, err := NewSQLDB("sqlite3", filepath.Join(t.TempDir(), "measurements.db"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	t.Cleanup(func() { sqlite.Close() })
+	dbs := map[string]*sqlDB{"sqlite": sqlite}
+
+	if dsn := os.Getenv("POSTGRES_DSN"); dsn != "" {
+		postgres, err := NewSQLDB("postgres", dsn)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		t.Cleanup(func() { postgres.Close() })
+		dbs["postgres"] = postgres
+	}
+
+	return dbs
+}
+
+func TestSQLDB(t *testing.T) {
+	full := &mpb.Measurement{
+		DeviceId:        "foo",
+		Timestamp:       mustTimestampProto(testTimestamp.Add(time.Hour)),
+		UploadTimestamp: mustTimestampProto(testTimestamp.Add(2 * time.Hour)),
+		Window:          durationpb.New(time.Minute),
+		Heartbeat:       durationpb.New(10 * time.Minute),
+		Sequence:        42,
+		BootId:          "boot",
+		Temp:            wpb.Float(18.5),
+		Readings: []*mpb.Reading{
+			{Metric: "temp", Sensor: "mcp9808", Location: "probe-in", Value: 18.5},
+		},
+		Summaries: []*mpb.Summary{
+			{Metric: "temp", Sensor: "mcp9808", Location: "probe-in", Min: 18, Max: 19, Mean: 18.5, Count: 6},
+		},
+	}
+	earlier := &mpb.Measurement{DeviceId: "foo", Timestamp: pbTimestamp, Temp: wpb.Float(17.5)}
+	other := &mpb.Measurement{DeviceId: "bar", Timestamp: mustTimestampProto(testTimestamp.Add(30 * time.Minute)), Pm25: wpb.Float(12.0)}
+
+	for name, db := range testSQLDBs(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			for _, m := range []*mpb.Measurement{full, other, earlier} {
+				if err := db.Save(ctx, m); err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+			}
+
+			// Saving a measurement with the same device ID and timestamp is a no-op.
+			dup := &mpb.Measurement{DeviceId: "foo", Timestamp: pbTimestamp, Temp: wpb.Float(0)}
+			if err := db.Save(ctx, dup); err != nil {
+				t.Fatalf("Unexpected error saving duplicate: %v", err)
+			}
+
+			got, err := db.Since(ctx, testTimestamp)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			want := map[string][]measurement.StorableMeasurement{
+				"foo": {mustStorable(t, earlier), mustStorable(t, full)},
+				"bar": {mustStorable(t, other)},
+			}
+			if diff := cmp.Diff(got, want); diff != "" {
+				t.Errorf("Since: unexpected result (-got +want):\n%s", diff)
+			}
+
+			got, err = db.Between(ctx, testTimestamp.Add(time.Minute), testTimestamp.Add(time.Hour))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			want = map[string][]measurement.StorableMeasurement{
+				"foo": {mustStorable(t, full)},
+				"bar": {mustStorable(t, other)},
+			}
+			if diff := cmp.Diff(got, want); diff != "" {
+				t.Errorf("Between: unexpected result (-got +want):\n%s", diff)
+			}
+
+			got, err = db.DelayedSince(ctx, testTimestamp)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			want = map[string][]measurement.StorableMeasurement{
+				"foo": {mustStorable(t, full)},
+			}
+			if diff := cmp.Diff(got, want); diff != "" {
+				t.Errorf("DelayedSince: unexpected result (-got +want):\n%s", diff)
+			}
+
+			latest, err := db.Latest(ctx, []string{"foo", "bar", "baz", "foo"})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			wantLatest := map[string]measurement.StorableMeasurement{
+				"foo": mustStorable(t, full),
+				"bar": mustStorable(t, other),
+			}
+			if diff := cmp.Diff(latest, wantLatest); diff != "" {
+				t.Errorf("Latest: unexpected result (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSQLDBMigrate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "measurements.db")
+	db, err := NewSQLDB("sqlite3", path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	m := &mpb.Measurement{DeviceId: "foo", Timestamp: pbTimestamp, Temp: wpb.Float(17.5)}
+	if err := db.Save(context.Background(), m); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	db.Close()
+
+	// Opening the database again doesn't re-apply migrations or lose measurements.
+	db, err = NewSQLDB("sqlite3", path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer db.Close()
+
+	var version int
+	if err := db.db.QueryRow(`SELECT MAX(version) FROM schema_migrations`).Scan(&version); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if version != len(sqlMigrations) {
+		t.Errorf("got schema version %d, want %d", version, len(sqlMigrations))
+	}
+
+	latest, err := db.Latest(context.Background(), []string{"foo"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(latest, map[string]measurement.StorableMeasurement{"foo": mustStorable(t, m)}); diff != "" {
+		t.Errorf("Unexpected result (-got +want):\n%s", diff)
+	}
+}
+
+func TestSQLDialectRebind(t *testing.T) {
+	cases := []struct {
+		name    string
+		dialect sqlDialect
+		query   string
+		want    string
+	}{
+		{"sqlite", sqlDialects["sqlite3"], "SELECT ? AND ?", "SELECT ? AND ?"},
+		{"postgres", sqlDialects["postgres"], "SELECT ? AND ?", "SELECT $1 AND $2"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := c.dialect.rebind(c.query); got != c.want {
+				t.Errorf("got %q, want %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestNewSQLDBUnsupportedDriver(t *testing.T) {
+	if _, err := NewSQLDB("mysql", ""); err == nil {
+		t.Errorf("Expected error for unsupported driver")
+	}
+}
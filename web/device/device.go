@@ -2,6 +2,9 @@ package device
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/pem"
 	"fmt"
 	"time"
 
@@ -17,15 +20,19 @@ func getRegistryPath(projectID, registryID string) string {
 		projectID, region, registryID)
 }
 
-// GetDevices returns a list of the devices in the given registry.
-func GetDevices(ctx context.Context, projectID, registryID string) ([]*cloudiot.Device, error) {
+func newService(ctx context.Context) (*cloudiot.Service, error) {
 	client, err := google.DefaultClient(ctx, cloudiot.CloudiotScope)
 	if err != nil {
-		return []*cloudiot.Device{}, err
+		return nil, err
 	}
 	client.Timeout = time.Second * 10
 
-	cloudiotService, err := cloudiot.New(client)
+	return cloudiot.New(client)
+}
+
+// GetDevices returns a list of the devices in the given registry.
+func GetDevices(ctx context.Context, projectID, registryID string) ([]*cloudiot.Device, error) {
+	cloudiotService, err := newService(ctx)
 	if err != nil {
 		return []*cloudiot.Device{}, err
 	}
@@ -53,3 +60,70 @@ func GetDeviceIDs(ctx context.Context, projectID, registryID string) ([]string,
 
 	return ids, nil
 }
+
+// GetPublicKeys returns the ECDSA public keys in the credentials of the given device. These are
+// the keys with which the device signs its JWTs, and so also its measurements. Credentials of
+// other types, e.g. RSA keys, are skipped.
+func GetPublicKeys(ctx context.Context, projectID, registryID, deviceID string) ([]*ecdsa.PublicKey, error) {
+	cloudiotService, err := newService(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := cloudiotService.Projects.Locations.Registries.Devices.Get(
+		getRegistryPath(projectID, registryID) + "/devices/" + deviceID).FieldMask("credentials").Do()
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []*ecdsa.PublicKey
+	for _, c := range d.Credentials {
+		if c.PublicKey == nil {
+			continue
+		}
+
+		switch c.PublicKey.Format {
+		case "ES256_PEM", "ES256_X509_PEM":
+			key, err := ParsePublicKey(c.PublicKey.Key)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, key)
+		}
+	}
+
+	return keys, nil
+}
+
+// ParsePublicKey parses a PEM-encoded ECDSA public key, which may be bare or wrapped in
+// an X.509 certificate.
+func ParsePublicKey(s string) (*ecdsa.PublicKey, error) {
+	block, _ := pem.Decode([]byte(s))
+	if block == nil {
+		return nil, fmt.Errorf("device: no PEM data in public key")
+	}
+
+	var key interface{}
+	switch block.Type {
+	case "PUBLIC KEY":
+		k, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("device: failed to parse public key: %v", err)
+		}
+		key = k
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("device: failed to parse certificate: %v", err)
+		}
+		key = cert.PublicKey
+	default:
+		return nil, fmt.Errorf("device: unexpected PEM block type %q", block.Type)
+	}
+
+	ecKey, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("device: public key is not an ECDSA key")
+	}
+	return ecKey, nil
+}
@@ -1,8 +1,17 @@
 package device
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
 	"fmt"
+	"math/big"
 	"testing"
+	"time"
 )
 
 func TestGetRegistryPath(t *testing.T) {
@@ -13,3 +22,63 @@ func TestGetRegistryPath(t *testing.T) {
 		t.Errorf("Expected %q, got %q", expected, path)
 	}
 }
+
+func encodePEM(blockType string, der []byte) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}))
+}
+
+func TestParsePublicKey(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	pub, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "foo"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	rsaPKIX, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	cases := []struct {
+		name  string
+		pem   string
+		valid bool
+	}{
+		{"public_key", encodePEM("PUBLIC KEY", pub), true},
+		{"certificate", encodePEM("CERTIFICATE", cert), true},
+		{"rsa", encodePEM("PUBLIC KEY", rsaPKIX), false},
+		{"wrong_type", encodePEM("PRIVATE KEY", pub), false},
+		{"garbage", encodePEM("PUBLIC KEY", []byte("not a key")), false},
+		{"not_pem", "not a key", false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := ParsePublicKey(c.pem)
+			if valid := err == nil; valid != c.valid {
+				t.Fatalf("got valid = %t, want %t (err = %v)", valid, c.valid, err)
+			}
+			if c.valid && !got.Equal(&key.PublicKey) {
+				t.Errorf("Parsed key doesn't match")
+			}
+		})
+	}
+}
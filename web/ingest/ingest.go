@@ -0,0 +1,256 @@
+// Package ingest decodes, checks, and saves the measurements in Pub/Sub messages from IoT Core.
+// It's shared by the web app's push handler and the pull-subscriber worker.
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	mpb "github.com/mtraver/environmental-sensor/measurementpb"
+	mpbutil "github.com/mtraver/environmental-sensor/measurementpbutil"
+	"github.com/mtraver/environmental-sensor/web/db"
+	"github.com/mtraver/gaelog"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Policies for measurements that aren't signed by the device that took them.
+const (
+	// Unsigned measurements are saved just as signed ones are.
+	AcceptUnsigned = "accept"
+
+	// Unsigned measurements are dropped.
+	RejectUnsigned = "reject"
+)
+
+// ErrKeyLookup is wrapped by errors that occur while looking up a device's public keys.
+// Unlike a bad signature, such an error may be temporary.
+var ErrKeyLookup = errors.New("ingest: failed to look up device keys")
+
+// Quarantine keeps rejected messages so that they can be replayed.
+type Quarantine interface {
+	Add(ctx context.Context, q db.QuarantinedMessage) error
+}
+
+// Pipeline ingests Pub/Sub messages.
+type Pipeline struct {
+	// Where measurements are saved. Each measurement is saved to every output.
+	Outputs []*Output
+
+	// The public keys against which signed measurements are verified.
+	Keys KeyRegistry
+
+	// What to do with measurements that aren't signed. One of AcceptUnsigned and RejectUnsigned.
+	UnsignedPolicy string
+
+	// The project and registry from which messages are expected. Messages that IoT Core says
+	// came from elsewhere are rejected.
+	ProjectID  string
+	RegistryID string
+
+	// If true, measurements with no device ID are given the ID of the device that published them.
+	FillDeviceID bool
+
+	// If non-nil, rejected messages are kept here so that they can be replayed.
+	Quarantine Quarantine
+}
+
+// Handle ingests a Pub/Sub message, quarantining it if it's rejected. It returns an error if the
+// message should be re-tried, in which case it's not quarantined. Rejected messages aren't
+// re-tried because that won't help.
+func (p Pipeline) Handle(ctx context.Context, id string, attributes map[string]string, data []byte) error {
+	reasons, err := p.Ingest(ctx, attributes, data)
+	if err != nil {
+		return err
+	}
+
+	if len(reasons) == 0 {
+		return nil
+	}
+
+	reason := strings.Join(reasons, "; ")
+	gaelog.Criticalf(ctx, "Rejected message %s: %s", id, reason)
+	if p.Quarantine == nil {
+		return nil
+	}
+
+	if err := p.Quarantine.Add(ctx, db.NewQuarantinedMessage(id, reason, data, attributes)); err != nil {
+		// Rather than lose the message, have it re-tried.
+		return fmt.Errorf("ingest: failed to quarantine message %s: %v", id, err)
+	}
+	return nil
+}
+
+// Ingest decodes, checks, and saves the measurements in the data of a Pub/Sub message. It
+// returns the reasons for rejecting the message or any of its measurements, which re-trying
+// won't change. An invalid measurement in a batch doesn't stop the others from being saved.
+// It returns an error if the message should be re-tried, e.g. because a save failed.
+func (p Pipeline) Ingest(ctx context.Context, attributes map[string]string, data []byte) ([]string, error) {
+	if err := p.checkRegistry(attributes); err != nil {
+		return []string{err.Error()}, nil
+	}
+
+	var measurements []*mpb.Measurement
+	var err error
+	if attributes["subFolder"] == mpbutil.SignedSubfolder {
+		measurements, err = verifyPayload(ctx, p.Keys, attributes, data)
+		if errors.Is(err, ErrKeyLookup) {
+			return nil, err
+		} else if err != nil {
+			return []string{fmt.Sprintf("bad signed measurements: %v", err)}, nil
+		}
+	} else {
+		measurements, err = decodePayload(attributes, data)
+		if err != nil {
+			return []string{fmt.Sprintf("failed to unmarshal measurements: %v", err)}, nil
+		}
+
+		if p.UnsignedPolicy == RejectUnsigned {
+			return []string{fmt.Sprintf("%d measurements aren't signed", len(measurements))}, nil
+		}
+	}
+
+	var reasons []string
+	for _, m := range measurements {
+		if err := p.checkDevice(attributes, m); err != nil {
+			reasons = append(reasons, err.Error())
+			continue
+		}
+
+		if err := mpbutil.Validate(m); err != nil {
+			reasons = append(reasons, err.Error())
+			continue
+		}
+
+		if err := p.save(ctx, m); err != nil {
+			return nil, err
+		}
+	}
+
+	return reasons, nil
+}
+
+// save saves the measurement to each output. A failing output doesn't stop the measurement
+// from being saved to the others, but the message is re-tried if a required output failed.
+func (p Pipeline) save(ctx context.Context, m *mpb.Measurement) error {
+	return saveAll(ctx, p.Outputs, m)
+}
+
+// checkRegistry checks that a message that came through IoT Core came from the expected
+// project and registry. IoT Core sets the message's attributes itself, so unlike the
+// payload they can't be forged by a device. Messages without the attributes, e.g. those
+// published straight to Pub/Sub, pass.
+func (p Pipeline) checkRegistry(attributes map[string]string) error {
+	if project, ok := attributes["projectId"]; ok && p.ProjectID != "" && project != p.ProjectID {
+		return fmt.Errorf("message is from project %q, want %q", project, p.ProjectID)
+	}
+	if registry, ok := attributes["deviceRegistryId"]; ok && p.RegistryID != "" && registry != p.RegistryID {
+		return fmt.Errorf("message is from registry %q, want %q", registry, p.RegistryID)
+	}
+	return nil
+}
+
+// checkDevice checks that the measurement is from the device that IoT Core says published
+// it, so that one device can't write data as another. If the measurement has no device ID
+// and p.FillDeviceID is set then it's given the publishing device's ID.
+func (p Pipeline) checkDevice(attributes map[string]string, m *mpb.Measurement) error {
+	publisher, ok := attributes["deviceId"]
+	if !ok {
+		return nil
+	}
+
+	if m.GetDeviceId() == "" && p.FillDeviceID {
+		m.DeviceId = publisher
+	}
+
+	if m.GetDeviceId() != publisher {
+		return fmt.Errorf("device %q published a measurement from device %q", publisher, m.GetDeviceId())
+	}
+	return nil
+}
+
+// Pub/Sub messages may say how their data is encoded in this attribute. Data is binary
+// protobuf by default.
+const (
+	contentTypeAttribute = "contentType"
+	protoContentType     = "application/x-protobuf"
+	jsonContentType      = "application/json"
+)
+
+// unmarshal unmarshals the data of a Pub/Sub message into m according to the message's
+// content type, either binary protobuf or the protobuf JSON encoding.
+func unmarshal(attributes map[string]string, data []byte, m proto.Message) error {
+	switch ct := attributes[contentTypeAttribute]; ct {
+	case "", protoContentType:
+		return proto.Unmarshal(data, m)
+	case jsonContentType:
+		return protojson.Unmarshal(data, m)
+	default:
+		return fmt.Errorf("unsupported content type %q", ct)
+	}
+}
+
+// decodePayload unmarshals the data of a Pub/Sub message from IoT Core. Batches of measurements
+// are published to a subfolder of the telemetry topic, which IoT Core passes on in the
+// subFolder attribute. Anything else is a single measurement.
+func decodePayload(attributes map[string]string, data []byte) ([]*mpb.Measurement, error) {
+	if attributes["subFolder"] == mpbutil.BatchSubfolder {
+		batch := &mpb.MeasurementBatch{}
+		if err := unmarshal(attributes, data, batch); err != nil {
+			return nil, err
+		}
+		return batch.GetMeasurements(), nil
+	}
+
+	m := &mpb.Measurement{}
+	if err := unmarshal(attributes, data, m); err != nil {
+		return nil, err
+	}
+	return []*mpb.Measurement{m}, nil
+}
+
+// verifyPayload unmarshals a SignedBatch, verifies that it was signed by the device that took
+// its measurements, and returns the measurements. All of the measurements must be from the same
+// device, which must be the device that published the message if IoT Core says which that was.
+// If the device's keys can't be looked up then the error wraps ErrKeyLookup.
+func verifyPayload(ctx context.Context, keys KeyRegistry, attributes map[string]string, data []byte) ([]*mpb.Measurement, error) {
+	sb := &mpb.SignedBatch{}
+	if err := unmarshal(attributes, data, sb); err != nil {
+		return nil, err
+	}
+
+	// The batch is unmarshaled before it's verified only to find which device's keys to use.
+	batch := &mpb.MeasurementBatch{}
+	if err := proto.Unmarshal(sb.GetBatch(), batch); err != nil {
+		return nil, err
+	}
+	if len(batch.GetMeasurements()) == 0 {
+		return nil, nil
+	}
+
+	deviceID := batch.GetMeasurements()[0].GetDeviceId()
+	for _, m := range batch.GetMeasurements() {
+		if m.GetDeviceId() != deviceID {
+			return nil, fmt.Errorf("batch has measurements from devices %q and %q", deviceID, m.GetDeviceId())
+		}
+	}
+	if publisher, ok := attributes["deviceId"]; ok && publisher != deviceID {
+		return nil, fmt.Errorf("device %q published measurements from device %q", publisher, deviceID)
+	}
+
+	if keys == nil {
+		return nil, errors.New("no key registry to verify signed measurements against")
+	}
+	pubKeys, err := keys.PublicKeys(ctx, deviceID)
+	if err != nil {
+		return nil, fmt.Errorf("%w for device %q: %v", ErrKeyLookup, deviceID, err)
+	}
+
+	verified, err := mpbutil.Verify(sb, pubKeys)
+	if err != nil {
+		return nil, fmt.Errorf("measurements from device %q: %v", deviceID, err)
+	}
+	return verified.GetMeasurements(), nil
+}
@@ -0,0 +1,299 @@
+package ingest
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	mpb "github.com/mtraver/environmental-sensor/measurementpb"
+	mpbutil "github.com/mtraver/environmental-sensor/measurementpbutil"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/testing/protocmp"
+	tspb "google.golang.org/protobuf/types/known/timestamppb"
+	wpb "google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestDecodePayload(t *testing.T) {
+	m1 := &mpb.Measurement{
+		DeviceId:  "foo",
+		Timestamp: tspb.New(time.Date(2018, time.March, 25, 0, 0, 0, 0, time.UTC)),
+		Temp:      wpb.Float(18.5),
+	}
+	m2 := &mpb.Measurement{
+		DeviceId:  "foo",
+		Timestamp: tspb.New(time.Date(2018, time.March, 25, 0, 1, 0, 0, time.UTC)),
+		Temp:      wpb.Float(18.25),
+	}
+
+	single, err := proto.Marshal(m1)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	batch, err := proto.Marshal(&mpb.MeasurementBatch{Measurements: []*mpb.Measurement{m1, m2}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	singleJSON, err := protojson.Marshal(m1)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	batchJSON, err := protojson.Marshal(&mpb.MeasurementBatch{Measurements: []*mpb.Measurement{m1, m2}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	jsonAttrs := map[string]string{"contentType": "application/json"}
+	jsonBatchAttrs := map[string]string{"contentType": "application/json", "subFolder": "batch"}
+
+	cases := []struct {
+		name       string
+		attributes map[string]string
+		data       []byte
+		want       []*mpb.Measurement
+		valid      bool
+	}{
+		{"single", map[string]string{"deviceId": "foo"}, single, []*mpb.Measurement{m1}, true},
+		{"batch", map[string]string{"deviceId": "foo", "subFolder": "batch"}, batch, []*mpb.Measurement{m1, m2}, true},
+		{"empty_batch", map[string]string{"subFolder": "batch"}, nil, nil, true},
+		{"bad_data", nil, []byte("not a proto"), nil, false},
+		{"explicit_proto", map[string]string{"contentType": "application/x-protobuf"}, single, []*mpb.Measurement{m1}, true},
+		{"json", jsonAttrs, singleJSON, []*mpb.Measurement{m1}, true},
+		{"json_batch", jsonBatchAttrs, batchJSON, []*mpb.Measurement{m1, m2}, true},
+		{"json_handwritten", jsonAttrs, []byte(`{"deviceId": "foo", "timestamp": "2018-03-25T00:00:00Z", "temp": 18.5}`), []*mpb.Measurement{m1}, true},
+		{"json_unknown_field", jsonAttrs, []byte(`{"deviceId": "foo", "humidity": 40}`), nil, false},
+		{"json_bad_data", jsonAttrs, []byte("not json"), nil, false},
+		{"json_as_proto", nil, singleJSON, nil, false},
+		{"unknown_content_type", map[string]string{"contentType": "text/csv"}, single, nil, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := decodePayload(c.attributes, c.data)
+			if valid := err == nil; valid != c.valid {
+				t.Fatalf("got valid = %t, want %t (err = %v)", valid, c.valid, err)
+			}
+
+			if diff := cmp.Diff(got, c.want, protocmp.Transform()); diff != "" {
+				t.Errorf("Unexpected result (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+// staticKeys is a KeyRegistry with a fixed set of keys for each device.
+type staticKeys map[string][]*ecdsa.PublicKey
+
+func (k staticKeys) PublicKeys(ctx context.Context, deviceID string) ([]*ecdsa.PublicKey, error) {
+	if deviceID == "unreachable" {
+		return nil, errors.New("registry unreachable")
+	}
+	return k[deviceID], nil
+}
+
+func TestVerifyPayload(t *testing.T) {
+	fooKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	barKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	keys := staticKeys{
+		"foo": {&fooKey.PublicKey},
+		"bar": {&barKey.PublicKey},
+	}
+
+	measurement := func(deviceID string) *mpb.Measurement {
+		return &mpb.Measurement{
+			DeviceId:  deviceID,
+			Timestamp: tspb.New(time.Date(2018, time.March, 25, 0, 0, 0, 0, time.UTC)),
+			Temp:      wpb.Float(18.5),
+		}
+	}
+	signed := func(key *ecdsa.PrivateKey, ms ...*mpb.Measurement) []byte {
+		sb, err := mpbutil.Sign(ms, key)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		b, err := proto.Marshal(sb)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return b
+	}
+
+	cases := []struct {
+		name       string
+		attributes map[string]string
+		data       []byte
+		want       []*mpb.Measurement
+		valid      bool
+		lookupErr  bool
+	}{
+		{"valid", map[string]string{"deviceId": "foo"}, signed(fooKey, measurement("foo"), measurement("foo")),
+			[]*mpb.Measurement{measurement("foo"), measurement("foo")}, true, false},
+		{"no_device_attribute", nil, signed(fooKey, measurement("foo")), []*mpb.Measurement{measurement("foo")}, true, false},
+		{"empty", nil, signed(fooKey), nil, true, false},
+		{"wrong_key", nil, signed(barKey, measurement("foo")), nil, false, false},
+		{"spoofed_device", map[string]string{"deviceId": "bar"}, signed(fooKey, measurement("foo")), nil, false, false},
+		{"mixed_devices", nil, signed(fooKey, measurement("foo"), measurement("bar")), nil, false, false},
+		{"unknown_device", nil, signed(fooKey, measurement("baz")), nil, false, false},
+		{"lookup_failed", nil, signed(fooKey, measurement("unreachable")), nil, false, true},
+		{"bad_data", nil, []byte("not a proto"), nil, false, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := verifyPayload(context.Background(), keys, c.attributes, c.data)
+			if valid := err == nil; valid != c.valid {
+				t.Fatalf("got valid = %t, want %t (err = %v)", valid, c.valid, err)
+			}
+			if lookupErr := errors.Is(err, ErrKeyLookup); lookupErr != c.lookupErr {
+				t.Errorf("got key lookup error = %t, want %t (err = %v)", lookupErr, c.lookupErr, err)
+			}
+
+			if diff := cmp.Diff(got, c.want, protocmp.Transform()); diff != "" {
+				t.Errorf("Unexpected result (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCheckRegistry(t *testing.T) {
+	h := Pipeline{ProjectID: "proj", RegistryID: "reg"}
+
+	cases := []struct {
+		name       string
+		attributes map[string]string
+		valid      bool
+	}{
+		{"match", map[string]string{"projectId": "proj", "deviceRegistryId": "reg"}, true},
+		{"no_attributes", nil, true},
+		{"wrong_project", map[string]string{"projectId": "other", "deviceRegistryId": "reg"}, false},
+		{"wrong_registry", map[string]string{"projectId": "proj", "deviceRegistryId": "other"}, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := h.checkRegistry(c.attributes)
+			if valid := err == nil; valid != c.valid {
+				t.Errorf("got valid = %t, want %t (err = %v)", valid, c.valid, err)
+			}
+		})
+	}
+}
+
+func TestCheckDevice(t *testing.T) {
+	cases := []struct {
+		name       string
+		fill       bool
+		attributes map[string]string
+		deviceID   string
+		want       string
+		valid      bool
+	}{
+		{"match", false, map[string]string{"deviceId": "foo"}, "foo", "foo", true},
+		{"no_attribute", false, nil, "foo", "foo", true},
+		{"mismatch", false, map[string]string{"deviceId": "bar"}, "foo", "foo", false},
+		{"mismatch_fill", true, map[string]string{"deviceId": "bar"}, "foo", "foo", false},
+		{"empty", false, map[string]string{"deviceId": "foo"}, "", "", false},
+		{"empty_fill", true, map[string]string{"deviceId": "foo"}, "", "foo", true},
+		{"empty_fill_no_attribute", true, nil, "", "", true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			h := Pipeline{FillDeviceID: c.fill}
+			m := &mpb.Measurement{DeviceId: c.deviceID}
+			err := h.checkDevice(c.attributes, m)
+			if valid := err == nil; valid != c.valid {
+				t.Errorf("got valid = %t, want %t (err = %v)", valid, c.valid, err)
+			}
+			if m.GetDeviceId() != c.want {
+				t.Errorf("got device ID %q, want %q", m.GetDeviceId(), c.want)
+			}
+		})
+	}
+}
+
+// saveDatabase is a Sink that only records saved measurements, or fails to save them if err is set.
+type saveDatabase struct {
+	saved []*mpb.Measurement
+	err   error
+}
+
+func (d *saveDatabase) Save(ctx context.Context, m *mpb.Measurement) error {
+	if d.err != nil {
+		return d.err
+	}
+	d.saved = append(d.saved, m)
+	return nil
+}
+
+func mustMarshal(t *testing.T, m proto.Message) []byte {
+	t.Helper()
+	b, err := proto.Marshal(m)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return b
+}
+
+func TestIngest(t *testing.T) {
+	valid := &mpb.Measurement{
+		DeviceId:  "foo",
+		Timestamp: tspb.New(time.Date(2018, time.March, 25, 0, 0, 0, 0, time.UTC)),
+		Temp:      wpb.Float(18.5),
+	}
+	invalid := &mpb.Measurement{
+		DeviceId:  "a",
+		Timestamp: tspb.New(time.Date(2018, time.March, 25, 0, 0, 0, 0, time.UTC)),
+		Temp:      wpb.Float(18.5),
+	}
+
+	cases := []struct {
+		name       string
+		saveErr    error
+		attributes map[string]string
+		data       []byte
+		saved      int
+		rejected   bool
+		retry      bool
+	}{
+		{"valid", nil, nil, mustMarshal(t, valid), 1, false, false},
+		{"invalid", nil, nil, mustMarshal(t, invalid), 0, true, false},
+		{"partly_invalid", nil, map[string]string{"subFolder": "batch"},
+			mustMarshal(t, &mpb.MeasurementBatch{Measurements: []*mpb.Measurement{valid, invalid}}), 1, true, false},
+		{"bad_data", nil, nil, []byte("not a proto"), 0, true, false},
+		{"json", nil, map[string]string{"contentType": "application/json"},
+			[]byte(`{"deviceId": "foo", "timestamp": "2018-03-25T00:00:00Z", "temp": 18.5}`), 1, false, false},
+		{"json_invalid", nil, map[string]string{"contentType": "application/json"},
+			[]byte(`{"deviceId": "a", "timestamp": "2018-03-25T00:00:00Z", "temp": 18.5}`), 0, true, false},
+		{"save_failed", errors.New("unavailable"), nil, mustMarshal(t, valid), 0, false, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			database := &saveDatabase{err: c.saveErr}
+			h := Pipeline{Outputs: []*Output{{Name: "db", Sink: database, Required: true}}}
+
+			reasons, err := h.Ingest(context.Background(), c.attributes, c.data)
+			if retry := err != nil; retry != c.retry {
+				t.Errorf("got retry = %t, want %t (err = %v)", retry, c.retry, err)
+			}
+			if rejected := len(reasons) > 0; rejected != c.rejected {
+				t.Errorf("got rejected = %t, want %t (reasons = %v)", rejected, c.rejected, reasons)
+			}
+			if len(database.saved) != c.saved {
+				t.Errorf("got %d saved, want %d", len(database.saved), c.saved)
+			}
+		})
+	}
+}
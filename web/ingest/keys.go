@@ -0,0 +1,55 @@
+package ingest
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"sync"
+	"time"
+
+	"github.com/mtraver/environmental-sensor/web/device"
+)
+
+// KeyRegistry looks up the public keys with which a device signs its measurements.
+type KeyRegistry interface {
+	PublicKeys(ctx context.Context, deviceID string) ([]*ecdsa.PublicKey, error)
+}
+
+type cachedKeys struct {
+	keys    []*ecdsa.PublicKey
+	fetched time.Time
+}
+
+// IoTCoreKeys is a KeyRegistry backed by the credentials of the devices in an IoT Core
+// registry. Each device's keys are cached for TTL so that the registry isn't queried for
+// every message, at the cost of a rotated key taking up to TTL to be trusted.
+type IoTCoreKeys struct {
+	ProjectID  string
+	RegistryID string
+	TTL        time.Duration
+
+	mu    sync.Mutex
+	cache map[string]cachedKeys
+}
+
+func (r *IoTCoreKeys) PublicKeys(ctx context.Context, deviceID string) ([]*ecdsa.PublicKey, error) {
+	r.mu.Lock()
+	c, ok := r.cache[deviceID]
+	r.mu.Unlock()
+	if ok && time.Since(c.fetched) < r.TTL {
+		return c.keys, nil
+	}
+
+	keys, err := device.GetPublicKeys(ctx, r.ProjectID, r.RegistryID, deviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cache == nil {
+		r.cache = make(map[string]cachedKeys)
+	}
+	r.cache[deviceID] = cachedKeys{keys: keys, fetched: time.Now()}
+
+	return keys, nil
+}
@@ -0,0 +1,145 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/mtraver/environmental-sensor/web/db"
+	"github.com/mtraver/gaelog"
+)
+
+// MQTTSubscriber ingests measurements published straight to an MQTT broker, e.g. Mosquitto,
+// rather than to IoT Core. Devices publish to topics that include their IDs, and the rest of
+// the topic plays the part of IoT Core's subfolder.
+type MQTTSubscriber struct {
+	Pipeline Pipeline
+
+	// The topic filter to subscribe to, e.g. "devices/+/events/#". The topic level matched
+	// by the first single-level wildcard is the device ID, and the levels matched by a
+	// trailing multi-level wildcard are the subfolder, e.g. "batch".
+	Topic string
+	QoS   byte
+
+	// How many times, and how long to wait between tries, to ingest a message that fails
+	// with an error that may be temporary. Messages that still fail are quarantined.
+	Retries    int
+	RetryDelay time.Duration
+}
+
+// topicAttributes returns the Pub/Sub-style attributes of a message published to the topic,
+// which must match the filter. The device ID is the level matched by the filter's first "+"
+// wildcard and the subfolder is the levels matched by a trailing "#" wildcard.
+func topicAttributes(filter, topic string) (map[string]string, error) {
+	filterLevels := strings.Split(filter, "/")
+	topicLevels := strings.Split(topic, "/")
+
+	attributes := make(map[string]string)
+	for i, f := range filterLevels {
+		if f == "#" {
+			if i < len(topicLevels) {
+				attributes["subFolder"] = strings.Join(topicLevels[i:], "/")
+			}
+			break
+		}
+
+		if i >= len(topicLevels) {
+			return nil, fmt.Errorf("ingest: topic %q doesn't match %q", topic, filter)
+		}
+
+		switch f {
+		case "+":
+			if _, ok := attributes["deviceId"]; !ok {
+				attributes["deviceId"] = topicLevels[i]
+			}
+		default:
+			if f != topicLevels[i] {
+				return nil, fmt.Errorf("ingest: topic %q doesn't match %q", topic, filter)
+			}
+		}
+
+		if i == len(filterLevels)-1 && len(topicLevels) > len(filterLevels) {
+			return nil, fmt.Errorf("ingest: topic %q doesn't match %q", topic, filter)
+		}
+	}
+
+	if attributes["deviceId"] == "" {
+		return nil, fmt.Errorf("ingest: topic %q has no device ID (filter %q)", topic, filter)
+	}
+	return attributes, nil
+}
+
+// Subscribe subscribes the client to the topic. It should be called from the client's
+// OnConnectHandler so that the subscription is renewed when the client reconnects.
+func (s MQTTSubscriber) Subscribe(client mqtt.Client) error {
+	waitDur := 10 * time.Second
+	token := client.Subscribe(s.Topic, s.QoS, func(client mqtt.Client, msg mqtt.Message) {
+		s.handle(context.Background(), msg)
+	})
+	if !token.WaitTimeout(waitDur) {
+		return fmt.Errorf("ingest: subscription to %s timed out after %v", s.Topic, waitDur)
+	} else if token.Error() != nil {
+		return fmt.Errorf("ingest: failed to subscribe to %s: %v", s.Topic, token.Error())
+	}
+	return nil
+}
+
+// Connect connects to the broker with the given options and subscribes to the topic, doing so
+// again whenever the client reconnects. The session is persistent so that messages published
+// while the subscriber is disconnected are delivered when it reconnects.
+func (s MQTTSubscriber) Connect(opts *mqtt.ClientOptions) (mqtt.Client, error) {
+	opts.SetCleanSession(false)
+	opts.SetAutoReconnect(true)
+	opts.SetOnConnectHandler(func(client mqtt.Client) {
+		if err := s.Subscribe(client); err != nil {
+			gaelog.Errorf(context.Background(), "%v", err)
+			return
+		}
+		gaelog.Infof(context.Background(), "Subscribed to %s", s.Topic)
+	})
+
+	client := mqtt.NewClient(opts)
+	waitDur := 10 * time.Second
+	if token := client.Connect(); !token.WaitTimeout(waitDur) {
+		return nil, fmt.Errorf("ingest: MQTT connection attempt timed out after %v", waitDur)
+	} else if token.Error() != nil {
+		return nil, fmt.Errorf("ingest: failed to connect to MQTT broker: %v", token.Error())
+	}
+
+	return client, nil
+}
+
+// handle runs the message through the pipeline. MQTT has no way to have the broker redeliver a
+// message, so one that fails with an error that may be temporary is re-tried here and then
+// quarantined so that it can be replayed.
+func (s MQTTSubscriber) handle(ctx context.Context, msg mqtt.Message) {
+	attributes, err := topicAttributes(s.Topic, msg.Topic())
+	if err != nil {
+		gaelog.Criticalf(ctx, "Dropped message: %v", err)
+		return
+	}
+
+	for try := 0; ; try++ {
+		err = s.Pipeline.Handle(ctx, "", attributes, msg.Payload())
+		if err == nil {
+			return
+		}
+		if try >= s.Retries {
+			break
+		}
+
+		gaelog.Warningf(ctx, "Failed to ingest message from %s, will re-try: %v", msg.Topic(), err)
+		time.Sleep(s.RetryDelay)
+	}
+
+	gaelog.Errorf(ctx, "Failed to ingest message from %s: %v", msg.Topic(), err)
+	if s.Pipeline.Quarantine == nil {
+		return
+	}
+	q := db.NewQuarantinedMessage("", fmt.Sprintf("failed after %d tries: %v", s.Retries+1, err), msg.Payload(), attributes)
+	if err := s.Pipeline.Quarantine.Add(ctx, q); err != nil {
+		gaelog.Errorf(ctx, "Failed to quarantine message from %s: %v", msg.Topic(), err)
+	}
+}
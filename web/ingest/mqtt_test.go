@@ -0,0 +1,134 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	mpb "github.com/mtraver/environmental-sensor/measurementpb"
+	"github.com/mtraver/environmental-sensor/web/db"
+	tspb "google.golang.org/protobuf/types/known/timestamppb"
+	wpb "google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// fakeMessage is an mqtt.Message.
+type fakeMessage struct {
+	topic   string
+	payload []byte
+}
+
+func (m fakeMessage) Duplicate() bool   { return false }
+func (m fakeMessage) Qos() byte         { return 1 }
+func (m fakeMessage) Retained() bool    { return false }
+func (m fakeMessage) Topic() string     { return m.topic }
+func (m fakeMessage) MessageID() uint16 { return 1 }
+func (m fakeMessage) Payload() []byte   { return m.payload }
+func (m fakeMessage) Ack()              {}
+
+// memQuarantine is a Quarantine that keeps messages in memory.
+type memQuarantine struct {
+	msgs []db.QuarantinedMessage
+}
+
+func (q *memQuarantine) Add(ctx context.Context, m db.QuarantinedMessage) error {
+	q.msgs = append(q.msgs, m)
+	return nil
+}
+
+func TestTopicAttributes(t *testing.T) {
+	cases := []struct {
+		name   string
+		filter string
+		topic  string
+		want   map[string]string
+		valid  bool
+	}{
+		{"device", "devices/+/events", "devices/foo/events", map[string]string{"deviceId": "foo"}, true},
+		{"subfolder", "devices/+/events/#", "devices/foo/events/batch", map[string]string{"deviceId": "foo", "subFolder": "batch"}, true},
+		{"nested_subfolder", "devices/+/events/#", "devices/foo/events/a/b", map[string]string{"deviceId": "foo", "subFolder": "a/b"}, true},
+		{"no_subfolder", "devices/+/events/#", "devices/foo/events", map[string]string{"deviceId": "foo"}, true},
+		{"first_wildcard", "+/+/events", "foo/bar/events", map[string]string{"deviceId": "foo"}, true},
+		{"leading_slash", "/devices/+/events", "/devices/foo/events", map[string]string{"deviceId": "foo"}, true},
+		{"too_long", "devices/+/events", "devices/foo/events/batch", nil, false},
+		{"too_short", "devices/+/events", "devices/foo", nil, false},
+		{"mismatch", "devices/+/events", "devices/foo/state", nil, false},
+		{"no_device", "devices/events/#", "devices/events/batch", nil, false},
+		{"empty_device", "devices/+/events", "devices//events", nil, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := topicAttributes(c.filter, c.topic)
+			if valid := err == nil; valid != c.valid {
+				t.Fatalf("got valid = %t, want %t (err = %v)", valid, c.valid, err)
+			}
+
+			if diff := cmp.Diff(got, c.want); diff != "" {
+				t.Errorf("Unexpected result (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMQTTSubscriberHandle(t *testing.T) {
+	m := &mpb.Measurement{
+		DeviceId:  "foo",
+		Timestamp: tspb.New(time.Date(2018, time.March, 25, 0, 0, 0, 0, time.UTC)),
+		Temp:      wpb.Float(18.5),
+	}
+	batch := mustMarshal(t, &mpb.MeasurementBatch{Measurements: []*mpb.Measurement{m, m}})
+
+	cases := []struct {
+		name        string
+		failures    int
+		topic       string
+		payload     []byte
+		saved       int
+		quarantined int
+	}{
+		{"single", 0, "devices/foo/events", mustMarshal(t, m), 1, 0},
+		{"batch", 0, "devices/foo/events/batch", batch, 2, 0},
+		{"wrong_device", 0, "devices/bar/events", mustMarshal(t, m), 0, 1},
+		{"bad_topic", 0, "devices/foo/state", mustMarshal(t, m), 0, 0},
+		{"retried", 2, "devices/foo/events", mustMarshal(t, m), 1, 0},
+		{"gave_up", 3, "devices/foo/events", mustMarshal(t, m), 0, 1},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			database := &flakySaveDatabase{failures: c.failures}
+			quarantine := &memQuarantine{}
+			s := MQTTSubscriber{
+				Pipeline: Pipeline{Outputs: []*Output{{Name: "db", Sink: database, Required: true}}, Quarantine: quarantine},
+				Topic:    "devices/+/events/#",
+				Retries:  2,
+			}
+
+			s.handle(context.Background(), fakeMessage{c.topic, c.payload})
+
+			if len(database.saved) != c.saved {
+				t.Errorf("got %d saved, want %d", len(database.saved), c.saved)
+			}
+			if len(quarantine.msgs) != c.quarantined {
+				t.Errorf("got %d quarantined, want %d", len(quarantine.msgs), c.quarantined)
+			}
+		})
+	}
+}
+
+// flakySaveDatabase fails as many saves as its failures field says and then records the rest.
+type flakySaveDatabase struct {
+	failures int
+	saved    []*mpb.Measurement
+}
+
+func (d *flakySaveDatabase) Save(ctx context.Context, m *mpb.Measurement) error {
+	if d.failures > 0 {
+		d.failures--
+		return errors.New("unavailable")
+	}
+	d.saved = append(d.saved, m)
+	return nil
+}
@@ -0,0 +1,334 @@
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mtraver/environmental-sensor/measurement"
+	mpb "github.com/mtraver/environmental-sensor/measurementpb"
+	"github.com/mtraver/gaelog"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// Sink is somewhere measurements are saved.
+type Sink interface {
+	Save(ctx context.Context, m *mpb.Measurement) error
+}
+
+// retryableError is implemented by errors from sinks that know whether re-trying the save
+// might help and how long to wait before doing so. Other errors are always re-tried.
+type retryableError interface {
+	error
+	Temporary() bool
+
+	// How long the sink asked for the save to be put off, or zero if it didn't say.
+	RetryAfter() time.Duration
+}
+
+// SinkStats counts the saves made to a sink.
+type SinkStats struct {
+	Name     string
+	Required bool
+
+	// The number of measurements saved, the number that failed to save after every try,
+	// and the number of tries that failed and were re-tried.
+	Saved   int64
+	Failed  int64
+	Retried int64
+
+	LastError     string
+	LastErrorTime time.Time
+}
+
+// Output is a sink registered with a pipeline. Each output is tried separately, so a failing
+// sink doesn't stop measurements from being saved to the others.
+type Output struct {
+	Name string
+	Sink Sink
+
+	// If true, a measurement that can't be saved to the sink fails its message so that the
+	// message is re-tried. Other sinks are best effort: failures are only counted and logged.
+	// Re-trying a message saves its measurements to every sink again, so sinks should treat
+	// saving a measurement twice as a no-op where they can.
+	Required bool
+
+	// How many times to re-try a failed save, waiting RetryDelay before the first re-try and
+	// twice as long before each one after that, or as long as the sink says to. This is the
+	// only place that saves are re-tried, so sinks make one try per call to Save.
+	Retries    int
+	RetryDelay time.Duration
+
+	// The longest that saving a measurement may take, including re-tries. Zero means no limit.
+	// Outputs are saved to in turn, so this bounds how long a failing sink holds up a message.
+	Timeout time.Duration
+
+	mu    sync.Mutex
+	stats SinkStats
+}
+
+// NewOutput returns an Output with the default retry policy.
+func NewOutput(name string, sink Sink, required bool) *Output {
+	return &Output{
+		Name:       name,
+		Sink:       sink,
+		Required:   required,
+		Retries:    2,
+		RetryDelay: 200 * time.Millisecond,
+		Timeout:    20 * time.Second,
+	}
+}
+
+// Stats returns the output's counters.
+func (o *Output) Stats() SinkStats {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	stats := o.stats
+	stats.Name = o.Name
+	stats.Required = o.Required
+	return stats
+}
+
+// save saves the measurement to the sink, re-trying according to the output's retry policy.
+func (o *Output) save(ctx context.Context, m *mpb.Measurement) error {
+	if o.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.Timeout)
+		defer cancel()
+	}
+
+	delay := o.RetryDelay
+	for try := 0; ; try++ {
+		err := o.Sink.Save(ctx, m)
+		if err == nil {
+			o.mu.Lock()
+			o.stats.Saved++
+			o.mu.Unlock()
+			return nil
+		}
+
+		retry, wait := true, delay
+		var rerr retryableError
+		if errors.As(err, &rerr) {
+			retry = rerr.Temporary()
+			if d := rerr.RetryAfter(); d > 0 {
+				wait = d
+			}
+		}
+
+		o.mu.Lock()
+		o.stats.LastError = err.Error()
+		o.stats.LastErrorTime = time.Now()
+		if !retry || try >= o.Retries {
+			o.stats.Failed++
+			o.mu.Unlock()
+			return fmt.Errorf("failed to save measurement to %s: %v", o.Name, err)
+		}
+		o.stats.Retried++
+		o.mu.Unlock()
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return fmt.Errorf("failed to save measurement to %s: %v", o.Name, err)
+		}
+		delay *= 2
+	}
+}
+
+// saveAll saves the measurement to each of the outputs. It returns an error if it couldn't be
+// saved to a required output.
+func saveAll(ctx context.Context, outputs []*Output, m *mpb.Measurement) error {
+	var required error
+	for _, o := range outputs {
+		if err := o.save(ctx, m); err != nil {
+			if o.Required && required == nil {
+				required = err
+			} else if !o.Required {
+				gaelog.Errorf(ctx, "%v", err)
+			}
+		}
+	}
+	return required
+}
+
+// ParseOutputs parses a comma-separated list of sinks, each of the form "type" or "type=arg",
+// e.g. "!datastore,influxdb,file=/var/lib/measurements.ndjson,webhook=https://example.com/hook".
+// Sinks whose type is prefixed with "!" are required (see Output). The "file" and "webhook"
+// types are built in and other types are made with the given constructors, each of which is
+// passed the sink's argument. Outputs are named after their types, not their arguments,
+// which may hold credentials.
+func ParseOutputs(spec string, constructors map[string]func(arg string) (Sink, error)) ([]*Output, error) {
+	var outputs []*Output
+	counts := make(map[string]int)
+	for _, s := range strings.Split(spec, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+
+		required := strings.HasPrefix(s, "!")
+		s = strings.TrimPrefix(s, "!")
+
+		typ, arg := s, ""
+		if i := strings.Index(s, "="); i >= 0 {
+			typ, arg = s[:i], s[i+1:]
+		}
+
+		var sink Sink
+		var err error
+		switch typ {
+		case "file":
+			sink, err = NewFileSink(arg)
+		case "webhook":
+			sink, err = NewWebhookSink(arg)
+		default:
+			newSink, ok := constructors[typ]
+			if !ok {
+				return nil, fmt.Errorf("ingest: unknown sink type %q", typ)
+			}
+			sink, err = newSink(arg)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("ingest: failed to make %s sink: %v", typ, err)
+		}
+
+		name := typ
+		if counts[typ]++; counts[typ] > 1 {
+			name = fmt.Sprintf("%s#%d", typ, counts[typ])
+		}
+		outputs = append(outputs, NewOutput(name, sink, required))
+	}
+
+	if len(outputs) == 0 {
+		return nil, fmt.Errorf("ingest: no sinks given")
+	}
+	return outputs, nil
+}
+
+// measurementKey returns the key that identifies the measurement, which is the same as its
+// key in the databases, so that the receivers of sinks that can't dedupe can do it themselves.
+func measurementKey(m *mpb.Measurement) (string, error) {
+	sm, err := measurement.NewStorableMeasurement(m)
+	if err != nil {
+		return "", err
+	}
+	return sm.DBKey(), nil
+}
+
+// fileLine is a line written by FileSink.
+type fileLine struct {
+	Key         string          `json:"key"`
+	Measurement json.RawMessage `json:"measurement"`
+}
+
+// FileSink appends each measurement to a file as a line of JSON holding the measurement and
+// its key. Delivery is at least once: a measurement whose message is re-tried is appended
+// again, so readers should dedupe lines by key.
+type FileSink struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// NewFileSink returns a FileSink that appends to the file at the given path, creating it if need be.
+func NewFileSink(path string) (*FileSink, error) {
+	if path == "" {
+		return nil, fmt.Errorf("no path given")
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{f: f}, nil
+}
+
+func (s *FileSink) Save(ctx context.Context, m *mpb.Measurement) error {
+	key, err := measurementKey(m)
+	if err != nil {
+		return err
+	}
+
+	b, err := protojson.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	// Marshaling compacts the measurement's JSON, so the line is guaranteed to be one line.
+	line, err := json.Marshal(fileLine{Key: key, Measurement: b})
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.f.Write(line)
+	return err
+}
+
+// Close closes the file.
+func (s *FileSink) Close() error {
+	return s.f.Close()
+}
+
+// WebhookSink POSTs each measurement as JSON to a URL. Delivery is at least once: a
+// measurement whose message is re-tried is POSTed again, so the receiver should dedupe
+// requests by their Idempotency-Key header, which holds the measurement's key.
+type WebhookSink struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewWebhookSink returns a WebhookSink for the given URL.
+func NewWebhookSink(url string) (*WebhookSink, error) {
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return nil, fmt.Errorf("bad webhook URL %q", url)
+	}
+
+	return &WebhookSink{
+		URL:    url,
+		Client: &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+func (s *WebhookSink) Save(ctx context.Context, m *mpb.Measurement) error {
+	key, err := measurementKey(m)
+	if err != nil {
+		return err
+	}
+
+	b, err := protojson.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", s.URL, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", jsonContentType)
+	req.Header.Set("Idempotency-Key", key)
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	ioutil.ReadAll(resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,316 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	mpb "github.com/mtraver/environmental-sensor/measurementpb"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	tspb "google.golang.org/protobuf/types/known/timestamppb"
+	wpb "google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+var sinkMeasurement = &mpb.Measurement{
+	DeviceId:  "foo",
+	Timestamp: tspb.New(time.Date(2018, time.March, 25, 0, 0, 0, 0, time.UTC)),
+	Temp:      wpb.Float(18.5),
+}
+
+// The key of sinkMeasurement, as in the databases.
+const sinkMeasurementKey = "foo#2018-03-25T00:00:00Z"
+
+func TestOutputSave(t *testing.T) {
+	cases := []struct {
+		name     string
+		failures int
+		retries  int
+		wantErr  bool
+		want     SinkStats
+	}{
+		{"ok", 0, 2, false, SinkStats{Name: "db", Saved: 1}},
+		{"retried", 2, 2, false, SinkStats{Name: "db", Saved: 1, Retried: 2, LastError: "unavailable"}},
+		{"failed", 3, 2, true, SinkStats{Name: "db", Failed: 1, Retried: 2, LastError: "unavailable"}},
+		{"no_retries", 1, 0, true, SinkStats{Name: "db", Failed: 1, LastError: "unavailable"}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			database := &flakySaveDatabase{failures: c.failures}
+			o := &Output{Name: "db", Sink: database, Retries: c.retries, RetryDelay: time.Millisecond}
+
+			err := o.save(context.Background(), sinkMeasurement)
+			if gotErr := err != nil; gotErr != c.wantErr {
+				t.Errorf("got err = %v, want error: %t", err, c.wantErr)
+			}
+
+			if diff := cmp.Diff(o.Stats(), c.want, cmpopts.IgnoreFields(SinkStats{}, "LastErrorTime")); diff != "" {
+				t.Errorf("Unexpected result (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+// retryableSaveError is an error that says whether re-trying might help and how long to wait.
+type retryableSaveError struct {
+	temporary bool
+	wait      time.Duration
+}
+
+func (e retryableSaveError) Error() string             { return "unavailable" }
+func (e retryableSaveError) Temporary() bool           { return e.temporary }
+func (e retryableSaveError) RetryAfter() time.Duration { return e.wait }
+
+// errSaveDatabase fails every save with its error and counts the tries.
+type errSaveDatabase struct {
+	err   error
+	tries int
+}
+
+func (d *errSaveDatabase) Save(ctx context.Context, m *mpb.Measurement) error {
+	d.tries++
+	return d.err
+}
+
+func TestOutputSaveRetryableError(t *testing.T) {
+	cases := []struct {
+		name      string
+		err       error
+		timeout   time.Duration
+		wantTries int
+	}{
+		{"permanent", retryableSaveError{temporary: false}, 0, 1},
+		{"temporary", retryableSaveError{temporary: true}, 0, 3},
+		// The sink asks for longer than the output may take, so it gives up when it times out.
+		{"retry_after", retryableSaveError{temporary: true, wait: time.Hour}, 50 * time.Millisecond, 1},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			database := &errSaveDatabase{err: c.err}
+			o := &Output{Name: "db", Sink: database, Retries: 2, RetryDelay: time.Millisecond, Timeout: c.timeout}
+
+			start := time.Now()
+			if err := o.save(context.Background(), sinkMeasurement); err == nil {
+				t.Errorf("Expected error")
+			}
+			if database.tries != c.wantTries {
+				t.Errorf("got %d tries, want %d", database.tries, c.wantTries)
+			}
+			if d := time.Since(start); d > 10*time.Second {
+				t.Errorf("Save took %v", d)
+			}
+		})
+	}
+}
+
+func TestSaveAllIsolatesSinks(t *testing.T) {
+	cases := []struct {
+		name         string
+		failRequired bool
+		failOptional bool
+		wantErr      bool
+		wantRequired int
+		wantOptional int
+	}{
+		{"all_ok", false, false, false, 1, 1},
+		{"optional_failed", false, true, false, 1, 0},
+		{"required_failed", true, false, true, 0, 1},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			required := &saveDatabase{}
+			if c.failRequired {
+				required.err = errors.New("unavailable")
+			}
+			optional := &saveDatabase{}
+			if c.failOptional {
+				optional.err = errors.New("unavailable")
+			}
+
+			// The failing sink comes first to check that it doesn't stop the other from being saved to.
+			outputs := []*Output{
+				{Name: "optional", Sink: optional},
+				{Name: "required", Sink: required, Required: true},
+			}
+			if c.failRequired {
+				outputs[0], outputs[1] = outputs[1], outputs[0]
+			}
+
+			err := saveAll(context.Background(), outputs, sinkMeasurement)
+			if gotErr := err != nil; gotErr != c.wantErr {
+				t.Errorf("got err = %v, want error: %t", err, c.wantErr)
+			}
+			if len(required.saved) != c.wantRequired {
+				t.Errorf("got %d saved to required sink, want %d", len(required.saved), c.wantRequired)
+			}
+			if len(optional.saved) != c.wantOptional {
+				t.Errorf("got %d saved to optional sink, want %d", len(optional.saved), c.wantOptional)
+			}
+		})
+	}
+}
+
+func TestParseOutputs(t *testing.T) {
+	dir := t.TempDir()
+	constructors := map[string]func(string) (Sink, error){
+		"memory": func(string) (Sink, error) {
+			return &saveDatabase{}, nil
+		},
+	}
+
+	type output struct {
+		Name     string
+		Required bool
+	}
+
+	cases := []struct {
+		name  string
+		spec  string
+		want  []output
+		valid bool
+	}{
+		{"one", "memory", []output{{"memory", false}}, true},
+		{"required", "!memory", []output{{"memory", true}}, true},
+		{"several", " !memory, file=" + filepath.Join(dir, "m.ndjson") + ",webhook=http://localhost/hook",
+			[]output{{"memory", true}, {"file", false}, {"webhook", false}}, true},
+		{"repeated", "webhook=http://a/,webhook=http://b/", []output{{"webhook", false}, {"webhook#2", false}}, true},
+		{"empty", "", nil, false},
+		{"unknown", "memory,nope", nil, false},
+		{"file_no_path", "file", nil, false},
+		{"bad_webhook_url", "webhook=localhost", nil, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			outputs, err := ParseOutputs(c.spec, constructors)
+			if valid := err == nil; valid != c.valid {
+				t.Fatalf("got valid = %t, want %t (err = %v)", valid, c.valid, err)
+			}
+
+			var got []output
+			for _, o := range outputs {
+				got = append(got, output{o.Name, o.Required})
+			}
+			if diff := cmp.Diff(got, c.want); diff != "" {
+				t.Errorf("Unexpected result (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "measurements.ndjson")
+	s, err := NewFileSink(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := s.Save(context.Background(), sinkMeasurement); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2:\n%s", len(lines), b)
+	}
+	for _, line := range lines {
+		var fl fileLine
+		if err := json.Unmarshal([]byte(line), &fl); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if fl.Key != sinkMeasurementKey {
+			t.Errorf("got key %q, want %q", fl.Key, sinkMeasurementKey)
+		}
+
+		var m mpb.Measurement
+		if err := protojson.Unmarshal(fl.Measurement, &m); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !proto.Equal(&m, sinkMeasurement) {
+			t.Errorf("got %v, want %v", &m, sinkMeasurement)
+		}
+	}
+}
+
+func TestSinkBadTimestamp(t *testing.T) {
+	s, err := NewFileSink(filepath.Join(t.TempDir(), "measurements.ndjson"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer s.Close()
+
+	// A measurement without a timestamp has no key.
+	if err := s.Save(context.Background(), &mpb.Measurement{DeviceId: "foo"}); err == nil {
+		t.Errorf("Expected error, got nil")
+	}
+}
+
+func TestWebhookSink(t *testing.T) {
+	cases := []struct {
+		name   string
+		status int
+		valid  bool
+	}{
+		{"ok", http.StatusOK, true},
+		{"no_content", http.StatusNoContent, true},
+		{"server_error", http.StatusInternalServerError, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var got mpb.Measurement
+			var contentType, key string
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				contentType = r.Header.Get("Content-Type")
+				key = r.Header.Get("Idempotency-Key")
+				b, _ := ioutil.ReadAll(r.Body)
+				if err := protojson.Unmarshal(b, &got); err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				w.WriteHeader(c.status)
+			}))
+			defer ts.Close()
+
+			s, err := NewWebhookSink(ts.URL)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			err = s.Save(context.Background(), sinkMeasurement)
+			if valid := err == nil; valid != c.valid {
+				t.Errorf("got valid = %t, want %t (err = %v)", valid, c.valid, err)
+			}
+			if contentType != jsonContentType {
+				t.Errorf("got Content-Type %q, want %q", contentType, jsonContentType)
+			}
+			if key != sinkMeasurementKey {
+				t.Errorf("got Idempotency-Key %q, want %q", key, sinkMeasurementKey)
+			}
+			if !proto.Equal(&got, sinkMeasurement) {
+				t.Errorf("got %v, want %v", &got, sinkMeasurement)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"html/template"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/mtraver/gaelog"
+
+	"github.com/mtraver/environmental-sensor/measurement"
+)
+
+// seqRange is an inclusive range of sequence numbers.
+type seqRange struct {
+	First int64
+	Last  int64
+}
+
+func (r seqRange) Len() int64 {
+	return r.Last - r.First + 1
+}
+
+// bootLoss describes the measurements received from one boot of a device. Measurements
+// before the first or after the last received aren't known to have been sent, so they're
+// not counted as lost.
+type bootLoss struct {
+	BootID string
+	// The timestamps of the first and last measurements received.
+	Start time.Time
+	End   time.Time
+
+	Received []seqRange
+	Missing  []seqRange
+}
+
+// Expected returns the number of measurements from the first received to the last.
+func (b bootLoss) Expected() int64 {
+	if len(b.Received) == 0 {
+		return 0
+	}
+	return b.Received[len(b.Received)-1].Last - b.Received[0].First + 1
+}
+
+func (b bootLoss) Lost() int64 {
+	var n int64
+	for _, r := range b.Missing {
+		n += r.Len()
+	}
+	return n
+}
+
+// deviceLoss describes the measurements received from a device, boot by boot.
+type deviceLoss struct {
+	DeviceID string
+	Boots    []bootLoss
+	// The number of measurements that predate sequence numbers.
+	Unnumbered int
+}
+
+func (d deviceLoss) Expected() int64 {
+	var n int64
+	for _, b := range d.Boots {
+		n += b.Expected()
+	}
+	return n
+}
+
+func (d deviceLoss) Lost() int64 {
+	var n int64
+	for _, b := range d.Boots {
+		n += b.Lost()
+	}
+	return n
+}
+
+// LossRate returns the fraction of expected measurements that were lost.
+func (d deviceLoss) LossRate() float64 {
+	if d.Expected() == 0 {
+		return 0
+	}
+	return float64(d.Lost()) / float64(d.Expected())
+}
+
+// lossReport works out which measurements were lost from each device from the sequence numbers
+// of those that were received. Duplicates, which Pub/Sub may deliver, are ignored. Devices are
+// sorted by ID and their boots by the time of their first measurement.
+func lossReport(measurements map[string][]measurement.StorableMeasurement) []deviceLoss {
+	var report []deviceLoss
+	for deviceID, ms := range measurements {
+		d := deviceLoss{DeviceID: deviceID}
+
+		byBoot := make(map[string][]measurement.StorableMeasurement)
+		for _, m := range ms {
+			if m.BootID == "" {
+				d.Unnumbered++
+				continue
+			}
+			byBoot[m.BootID] = append(byBoot[m.BootID], m)
+		}
+
+		for bootID, bms := range byBoot {
+			sort.Slice(bms, func(i, j int) bool {
+				return bms[i].Sequence < bms[j].Sequence
+			})
+
+			b := bootLoss{BootID: bootID, Start: bms[0].Timestamp, End: bms[0].Timestamp}
+			for _, m := range bms {
+				if m.Timestamp.Before(b.Start) {
+					b.Start = m.Timestamp
+				}
+				if m.Timestamp.After(b.End) {
+					b.End = m.Timestamp
+				}
+
+				if n := len(b.Received); n == 0 {
+					b.Received = append(b.Received, seqRange{m.Sequence, m.Sequence})
+				} else if last := &b.Received[n-1]; m.Sequence <= last.Last+1 {
+					// Contiguous or a duplicate.
+					if m.Sequence > last.Last {
+						last.Last = m.Sequence
+					}
+				} else {
+					b.Missing = append(b.Missing, seqRange{last.Last + 1, m.Sequence - 1})
+					b.Received = append(b.Received, seqRange{m.Sequence, m.Sequence})
+				}
+			}
+
+			d.Boots = append(d.Boots, b)
+		}
+
+		sort.Slice(d.Boots, func(i, j int) bool {
+			return d.Boots[i].Start.Before(d.Boots[j].Start)
+		})
+		report = append(report, d)
+	}
+
+	sort.Slice(report, func(i, j int) bool {
+		return report[i].DeviceID < report[j].DeviceID
+	})
+	return report
+}
+
+// losszHandler renders a page displaying the measurements lost from each device. app.yaml
+// limits the page to the project's admins.
+type losszHandler struct {
+	// Account for measurements up to this duration old.
+	Dur      time.Duration
+	Database Database
+	Template *template.Template
+}
+
+func (h losszHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := newContext(r)
+
+	endTime := time.Now().UTC()
+	startTime := endTime.Add(-h.Dur)
+
+	measurements, err := h.Database.Between(ctx, startTime, endTime)
+	if err != nil {
+		gaelog.Errorf(ctx, "Error fetching data: %v", err)
+	}
+
+	data := struct {
+		Dur     time.Duration
+		Devices []deviceLoss
+		Error   error
+	}{
+		Dur:     h.Dur,
+		Devices: lossReport(measurements),
+		Error:   err,
+	}
+
+	if err := h.Template.ExecuteTemplate(w, "lossz", data); err != nil {
+		gaelog.Errorf(ctx, "Could not execute template: %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/mtraver/environmental-sensor/measurement"
+	mpb "github.com/mtraver/environmental-sensor/measurementpb"
+)
+
+func TestLossReport(t *testing.T) {
+	ts := func(min int) time.Time {
+		return time.Date(2018, time.March, 25, 0, min, 0, 0, time.UTC)
+	}
+	numbered := func(bootID string, seq int64, min int) measurement.StorableMeasurement {
+		return measurement.StorableMeasurement{DeviceID: "foo", BootID: bootID, Sequence: seq, Timestamp: ts(min)}
+	}
+
+	measurements := map[string][]measurement.StorableMeasurement{
+		"foo": {
+			numbered("boot-2", 10, 20),
+			numbered("boot-1", 0, 0),
+			numbered("boot-1", 1, 1),
+			numbered("boot-1", 1, 1),
+			numbered("boot-1", 4, 4),
+			numbered("boot-1", 5, 5),
+			numbered("boot-1", 9, 9),
+			numbered("boot-2", 11, 21),
+			{DeviceID: "foo", Timestamp: ts(30)},
+		},
+		"bar": {},
+	}
+
+	got := lossReport(measurements)
+	want := []deviceLoss{
+		{DeviceID: "bar"},
+		{
+			DeviceID: "foo",
+			Boots: []bootLoss{
+				{
+					BootID:   "boot-1",
+					Start:    ts(0),
+					End:      ts(9),
+					Received: []seqRange{{0, 1}, {4, 5}, {9, 9}},
+					Missing:  []seqRange{{2, 3}, {6, 8}},
+				},
+				{
+					BootID:   "boot-2",
+					Start:    ts(20),
+					End:      ts(21),
+					Received: []seqRange{{10, 11}},
+				},
+			},
+			Unnumbered: 1,
+		},
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("Unexpected result (-got +want):\n%s", diff)
+	}
+
+	foo := got[1]
+	if foo.Expected() != 12 || foo.Lost() != 5 {
+		t.Errorf("got %d lost of %d, want 5 of 12", foo.Lost(), foo.Expected())
+	}
+	if rate, want := foo.LossRate(), 5.0/12.0; rate != want {
+		t.Errorf("got loss rate %v, want %v", rate, want)
+	}
+}
+
+func TestLosszHandler(t *testing.T) {
+	numbered := func(seq uint64, ago time.Duration) *mpb.Measurement {
+		m := tempMeasurement("foo", ago, 18.5)
+		m.BootId = "boot-1"
+		m.Sequence = seq
+		return m
+	}
+
+	h := losszHandler{
+		Dur: 48 * time.Hour,
+		Database: newMemoryDB(t,
+			numbered(0, 4*time.Minute),
+			numbered(1, 3*time.Minute),
+			numbered(3, time.Minute),
+			tempMeasurement("bar", time.Hour, 12.5)),
+		Template: testTemplates,
+	}
+
+	status, body := get(t, h, "/lossz")
+	if status != http.StatusOK {
+		t.Errorf("got status %d, want %d", status, http.StatusOK)
+	}
+	for _, s := range []string{
+		"Lost Measurements (Past 48h0m0s)",
+		"foo: lost 1 of 4 (25.00%)",
+		"bar: lost 0 of 0 (0.00%)",
+		"1 measurements without sequence numbers",
+		"Missing: [2, 2]",
+	} {
+		if !strings.Contains(body, s) {
+			t.Errorf("Response doesn't contain %q", s)
+		}
+	}
+}
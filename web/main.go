@@ -2,22 +2,33 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"html/template"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
 	"time"
 
+	mqtt "github.com/eclipse/paho.mqtt.golang"
 	"github.com/mtraver/environmental-sensor/aqi"
 	"github.com/mtraver/environmental-sensor/measurement"
 	mpb "github.com/mtraver/environmental-sensor/measurementpb"
 	"github.com/mtraver/environmental-sensor/web/db"
+	"github.com/mtraver/environmental-sensor/web/ingest"
 	"github.com/mtraver/gaelog"
+	"google.golang.org/api/idtoken"
+
+	// The drivers that the SQL database can use. SQLite's is in sqlite3.go.
+	_ "github.com/lib/pq"
 )
 
 const (
 	datastoreKind = "measurement"
+
+	// The Datastore kind of quarantined messages.
+	quarantineKind = "quarantined_message"
 )
 
 type Database interface {
@@ -36,16 +47,30 @@ func mustGetenv(varName string) string {
 	return val
 }
 
-func main() {
-	projectID := mustGetenv("GOOGLE_CLOUD_PROJECT")
+// unsignedPolicy returns the policy for unsigned measurements given by the UNSIGNED_POLICY
+// environment variable. Unsigned measurements are accepted by default.
+func unsignedPolicy() string {
+	switch p := os.Getenv("UNSIGNED_POLICY"); p {
+	case "":
+		return ingest.AcceptUnsigned
+	case ingest.AcceptUnsigned, ingest.RejectUnsigned:
+		return p
+	default:
+		log.Fatalf("UNSIGNED_POLICY must be %q or %q, not %q", ingest.AcceptUnsigned, ingest.RejectUnsigned, p)
+		return ""
+	}
+}
 
-	// The path to the templates is relative to go.mod, as that's how the path should
-	// be specified when deployed to App Engine.
-	templates := template.Must(template.New("index.html").Funcs(
+// newTemplates parses the templates matched by the glob pattern.
+func newTemplates(pattern string) *template.Template {
+	return template.Must(template.New("index.html").Funcs(
 		template.FuncMap{
 			"millis": func(t time.Time) int64 {
 				return t.Unix() * 1000
 			},
+			"now": func() time.Time {
+				return time.Now()
+			},
 			"RFC3339": func(t time.Time) string {
 				return t.Format(time.RFC3339)
 			},
@@ -55,32 +80,190 @@ func main() {
 				}
 				return fmt.Sprintf(format, *f)
 			},
+			"LossPercent": func(rate float64) float64 {
+				return rate * 100
+			},
 			"AQIStr": func(v float32) string {
 				return aqi.String(int(v))
 			},
 			"AQIAbbrv": func(v float32) string {
 				return aqi.Abbrv(int(v))
 			},
-		}).ParseGlob("web/templates/*"))
+		}).ParseGlob(pattern))
+}
+
+// setDevDefaults sets the environment variables that the app needs for it to run standalone,
+// unless they're set already. Measurements are kept in memory and messages are quarantined
+// to a temporary directory.
+func setDevDefaults() {
+	defaults := map[string]string{
+		"GOOGLE_CLOUD_PROJECT":      "dev",
+		"IOTCORE_REGISTRY":          "dev",
+		"DATABASE":                  "memory",
+		"SINKS":                     "!memory",
+		"PUBSUB_VERIFICATION_TOKEN": "dev",
+		"PUBSUB_AUDIENCE":           "dev",
+	}
+	if os.Getenv("QUARANTINE_DIR") == "" {
+		dir, err := ioutil.TempDir("", "quarantine")
+		if err != nil {
+			log.Fatalf("Failed to make quarantine directory: %v", err)
+		}
+		defaults["QUARANTINE_DIR"] = dir
+	}
+
+	for k, v := range defaults {
+		if os.Getenv(k) == "" {
+			os.Setenv(k, v)
+		}
+	}
+}
+
+// devValidate accepts any JWT. It stands in for idtoken.Validate in dev mode so that
+// messages can be pushed without Pub/Sub.
+func devValidate(ctx context.Context, token string, audience string) (*idtoken.Payload, error) {
+	return &idtoken.Payload{Issuer: "accounts.google.com", Audience: audience}, nil
+}
+
+func main() {
+	dev := flag.Bool("dev", false, "run standalone, keeping measurements in memory, without GCP")
+	flag.Parse()
+
+	if *dev {
+		setDevDefaults()
+		log.Printf("Running in dev mode; quarantine is in %s", os.Getenv("QUARANTINE_DIR"))
+	}
+
+	projectID := mustGetenv("GOOGLE_CLOUD_PROJECT")
+
+	// The path to the templates is relative to go.mod, as that's how the path should
+	// be specified when deployed to App Engine.
+	templates := newTemplates("web/templates/*")
 
 	cache := newCache()
-	database, err := db.NewDatastoreDB(projectID, datastoreKind, cache)
+
+	// Each database is only set up if it's used, either as a sink or to read measurements from,
+	// so that the app can be run without GCP.
+	var datastoreDB Database
+	getDatastoreDB := func() (Database, error) {
+		if datastoreDB != nil {
+			return datastoreDB, nil
+		}
+
+		d, err := db.NewDatastoreDB(projectID, datastoreKind, cache)
+		if err != nil {
+			return nil, err
+		}
+		datastoreDB = d
+		return datastoreDB, nil
+	}
+
+	// The in-memory database is for development (see the -dev flag).
+	var memoryDB interface {
+		Database
+		DeviceIDs(ctx context.Context) ([]string, error)
+	}
+	getMemoryDB := func() (Database, error) {
+		if memoryDB == nil {
+			memoryDB = db.NewMemoryDB()
+		}
+		return memoryDB, nil
+	}
+
+	var sqlDB Database
+	getSQLDB := func() (Database, error) {
+		if sqlDB != nil {
+			return sqlDB, nil
+		}
+
+		d, err := db.NewSQLDB(mustGetenv("SQL_DRIVER"), mustGetenv("SQL_DSN"))
+		if err != nil {
+			return nil, err
+		}
+		sqlDB = d
+		return sqlDB, nil
+	}
+
+	var influxDB *db.InfluxDB
+	getInfluxDB := func() (*db.InfluxDB, error) {
+		if influxDB != nil {
+			return influxDB, nil
+		}
+
+		tags, err := db.ParseInfluxDBTags(os.Getenv("INFLUXDB_TAGS"))
+		if err != nil {
+			return nil, err
+		}
+		influxDB, err = db.NewInfluxDB(mustGetenv("INFLUXDB_SERVER"), mustGetenv("INFLUXDB_TOKEN"), mustGetenv("INFLUXDB_ORG"), mustGetenv("INFLUXDB_BUCKET"), db.InfluxDBOptions{
+			Measurement:     os.Getenv("INFLUXDB_MEASUREMENT"),
+			Tags:            tags,
+			DownsampleAfter: 7 * 24 * time.Hour,
+			MaxPoints:       2000,
+		})
+		return influxDB, err
+	}
+
+	// Measurements are read from Datastore unless DATABASE says otherwise.
+	var database Database
+	var err error
+	switch d := os.Getenv("DATABASE"); d {
+	case "", "datastore":
+		database, err = getDatastoreDB()
+	case "influxdb":
+		database, err = getInfluxDB()
+	case "sql":
+		database, err = getSQLDB()
+	case "memory":
+		database, err = getMemoryDB()
+	default:
+		log.Fatalf("DATABASE must be %q, %q, %q, or %q, not %q", "datastore", "influxdb", "sql", "memory", d)
+	}
 	if err != nil {
-		log.Fatalf("Failed to make datastore DB: %v", err)
+		log.Fatalf("Failed to make database: %v", err)
 	}
 
-	influxDB := db.NewInfluxDB(mustGetenv("INFLUXDB_SERVER"), mustGetenv("INFLUXDB_TOKEN"), mustGetenv("INFLUXDB_ORG"), mustGetenv("INFLUXDB_BUCKET"))
+	// This environment variable should be defined in app.yaml.
+	registryID := mustGetenv("IOTCORE_REGISTRY")
+
+	// Measurements are saved to the sinks listed in SINKS (see ingest.ParseOutputs). By default
+	// the app fails to ingest a message it can't save to Datastore, and InfluxDB is best effort.
+	sinks := os.Getenv("SINKS")
+	if sinks == "" {
+		sinks = "!datastore,influxdb"
+	}
+	outputs, err := ingest.ParseOutputs(sinks, map[string]func(string) (ingest.Sink, error){
+		"datastore": func(string) (ingest.Sink, error) {
+			return getDatastoreDB()
+		},
+		"influxdb": func(string) (ingest.Sink, error) {
+			return getInfluxDB()
+		},
+		"sql": func(string) (ingest.Sink, error) {
+			return getSQLDB()
+		},
+		"memory": func(string) (ingest.Sink, error) {
+			return getMemoryDB()
+		},
+	})
+	if err != nil {
+		log.Fatalf("Failed to make sinks: %v", err)
+	}
 
 	mux := http.NewServeMux()
 
-	mux.Handle("/", rootHandler{
-		ProjectID: projectID,
-		// This environment variable should be defined in app.yaml.
-		IoTCoreRegistry:   mustGetenv("IOTCORE_REGISTRY"),
+	root := rootHandler{
+		ProjectID:         projectID,
+		IoTCoreRegistry:   registryID,
 		DefaultDisplayAge: 12 * time.Hour,
 		Database:          database,
 		Template:          templates,
-	})
+	}
+	// Devices are listed from IoT Core unless measurements are kept in memory, in which case
+	// the devices are those with measurements.
+	if database == memoryDB {
+		root.DeviceIDs = memoryDB.DeviceIDs
+	}
+	mux.Handle("/", root)
 
 	mux.Handle("/uploadz", uploadzHandler{
 		DelayedUploadsDur: 48 * time.Hour,
@@ -88,16 +271,96 @@ func main() {
 		Template:          templates,
 	})
 
+	mux.Handle("/lossz", losszHandler{
+		Dur:      48 * time.Hour,
+		Database: database,
+		Template: templates,
+	})
+
 	mux.Handle("/cachez", cachezHandler{
 		Cache:    cache,
 		Template: templates,
 	})
 
-	mux.Handle("/_ah/push-handlers/telemetry", pushHandler{
+	mux.Handle("/sinkz", sinkzHandler{
+		Outputs:  outputs,
+		Template: templates,
+	})
+
+	// Quarantined messages are kept in Datastore unless a directory is given.
+	var quarantine Quarantine
+	if dir := os.Getenv("QUARANTINE_DIR"); dir != "" {
+		quarantine, err = db.NewFileQuarantine(dir)
+	} else {
+		quarantine, err = db.NewDatastoreQuarantine(projectID, quarantineKind)
+	}
+	if err != nil {
+		log.Fatalf("Failed to make quarantine: %v", err)
+	}
+
+	// Signed measurements are verified against the devices' keys in IoT Core. In dev mode
+	// there are no keys, so signed measurements are rejected.
+	var keys ingest.KeyRegistry
+	if !*dev {
+		keys = &ingest.IoTCoreKeys{
+			ProjectID:  projectID,
+			RegistryID: registryID,
+			TTL:        10 * time.Minute,
+		}
+	}
+
+	pipeline := ingest.Pipeline{
+		Outputs:        outputs,
+		Keys:           keys,
+		UnsignedPolicy: unsignedPolicy(),
+		ProjectID:      projectID,
+		RegistryID:     registryID,
+		FillDeviceID:   os.Getenv("FILL_DEVICE_ID") == "true",
+		Quarantine:     quarantine,
+	}
+
+	// Self-hosted setups can have devices publish to an MQTT broker instead of IoT Core.
+	if broker := os.Getenv("MQTT_BROKER"); broker != "" {
+		topic := os.Getenv("MQTT_TOPIC")
+		if topic == "" {
+			topic = "devices/+/events/#"
+		}
+		clientID := os.Getenv("MQTT_CLIENT_ID")
+		if clientID == "" {
+			clientID = "environmental-sensor-web"
+		}
+
+		opts := mqtt.NewClientOptions().AddBroker(broker).SetClientID(clientID)
+		opts.SetUsername(os.Getenv("MQTT_USERNAME"))
+		opts.SetPassword(os.Getenv("MQTT_PASSWORD"))
+
+		// Messages aren't re-tried here because each sink is re-tried by its output, and a
+		// message that still fails is quarantined so that it can be replayed.
+		sub := ingest.MQTTSubscriber{
+			Pipeline: pipeline,
+			Topic:    topic,
+			QoS:      1,
+		}
+		if _, err := sub.Connect(opts); err != nil {
+			log.Fatalf("Failed to connect to MQTT broker: %v", err)
+		}
+	}
+
+	push := pushHandler{
 		PubSubToken:    mustGetenv("PUBSUB_VERIFICATION_TOKEN"),
 		PubSubAudience: mustGetenv("PUBSUB_AUDIENCE"),
-		Database:       database,
-		InfluxDB:       influxDB,
+		Pipeline:       pipeline,
+	}
+	if *dev {
+		push.Validate = devValidate
+	}
+	mux.Handle("/_ah/push-handlers/telemetry", push)
+
+	mux.Handle("/quarantinez", quarantinezHandler{
+		Quarantine: quarantine,
+		Pipeline:   pipeline,
+		AdminToken: os.Getenv("ADMIN_TOKEN"),
+		Template:   templates,
 	})
 
 	serve(gaelog.Wrap(mux))
@@ -1,16 +1,64 @@
 package main
 
 import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
 	"github.com/mtraver/environmental-sensor/measurement"
+	mpb "github.com/mtraver/environmental-sensor/measurementpb"
+	"github.com/mtraver/environmental-sensor/web/db"
+	tspb "google.golang.org/protobuf/types/known/timestamppb"
+	wpb "google.golang.org/protobuf/types/known/wrapperspb"
 )
 
+// testTemplates are the app's templates. Tests are run in the package's directory.
+var testTemplates = newTemplates("templates/*")
+
 func floatPtr(f float32) *float32 {
 	return &f
 }
 
+// newMemoryDB returns an in-memory database holding the given measurements.
+func newMemoryDB(t *testing.T, ms ...*mpb.Measurement) interface {
+	Database
+	DeviceIDs(ctx context.Context) ([]string, error)
+} {
+	t.Helper()
+	d := db.NewMemoryDB()
+	for _, m := range ms {
+		if err := d.Save(context.Background(), m); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	return d
+}
+
+// tempMeasurement returns a measurement of the temperature taken the given duration ago.
+func tempMeasurement(deviceID string, ago time.Duration, temp float32) *mpb.Measurement {
+	return &mpb.Measurement{
+		DeviceId:  deviceID,
+		Timestamp: tspb.New(time.Now().Add(-ago).Truncate(time.Second)),
+		Temp:      wpb.Float(temp),
+	}
+}
+
+// get serves a GET request for the path and returns the response's status and body.
+func get(t *testing.T, h http.Handler, path string) (int, string) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+
+	b, err := ioutil.ReadAll(w.Result().Body)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return w.Code, string(b)
+}
+
 func TestMeasurementMapToJSON(t *testing.T) {
 	cases := []struct {
 		name         string
@@ -74,6 +122,32 @@ func TestMeasurementMapToJSON(t *testing.T) {
 				},
 			},
 		}, `[{"id":"bar","metrics":["temp"],"values":[{"temp":18.5,"ts":1521997200000},{"temp":18.5,"ts":1522083600000},{"temp":18.5,"ts":1522170000000}]},{"id":"foo","metrics":["temp"],"values":[{"temp":18.5,"ts":1521936000000},{"temp":18.5,"ts":1522022400000},{"temp":18.5,"ts":1522108800000}]}]`},
+		{"readings", map[string][]measurement.StorableMeasurement{
+			"foo": {
+				{
+					DeviceID:  "foo",
+					Timestamp: time.Date(2018, time.March, 25, 0, 0, 0, 0, time.UTC),
+					Temp:      floatPtr(18.5),
+					Readings: []measurement.Reading{
+						{Metric: "temp", Sensor: "mcp9808", Location: "probe-in", Value: 18.5},
+						{Metric: "temp", Sensor: "bme280", Location: "probe-out", Value: 4.5},
+					},
+				},
+			},
+		}, `[{"id":"foo","metrics":[],"values":[{"ts":1521936000000}]},{"id":"foo (probe-in)","metrics":["temp"],"values":[{"temp":18.5,"ts":1521936000000}]},{"id":"foo (probe-out)","metrics":["temp"],"values":[{"temp":4.5,"ts":1521936000000}]}]`},
+		{"summaries", map[string][]measurement.StorableMeasurement{
+			"foo": {
+				{
+					DeviceID:  "foo",
+					Timestamp: time.Date(2018, time.March, 25, 0, 0, 0, 0, time.UTC),
+					Window:    5 * time.Minute,
+					Temp:      floatPtr(18.5),
+					Summaries: []measurement.Summary{
+						{Metric: "temp", Sensor: "mcp9808", Min: 17.5, Max: 19.5, Mean: 18.5, Count: 5},
+					},
+				},
+			},
+		}, `[{"id":"foo","metrics":["temp"],"values":[{"temp":18.5,"ts":1521936000000,"min":{"temp":17.5},"max":{"temp":19.5}}]}]`},
 	}
 
 	for _, c := range cases {
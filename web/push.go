@@ -8,12 +8,9 @@ import (
 	"net/http"
 	"strings"
 
-	mpb "github.com/mtraver/environmental-sensor/measurementpb"
-	mpbutil "github.com/mtraver/environmental-sensor/measurementpbutil"
-	"github.com/mtraver/environmental-sensor/web/db"
+	"github.com/mtraver/environmental-sensor/web/ingest"
 	"github.com/mtraver/gaelog"
 	"google.golang.org/api/idtoken"
-	"google.golang.org/protobuf/proto"
 )
 
 // This is the structure of the JSON payload pushed to the endpoint by Cloud Pub/Sub.
@@ -31,8 +28,10 @@ type pushRequest struct {
 type pushHandler struct {
 	PubSubToken    string
 	PubSubAudience string
-	Database       Database
-	InfluxDB       *db.InfluxDB
+	Pipeline       ingest.Pipeline
+
+	// Validate validates a JWT for the audience. If nil, idtoken.Validate is used.
+	Validate func(ctx context.Context, token string, audience string) (*idtoken.Payload, error)
 }
 
 // authenticate validates the JWT signed by Pub/Sub.
@@ -50,7 +49,11 @@ func (h pushHandler) authenticate(ctx context.Context, r *http.Request) error {
 	token := strings.Split(authHeader, " ")[1]
 
 	// Decode and verify the JWT.
-	payload, err := idtoken.Validate(ctx, token, h.PubSubAudience)
+	validate := h.Validate
+	if validate == nil {
+		validate = idtoken.Validate
+	}
+	payload, err := validate(ctx, token, h.PubSubAudience)
 	if err != nil {
 		return errors.New("Invalid JWT")
 	}
@@ -82,35 +85,19 @@ func (h pushHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	m := &mpb.Measurement{}
-	if err := proto.Unmarshal(msg.Message.Data, m); err != nil {
-		gaelog.Criticalf(ctx, "Failed to unmarshal protobuf: %v\n", err)
-		http.Error(w, fmt.Sprintf("Failed to unmarshal protobuf: %v", err), http.StatusBadRequest)
-		return
-	}
-
-	if err := mpbutil.Validate(m); err != nil {
-		gaelog.Errorf(ctx, "%v", err)
-
-		// Pub/Sub will only stop re-trying the message if it receives a status 200.
-		// The docs say that any of 200, 201, 202, 204, or 102 will have this effect
-		// (https://cloud.google.com/pubsub/docs/push), but the local emulator
-		// doesn't respect anything other than 200, so return 200 just to be safe.
-		// TODO(mtraver) I'd rather return e.g. 202 (http.StatusAccepted) to
-		// indicate that it was successfully received but not that all is ok.
-		w.WriteHeader(http.StatusOK)
+	if err := h.Pipeline.Handle(ctx, msg.Message.ID, msg.Message.Attributes, msg.Message.Data); err != nil {
+		// Return an error so that Pub/Sub re-tries the message.
+		gaelog.Errorf(ctx, "Failed to ingest message %s: %v", msg.Message.ID, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	if err := h.Database.Save(ctx, m); err != nil {
-		gaelog.Errorf(ctx, "Failed to save measurement: %v\n", err)
-	}
-
-	if h.InfluxDB != nil {
-		if err := h.InfluxDB.Save(ctx, m); err != nil {
-			gaelog.Errorf(ctx, "Failed to save measurement to InfluxDB: %v\n", err)
-		}
-	}
-
+	// Rejected messages are acknowledged because re-trying them won't help.
+	// Pub/Sub will only stop re-trying the message if it receives a status 200.
+	// The docs say that any of 200, 201, 202, 204, or 102 will have this effect
+	// (https://cloud.google.com/pubsub/docs/push), but the local emulator
+	// doesn't respect anything other than 200, so return 200 just to be safe.
+	// TODO(mtraver) I'd rather return e.g. 202 (http.StatusAccepted) to
+	// indicate that it was successfully received but not that all is ok.
 	w.WriteHeader(http.StatusOK)
 }
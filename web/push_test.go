@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/mtraver/environmental-sensor/web/db"
+	"github.com/mtraver/environmental-sensor/web/ingest"
+	"google.golang.org/api/idtoken"
+)
+
+// fakeValidate accepts only the JWT "good", which it says was issued by the given issuer.
+func fakeValidate(issuer string) func(ctx context.Context, token string, audience string) (*idtoken.Payload, error) {
+	return func(ctx context.Context, token string, audience string) (*idtoken.Payload, error) {
+		if token != "good" || audience != "audience" {
+			return nil, errors.New("invalid token")
+		}
+		return &idtoken.Payload{Issuer: issuer, Audience: audience}, nil
+	}
+}
+
+func newPushRequest(t *testing.T, id string, data []byte) []byte {
+	t.Helper()
+	var msg pushRequest
+	msg.Message.ID = id
+	msg.Message.Attributes = map[string]string{"deviceId": "foo"}
+	msg.Message.Data = data
+
+	b, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return b
+}
+
+func TestPushHandler(t *testing.T) {
+	m := tempMeasurement("foo", time.Hour, 18.5)
+	good := newPushRequest(t, "1", mustMarshal(t, m))
+
+	cases := []struct {
+		name        string
+		method      string
+		query       string
+		auth        string
+		issuer      string
+		body        []byte
+		failingSink bool
+		wantStatus  int
+		wantSaved   bool
+		quarantined int
+	}{
+		{"ok", "POST", "?token=secret", "Bearer good", "accounts.google.com", good, false, http.StatusOK, true, 0},
+		{"https_issuer", "POST", "?token=secret", "Bearer good", "https://accounts.google.com", good, false, http.StatusOK, true, 0},
+		{"get", "GET", "?token=secret", "Bearer good", "accounts.google.com", nil, false, http.StatusMethodNotAllowed, false, 0},
+		{"no_token", "POST", "", "Bearer good", "accounts.google.com", good, false, http.StatusBadRequest, false, 0},
+		{"wrong_token", "POST", "?token=wrong", "Bearer good", "accounts.google.com", good, false, http.StatusBadRequest, false, 0},
+		{"no_auth", "POST", "?token=secret", "", "accounts.google.com", good, false, http.StatusBadRequest, false, 0},
+		{"bad_jwt", "POST", "?token=secret", "Bearer bad", "accounts.google.com", good, false, http.StatusBadRequest, false, 0},
+		{"wrong_issuer", "POST", "?token=secret", "Bearer good", "example.com", good, false, http.StatusBadRequest, false, 0},
+		{"bad_body", "POST", "?token=secret", "Bearer good", "accounts.google.com", []byte("{"), false, http.StatusBadRequest, false, 0},
+		// Messages that can't be ingested are acknowledged and quarantined.
+		{"bad_data", "POST", "?token=secret", "Bearer good", "accounts.google.com", newPushRequest(t, "2", []byte("not a proto")), false, http.StatusOK, false, 1},
+		// Messages that can't be saved to a required sink are re-tried, though they're still saved to the others.
+		{"save_failed", "POST", "?token=secret", "Bearer good", "accounts.google.com", good, true, http.StatusInternalServerError, true, 0},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ctx := context.Background()
+			database := newMemoryDB(t)
+			quarantine, err := db.NewFileQuarantine(t.TempDir())
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			outputs := []*ingest.Output{{Name: "memory", Sink: database, Required: true}}
+			if c.failingSink {
+				outputs = append(outputs, &ingest.Output{Name: "failing", Sink: failingSink{}, Required: true})
+			}
+
+			h := pushHandler{
+				PubSubToken:    "secret",
+				PubSubAudience: "audience",
+				Pipeline: ingest.Pipeline{
+					Outputs:    outputs,
+					Quarantine: quarantine,
+				},
+				Validate: fakeValidate(c.issuer),
+			}
+
+			r := httptest.NewRequest(c.method, "/_ah/push-handlers/telemetry"+c.query, bytes.NewReader(c.body))
+			if c.auth != "" {
+				r.Header.Set("Authorization", c.auth)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != c.wantStatus {
+				t.Errorf("got status %d, want %d", w.Code, c.wantStatus)
+			}
+
+			latest, err := database.Latest(ctx, []string{"foo"})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if _, saved := latest["foo"]; saved != c.wantSaved {
+				t.Errorf("got saved = %t, want %t", saved, c.wantSaved)
+			}
+
+			q, err := quarantine.List(ctx)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(q) != c.quarantined {
+				t.Errorf("got %d quarantined messages, want %d", len(q), c.quarantined)
+			}
+		})
+	}
+}
+
+func TestPushHandlerDuplicate(t *testing.T) {
+	ctx := context.Background()
+	database := newMemoryDB(t)
+	h := pushHandler{
+		PubSubToken:    "secret",
+		PubSubAudience: "audience",
+		Pipeline:       ingest.Pipeline{Outputs: []*ingest.Output{{Name: "memory", Sink: database, Required: true}}},
+		Validate:       fakeValidate("accounts.google.com"),
+	}
+
+	m := tempMeasurement("foo", time.Hour, 18.5)
+	body := newPushRequest(t, "1", mustMarshal(t, m))
+
+	// Pub/Sub delivers messages at least once, so the same message may be pushed again.
+	for i := 0; i < 2; i++ {
+		r := httptest.NewRequest("POST", "/_ah/push-handlers/telemetry?token=secret", bytes.NewReader(body))
+		r.Header.Set("Authorization", "Bearer good")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Push %d: got status %d, want %d", i, w.Code, http.StatusOK)
+		}
+	}
+
+	got, err := database.Since(ctx, time.Now().Add(-2*time.Hour))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(got["foo"]) != 1 {
+		t.Fatalf("got %d measurements, want 1", len(got["foo"]))
+	}
+	if diff := cmp.Diff(got["foo"][0].Timestamp, m.GetTimestamp().AsTime()); diff != "" {
+		t.Errorf("Unexpected result (-got +want):\n%s", diff)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/mtraver/environmental-sensor/web/db"
+	"github.com/mtraver/environmental-sensor/web/ingest"
+	"github.com/mtraver/gaelog"
+)
+
+// Quarantine keeps the Pub/Sub messages rejected by the push handler.
+type Quarantine interface {
+	Add(ctx context.Context, q db.QuarantinedMessage) error
+	List(ctx context.Context) ([]db.QuarantinedMessage, error)
+	Get(ctx context.Context, id string) (db.QuarantinedMessage, error)
+	Delete(ctx context.Context, id string) error
+}
+
+// quarantinezHandler renders a page listing quarantined messages. Selected messages can be
+// replayed through the ingest pipeline, e.g. after a bug that caused them to be rejected is
+// fixed, or deleted. The messages' data isn't fit to show to everyone, so app.yaml limits the
+// page to the project's admins.
+type quarantinezHandler struct {
+	Quarantine Quarantine
+	Pipeline   ingest.Pipeline
+
+	// Replaying and deleting messages requires this token. If it's empty they're disabled.
+	AdminToken string
+	Template   *template.Template
+}
+
+// replayResult describes the outcome of replaying or deleting a quarantined message.
+type replayResult struct {
+	ID      string
+	Outcome string
+}
+
+func (h quarantinezHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := newContext(r)
+
+	var results []replayResult
+	if r.Method == "POST" {
+		if h.AdminToken == "" || subtle.ConstantTimeCompare([]byte(r.PostFormValue("token")), []byte(h.AdminToken)) != 1 {
+			http.Error(w, "Bad token", http.StatusForbidden)
+			return
+		}
+
+		action := r.PostFormValue("action")
+		if action != "replay" && action != "delete" {
+			http.Error(w, fmt.Sprintf("Unknown action %q", action), http.StatusBadRequest)
+			return
+		}
+
+		for _, id := range r.PostForm["id"] {
+			var outcome string
+			if action == "replay" {
+				outcome = h.replay(ctx, id)
+			} else if err := h.Quarantine.Delete(ctx, id); err != nil {
+				outcome = fmt.Sprintf("failed to delete: %v", err)
+			} else {
+				outcome = "deleted"
+			}
+			results = append(results, replayResult{ID: id, Outcome: outcome})
+		}
+	} else if r.Method != "GET" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	msgs, err := h.Quarantine.List(ctx)
+	if err != nil {
+		gaelog.Errorf(ctx, "Error fetching quarantined messages: %v", err)
+	}
+
+	data := struct {
+		Messages  []db.QuarantinedMessage
+		Results   []replayResult
+		CanReplay bool
+		Error     error
+	}{
+		Messages:  msgs,
+		Results:   results,
+		CanReplay: h.AdminToken != "",
+		Error:     err,
+	}
+
+	if err := h.Template.ExecuteTemplate(w, "quarantinez", data); err != nil {
+		gaelog.Errorf(ctx, "Could not execute template: %v", err)
+	}
+}
+
+// replay runs the quarantined message with the given ID through the push handler again and
+// returns a description of the outcome. The message is removed from the quarantine if it's
+// accepted, and its reason is updated if it's rejected again.
+func (h quarantinezHandler) replay(ctx context.Context, id string) string {
+	q, err := h.Quarantine.Get(ctx, id)
+	if err != nil {
+		return fmt.Sprintf("failed to get message: %v", err)
+	}
+
+	reasons, err := h.Pipeline.Ingest(ctx, q.AttributeMap(), q.Data)
+	if err != nil {
+		return fmt.Sprintf("failed, still quarantined: %v", err)
+	}
+
+	if len(reasons) > 0 {
+		q.Reason = strings.Join(reasons, "; ")
+		if err := h.Quarantine.Add(ctx, q); err != nil {
+			return fmt.Sprintf("rejected again (%s) and failed to update: %v", q.Reason, err)
+		}
+		return fmt.Sprintf("rejected again: %s", q.Reason)
+	}
+
+	if err := h.Quarantine.Delete(ctx, id); err != nil {
+		return fmt.Sprintf("accepted but failed to remove from quarantine: %v", err)
+	}
+	return "accepted"
+}
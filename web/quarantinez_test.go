@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	mpb "github.com/mtraver/environmental-sensor/measurementpb"
+	"github.com/mtraver/environmental-sensor/web/db"
+	"github.com/mtraver/environmental-sensor/web/ingest"
+	"google.golang.org/protobuf/proto"
+	tspb "google.golang.org/protobuf/types/known/timestamppb"
+	wpb "google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// saveDatabase is an ingest.Sink that records saved measurements.
+type saveDatabase struct {
+	saved []*mpb.Measurement
+}
+
+func (d *saveDatabase) Save(ctx context.Context, m *mpb.Measurement) error {
+	d.saved = append(d.saved, m)
+	return nil
+}
+
+func mustMarshal(t *testing.T, m proto.Message) []byte {
+	t.Helper()
+	b, err := proto.Marshal(m)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return b
+}
+
+func TestQuarantinezReplay(t *testing.T) {
+	ctx := context.Background()
+	quarantine, err := db.NewFileQuarantine(t.TempDir())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	good := &mpb.Measurement{
+		DeviceId:  "foo",
+		Timestamp: tspb.New(time.Date(2018, time.March, 25, 0, 0, 0, 0, time.UTC)),
+		Temp:      wpb.Float(18.5),
+	}
+	msgs := []db.QuarantinedMessage{
+		db.NewQuarantinedMessage("1", "failed to save", mustMarshal(t, good), nil),
+		db.NewQuarantinedMessage("2", "failed to unmarshal protobuf", []byte("not a proto"), nil),
+		db.NewQuarantinedMessage("3", "unwanted", []byte("not a proto"), nil),
+	}
+	for _, q := range msgs {
+		if err := quarantine.Add(ctx, q); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	database := &saveDatabase{}
+	h := quarantinezHandler{
+		Quarantine: quarantine,
+		Pipeline:   ingest.Pipeline{Outputs: []*ingest.Output{{Name: "db", Sink: database, Required: true}}},
+		AdminToken: "secret",
+		Template:   template.Must(template.New("quarantinez").Parse(`{{ range .Results }}{{ .ID }}: {{ .Outcome }}{{ "\n" }}{{ end }}`)),
+	}
+
+	post := func(form url.Values) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "/quarantinez", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := post(url.Values{"token": {"wrong"}, "action": {"replay"}, "id": {"1"}}); w.Code != http.StatusForbidden {
+		t.Errorf("got status %d with wrong token, want %d", w.Code, http.StatusForbidden)
+	}
+
+	w := post(url.Values{"token": {"secret"}, "action": {"replay"}, "id": {"1", "2"}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d, want %d", w.Code, http.StatusOK)
+	}
+	if !strings.Contains(w.Body.String(), "1: accepted") || !strings.Contains(w.Body.String(), "2: rejected again") {
+		t.Errorf("Unexpected results:\n%s", w.Body.String())
+	}
+	if len(database.saved) != 1 {
+		t.Errorf("got %d saved, want 1", len(database.saved))
+	}
+
+	if w := post(url.Values{"token": {"secret"}, "action": {"delete"}, "id": {"3"}}); w.Code != http.StatusOK {
+		t.Fatalf("got status %d, want %d", w.Code, http.StatusOK)
+	}
+
+	remaining, err := quarantine.List(ctx)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(remaining) != 1 || remaining[0].ID != "2" {
+		t.Errorf("got remaining %v, want only message 2", remaining)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"html/template"
@@ -24,6 +25,17 @@ type rootHandler struct {
 	DefaultDisplayAge time.Duration
 	Database          Database
 	Template          *template.Template
+
+	// DeviceIDs lists the devices whose latest measurements are shown. If nil, the devices
+	// in the IoT Core registry are listed.
+	DeviceIDs func(ctx context.Context) ([]string, error)
+}
+
+func (h rootHandler) deviceIDs(ctx context.Context) ([]string, error) {
+	if h.DeviceIDs != nil {
+		return h.DeviceIDs(ctx)
+	}
+	return device.GetDeviceIDs(ctx, h.ProjectID, h.IoTCoreRegistry)
 }
 
 func (h rootHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -198,7 +210,7 @@ func (h rootHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		defer wg.Done()
 		start := time.Now()
 
-		ids, err := device.GetDeviceIDs(ctx, h.ProjectID, h.IoTCoreRegistry)
+		ids, err := h.deviceIDs(ctx)
 		if err != nil {
 			latestErr = err
 			gaelog.Errorf(ctx, "Error getting device IDs: %v", err)
@@ -289,7 +301,9 @@ func (h rootHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 // JSON array for use in the template. The JSON is an array with one element for each
 // device ID. It's constructed this way, instead of as a map where keys are device IDs,
 // because the JavaScript visualization package D3 (https://d3js.org/) works better with
-// arrays of data than maps.
+// arrays of data than maps. If a device has more than one sensor reporting the same metric
+// then each of those sensors gets its own element, with an ID like "foo (probe-in)", so
+// that their values are plotted as separate lines.
 func measurementMapToJSON(measurements map[string][]measurement.StorableMeasurement) ([]byte, error) {
 	type dataForTemplate struct {
 		ID      string                            `json:"id"`
@@ -297,12 +311,27 @@ func measurementMapToJSON(measurements map[string][]measurement.StorableMeasurem
 		Values  []measurement.StorableMeasurement `json:"values"`
 	}
 
+	series := make(map[string][]measurement.StorableMeasurement)
+	for id, v := range measurements {
+		values := make([]measurement.StorableMeasurement, 0, len(v))
+		for _, sm := range v {
+			sm, bySensor := sm.SplitBySensor()
+			values = append(values, sm)
+
+			for label, s := range bySensor {
+				sensorID := fmt.Sprintf("%s (%s)", id, label)
+				series[sensorID] = append(series[sensorID], s)
+			}
+		}
+		series[id] = values
+	}
+
 	// Sort the map's keys so that the resulting JSON always has them in the same
 	// order. This ensures that e.g. the color assigned to each line on a plot is
 	// the same for every page load.
-	keys := make([]string, len(measurements))
+	keys := make([]string, len(series))
 	i := 0
-	for k := range measurements {
+	for k := range series {
 		keys[i] = k
 		i++
 	}
@@ -314,7 +343,7 @@ func measurementMapToJSON(measurements map[string][]measurement.StorableMeasurem
 		// present in the given measurements.
 		metricsSet := make(map[string]bool)
 
-		for _, sm := range measurements[k] {
+		for _, sm := range series[k] {
 			for metricName, _ := range sm.ValueMap() {
 				metric, _ := measurement.GetMetric(metricName)
 				metricsSet[metric.Abbrv] = true
@@ -332,7 +361,7 @@ func measurementMapToJSON(measurements map[string][]measurement.StorableMeasurem
 		data = append(data, dataForTemplate{
 			ID:      k,
 			Metrics: metrics,
-			Values:  measurements[k],
+			Values:  series[k],
 		})
 	}
 	return json.Marshal(data)
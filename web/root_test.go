@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRootHandler(t *testing.T) {
+	database := newMemoryDB(t,
+		tempMeasurement("foo", 2*time.Hour, 18.5),
+		tempMeasurement("foo", time.Hour, 19.5),
+		tempMeasurement("bar", 24*time.Hour, 12.5))
+	h := rootHandler{
+		DefaultDisplayAge: 12 * time.Hour,
+		Database:          database,
+		Template:          testTemplates,
+		DeviceIDs:         database.DeviceIDs,
+	}
+
+	cases := []struct {
+		name       string
+		method     string
+		path       string
+		form       url.Values
+		wantStatus int
+		want       []string
+		notWant    []string
+	}{
+		{
+			name:       "default",
+			method:     "GET",
+			path:       "/",
+			wantStatus: http.StatusOK,
+			// The stats cover the last 12 hours, but every device's latest measurement is shown.
+			want:    []string{`<th scope="row">foo</th>`, `[18.50, 19.50]`, `"id":"foo"`, "bar"},
+			notWant: []string{`<th scope="row">bar</th>`, "Error getting latest measurements"},
+		},
+		{
+			name:       "hours_ago",
+			method:     "POST",
+			path:       "/",
+			form:       url.Values{"form-name": {"hoursago"}, "hoursago": {"48"}},
+			wantStatus: http.StatusOK,
+			want:       []string{`<th scope="row">foo</th>`, `<th scope="row">bar</th>`, `"id":"bar"`},
+		},
+		{
+			name:       "days_ago",
+			method:     "POST",
+			path:       "/",
+			form:       url.Values{"form-name": {"daysago"}, "daysago": {"0.5"}},
+			wantStatus: http.StatusOK,
+			want:       []string{`<th scope="row">foo</th>`},
+			notWant:    []string{`<th scope="row">bar</th>`},
+		},
+		{
+			name:   "range",
+			method: "POST",
+			path:   "/",
+			form: url.Values{
+				"form-name":          {"range"},
+				"startdate-adjusted": {time.Now().Add(-30 * time.Hour).Format(time.RFC3339Nano)},
+				"enddate-adjusted":   {time.Now().Add(-20 * time.Hour).Format(time.RFC3339Nano)},
+			},
+			wantStatus: http.StatusOK,
+			want:       []string{`<th scope="row">bar</th>`},
+			notWant:    []string{`<th scope="row">foo</th>`},
+		},
+		{
+			name:       "bad_range",
+			method:     "POST",
+			path:       "/",
+			form:       url.Values{"form-name": {"range"}, "startdate-adjusted": {"yesterday"}},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "bad_days_ago",
+			method:     "POST",
+			path:       "/",
+			form:       url.Values{"form-name": {"daysago"}, "daysago": {"0"}},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "bad_hours_ago",
+			method:     "POST",
+			path:       "/",
+			form:       url.Values{"form-name": {"hoursago"}, "hoursago": {"x"}},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "unknown_form",
+			method:     "POST",
+			path:       "/",
+			form:       url.Values{"form-name": {"foo"}},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "not_root",
+			method:     "GET",
+			path:       "/foo",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := httptest.NewRequest(c.method, c.path, strings.NewReader(c.form.Encode()))
+			if c.form != nil {
+				r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != c.wantStatus {
+				t.Fatalf("got status %d, want %d", w.Code, c.wantStatus)
+			}
+
+			b, err := ioutil.ReadAll(w.Result().Body)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			for _, s := range c.want {
+				if !strings.Contains(string(b), s) {
+					t.Errorf("Response doesn't contain %q", s)
+				}
+			}
+			for _, s := range c.notWant {
+				if strings.Contains(string(b), s) {
+					t.Errorf("Response contains %q", s)
+				}
+			}
+		})
+	}
+}
+
+func TestRootHandlerDeviceIDsError(t *testing.T) {
+	h := rootHandler{
+		DefaultDisplayAge: 12 * time.Hour,
+		Database:          newMemoryDB(t, tempMeasurement("foo", time.Hour, 18.5)),
+		Template:          testTemplates,
+		DeviceIDs: func(ctx context.Context) ([]string, error) {
+			return nil, errors.New("registry unavailable")
+		},
+	}
+
+	status, body := get(t, h, "/")
+	if status != http.StatusOK {
+		t.Errorf("got status %d, want %d", status, http.StatusOK)
+	}
+	// The plot is still shown.
+	for _, s := range []string{"Error getting latest measurements", `"id":"foo"`} {
+		if !strings.Contains(body, s) {
+			t.Errorf("Response doesn't contain %q", s)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"html/template"
+	"net/http"
+
+	"github.com/mtraver/environmental-sensor/web/ingest"
+	"github.com/mtraver/gaelog"
+)
+
+type sinkzHandler struct {
+	Outputs  []*ingest.Output
+	Template *template.Template
+}
+
+func (h sinkzHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := newContext(r)
+
+	stats := make([]ingest.SinkStats, len(h.Outputs))
+	for i, o := range h.Outputs {
+		stats[i] = o.Stats()
+	}
+
+	if err := h.Template.ExecuteTemplate(w, "sinkz", stats); err != nil {
+		gaelog.Errorf(ctx, "Could not execute template: %v", err)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	mpb "github.com/mtraver/environmental-sensor/measurementpb"
+	"github.com/mtraver/environmental-sensor/web/ingest"
+)
+
+// failingSink is an ingest.Sink that fails every save.
+type failingSink struct{}
+
+func (failingSink) Save(ctx context.Context, m *mpb.Measurement) error {
+	return errors.New("sink unavailable")
+}
+
+func TestSinkzHandler(t *testing.T) {
+	outputs := []*ingest.Output{
+		ingest.NewOutput("memory", newMemoryDB(t), true),
+		{Name: "webhook", Sink: failingSink{}},
+	}
+	pipeline := ingest.Pipeline{Outputs: outputs}
+	if _, err := pipeline.Ingest(context.Background(), nil, mustMarshal(t, tempMeasurement("foo", time.Hour, 18.5))); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	h := sinkzHandler{
+		Outputs:  outputs,
+		Template: testTemplates,
+	}
+
+	status, body := get(t, h, "/sinkz")
+	if status != http.StatusOK {
+		t.Errorf("got status %d, want %d", status, http.StatusOK)
+	}
+
+	// Strip the whitespace between cells so that the rows can be compared.
+	rows := strings.Join(strings.Fields(body), "")
+	for _, s := range []string{
+		"<td>memory</td><td>true</td><td>1</td><td>0</td><td>0</td><td></td>",
+		"<td>webhook</td><td>false</td><td>0</td><td>1</td><td>0</td><td>",
+		"sinkunavailable</td>",
+	} {
+		if !strings.Contains(rows, s) {
+			t.Errorf("Response doesn't contain %q", s)
+		}
+	}
+}
@@ -0,0 +1,8 @@
+//go:build sqlite3 && cgo
+// +build sqlite3,cgo
+
+package main
+
+// The SQLite driver needs cgo, so it's only built in with the sqlite3 build tag, e.g.
+// "go build -tags sqlite3". Without it the only SQL driver is "postgres".
+import _ "github.com/mattn/go-sqlite3"
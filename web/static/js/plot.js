@@ -1,8 +1,57 @@
+// Returns the minimum of the metric over a value's aggregation window, or the
+// value itself if it isn't a summary of a window.
+function low(d, metric) {
+  return d.min && d.min[metric] !== undefined ? d.min[metric] : d[metric];
+}
+
+// Returns the maximum of the metric over a value's aggregation window, or the
+// value itself if it isn't a summary of a window.
+function high(d, metric) {
+  return d.max && d.max[metric] !== undefined ? d.max[metric] : d[metric];
+}
+
+// Returns true if the value summarizes a window and so has a min/max band.
+function hasBand(d, metric) {
+  return d.min !== undefined && d.min[metric] !== undefined &&
+    d.max !== undefined && d.max[metric] !== undefined;
+}
+
+// Devices that publish by exception skip values that are close to the last
+// published value, so each value holds until the next one. Returns the values
+// with the held value repeated just before each next value, so that the line
+// stays flat rather than sloping, and with a break in the line wherever the
+// gap is longer than twice the heartbeat, which means values were lost. Values
+// without a heartbeat are returned unchanged.
+function withHeldValues(values, metric) {
+  var out = [];
+  for (var i = 0; i < values.length; i++) {
+    var d = values[i];
+    out.push(d);
+
+    var next = values[i + 1];
+    if (!d.heartbeat || d[metric] === undefined || next === undefined) {
+      continue;
+    }
+
+    var held = Object.assign({}, d);
+    if (next.ts - d.ts > 2 * d.heartbeat) {
+      held.ts = d.ts + d.heartbeat;
+      out.push(held);
+      out.push({ts: held.ts + 1});
+    } else if (next.ts - d.ts > 1) {
+      held.ts = next.ts - 1;
+      out.push(held);
+    }
+  }
+  return out;
+}
+
 function multiExtent(data, metric, startTimestamp, endTimestamp) {
-  // Get the extent of the values for each device
-  var extents = data.map(d => d3.extent(
-    d.values.filter(e => e.ts > startTimestamp && e.ts < endTimestamp),
-    e => e[metric]));
+  // Get the extent of the values, including min/max bands, for each device
+  var extents = data.map(function(d) {
+    var visible = d.values.filter(e => e.ts > startTimestamp && e.ts < endTimestamp);
+    return [d3.min(visible, e => low(e, metric)), d3.max(visible, e => high(e, metric))];
+  });
 
   // Flatten the array of extents, and then get the overall extent
   return d3.extent(extents.reduce((acc, val) => acc.concat(val), []), e => e);
@@ -44,14 +93,24 @@ function makePlot(selector, data, metric, name, unit, startDate, endDate) {
 
   var line = d3.line()
       .curve(d3.curveBasis)
+      .defined(function(d) { return d[metric] !== undefined; })
       .x(function(d) { return x(d.ts); })
       .y(function(d) { return y(d[metric]); });
 
   var line2 = d3.line()
       .curve(d3.curveBasis)
+      .defined(function(d) { return d[metric] !== undefined; })
       .x(function(d) { return x2(d.ts); })
       .y(function(d) { return y2(d[metric]); });
 
+  // The band around a line that shows the min and max over each aggregation window.
+  var area = d3.area()
+      .curve(d3.curveBasis)
+      .defined(function(d) { return hasBand(d, metric); })
+      .x(function(d) { return x(d.ts); })
+      .y0(function(d) { return y(d.min[metric]); })
+      .y1(function(d) { return y(d.max[metric]); });
+
   x.domain([startDate, endDate]);
   x2.domain(x.domain());
 
@@ -60,7 +119,11 @@ function makePlot(selector, data, metric, name, unit, startDate, endDate) {
   filtered = [];
   for (var i = 0; i < data.length; i++) {
     if (data[i].metrics.includes(metric)) {
-      filtered.push(data[i]);
+      filtered.push({
+        id: data[i].id,
+        metrics: data[i].metrics,
+        values: withHeldValues(data[i].values, metric),
+      });
     }
   }
   data = filtered;
@@ -70,12 +133,12 @@ function makePlot(selector, data, metric, name, unit, startDate, endDate) {
     y.domain([
       d3.min(data, function(c) {
         return d3.min(c.values, function(d) {
-          return d[metric];
+          return low(d, metric);
         });
       }),
       d3.max(data, function(c) {
         return d3.max(c.values, function(d) {
-          return d[metric];
+          return high(d, metric);
         });
       })
     ]);
@@ -169,13 +232,21 @@ function makePlot(selector, data, metric, name, unit, startDate, endDate) {
     return;
   }
 
-  // Draw lines on the focus plot
-  focus.selectAll(".device")
+  // Draw lines on the focus plot, each over its min/max band if it has one
+  var devices = focus.selectAll(".device")
       .data(data)
     .enter().append("g")
       .attr("class", "device")
-      .attr("clip-path", "url(#" + clipId + ")")
-    .append("path")
+      .attr("clip-path", "url(#" + clipId + ")");
+
+  devices.append("path")
+      .attr("class", "band")
+      .attr("d", function(d) { return area(d.values); })
+      .style("fill", function(d) { return z(d.id); })
+      .style("fill-opacity", 0.2)
+      .style("stroke", "none");
+
+  devices.append("path")
       .attr("class", "line")
       .attr("d", function(d) { return line(d.values); })
       .style("stroke", function(d) { return z(d.id); })
@@ -241,6 +312,8 @@ function makePlot(selector, data, metric, name, unit, startDate, endDate) {
     x.domain(s.map(x2.invert, x2));
     focus.selectAll(".line")
         .attr("d", function(d) { return line(d.values); });
+    focus.selectAll(".band")
+        .attr("d", function(d) { return area(d.values); });
     focus.select(".axis--x").call(xAxis);
     svg.select(".zoom").call(
         zoom.transform,
@@ -259,6 +332,8 @@ function makePlot(selector, data, metric, name, unit, startDate, endDate) {
     x.domain(t.rescaleX(x2).domain());
     focus.selectAll(".line")
         .attr("d", function(d) { return line(d.values); });
+    focus.selectAll(".band")
+        .attr("d", function(d) { return area(d.values); });
     focus.select(".axis--x").call(xAxis);
     context.select(".brush").call(brush.move, x.range().map(t.invertX, t));
 
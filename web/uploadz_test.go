@@ -0,0 +1,38 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	tspb "google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestUploadzHandler(t *testing.T) {
+	delayed := tempMeasurement("foo", 3*time.Hour, 18.5)
+	delayed.UploadTimestamp = tspb.New(time.Now().Add(-time.Hour))
+	old := tempMeasurement("bar", 72*time.Hour, 12.5)
+	old.UploadTimestamp = tspb.New(time.Now().Add(-60 * time.Hour))
+
+	h := uploadzHandler{
+		DelayedUploadsDur: 48 * time.Hour,
+		Database:          newMemoryDB(t, delayed, old, tempMeasurement("baz", time.Hour, 20)),
+		Template:          testTemplates,
+	}
+
+	status, body := get(t, h, "/uploadz")
+	if status != http.StatusOK {
+		t.Errorf("got status %d, want %d", status, http.StatusOK)
+	}
+	for _, s := range []string{"Delayed Uploads (Past 48h0m0s)", "Total: 1", "foo"} {
+		if !strings.Contains(body, s) {
+			t.Errorf("Response doesn't contain %q", s)
+		}
+	}
+	for _, s := range []string{"bar", "baz"} {
+		if strings.Contains(body, s) {
+			t.Errorf("Response contains %q", s)
+		}
+	}
+}